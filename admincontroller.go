@@ -0,0 +1,48 @@
+package mochi
+
+// AdminUserIDFilter is the list filter admin controllers accept to narrow
+// results to a single user, as in ?user_id=42.
+const AdminUserIDFilter = "user_id"
+
+// NewAdminController returns a controller for back-office tooling. It is
+// mounted under AdminRequired and lists, gets, updates, and deletes items
+// across all users; lists can be narrowed with the user_id filter. Create is
+// disabled since admin created items would have no owner.
+func NewAdminController[M Resource[K], K comparable](
+	svc Service[M, K],
+	logger LoggerService,
+	authSvc AuthService,
+	updateRequestConstructor ResourceRequestConstructor[M],
+	opts ...ControllerOption[M, K],
+) Controller[M, K] {
+	ctrl := &controller[M, K]{
+		additionalDetailRoutes: make([]Route, 0),
+		disabledRoutes:         map[ControllerRoute]bool{RouteCreate: true},
+
+		auth:    authSvc,
+		logger:  logger,
+		metrics: NewNoopMetricsService(),
+		svc:     svc,
+		readSvc: svc,
+
+		errorHandler: DefaultErrorHandler,
+		idParamName:  DefaultIDParamName,
+		idParser:     ParseID[K],
+		admin:        true,
+		filterFields: []FilterField{{Name: AdminUserIDFilter, Operators: []FilterOperator{FilterEq}}},
+
+		updateRequestConstructor: updateRequestConstructor,
+	}
+
+	if isViewModel[M]() {
+		WithReadOnly[M]()(ctrl)
+	}
+
+	for _, opt := range opts {
+		opt(ctrl)
+	}
+
+	ctrl.mount()
+
+	return ctrl
+}
@@ -0,0 +1,344 @@
+package mochi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+const (
+	QueueReportersGroup = `group:"queue_reporters"`
+
+	// AdminStatsWindow is how far back request rates are reported, kept in
+	// one-minute buckets.
+	AdminStatsWindow = 15 * time.Minute
+
+	// RowCountTTL bounds how often row counts, which scan whole tables,
+	// are refreshed.
+	RowCountTTL = time.Minute
+)
+
+// QueueReporter reports the depth of a job queue to the admin stats.
+type QueueReporter interface {
+	QueueDepths(ctx context.Context) (map[string]int64, error)
+}
+
+// AsQueueReporter annotates a constructor returning a QueueReporter so its
+// queues appear in the admin stats.
+func AsQueueReporter(constructor interface{}) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(QueueReportersGroup)))
+}
+
+type RequestStats struct {
+	Window          string  `json:"window"`
+	Requests        int64   `json:"requests"`
+	ClientErrors    int64   `json:"client_errors"`
+	ServerErrors    int64   `json:"server_errors"`
+	RequestsPerSec  float64 `json:"requests_per_sec"`
+	ErrorRate       float64 `json:"error_rate"`
+	AvgLatencyMs    float64 `json:"avg_latency_ms"`
+	MaxLatencyMs    int64   `json:"max_latency_ms"`
+	LastMinuteCount int64   `json:"last_minute_requests"`
+}
+
+type DBPoolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// AdminStats summarizes system health for internal dashboards.
+type AdminStats struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Uptime      string           `json:"uptime"`
+	Requests    RequestStats     `json:"requests"`
+	DBReady     bool             `json:"db_ready"`
+	DBPool      *DBPoolStats     `json:"db_pool,omitempty"`
+	Queues      map[string]int64 `json:"queues"`
+	Rows        map[string]int64 `json:"rows"`
+	Errors      []string         `json:"errors,omitempty"`
+}
+
+func (s *AdminStats) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// AdminStatsService serves system health as JSON at GET / of its admin
+// router. Request rates only cover requests passing through Middleware,
+// which should wrap the whole app, for example through AsRouterMiddleware.
+type AdminStatsService interface {
+	Middleware() func(http.Handler) http.Handler
+	Stats(ctx context.Context) *AdminStats
+
+	GetRouter() *chi.Mux
+}
+
+type AdminStatsServiceParams struct {
+	fx.In
+
+	Auth      AuthService
+	DB        DBService
+	Logger    LoggerService
+	Models    ModelList
+	Reporters []QueueReporter `group:"queue_reporters"`
+}
+
+type AdminStatsServiceResult struct {
+	fx.Out
+
+	AdminStatsService AdminStatsService
+}
+
+type requestBucket struct {
+	minute       time.Time
+	requests     int64
+	clientErrors int64
+	serverErrors int64
+	latencyMs    int64
+	maxLatencyMs int64
+}
+
+type adminStatsService struct {
+	db        DBService
+	logger    LoggerService
+	models    ModelList
+	reporters []QueueReporter
+	startedAt time.Time
+	Router    *chi.Mux
+
+	mu      sync.Mutex
+	buckets []requestBucket
+
+	rowsMu        sync.Mutex
+	rows          map[string]int64
+	rowsCountedAt time.Time
+}
+
+func NewAdminStatsService(params AdminStatsServiceParams) AdminStatsServiceResult {
+	srv := &adminStatsService{
+		db:        params.DB,
+		logger:    params.Logger,
+		models:    params.Models,
+		reporters: params.Reporters,
+		startedAt: time.Now(),
+		buckets:   make([]requestBucket, int(AdminStatsWindow/time.Minute)),
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Get("/", srv.handleStats)
+
+	return AdminStatsServiceResult{AdminStatsService: srv}
+}
+
+func (srv *adminStatsService) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+
+			srv.record(start, time.Since(start), ww.Status())
+		})
+	}
+}
+
+func (srv *adminStatsService) record(at time.Time, latency time.Duration, status int) {
+	minute := at.Truncate(time.Minute)
+	slot := int(minute.Unix()/60) % len(srv.buckets)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	bucket := &srv.buckets[slot]
+	if !bucket.minute.Equal(minute) {
+		*bucket = requestBucket{minute: minute}
+	}
+
+	ms := latency.Milliseconds()
+
+	bucket.requests++
+	bucket.latencyMs += ms
+
+	if ms > bucket.maxLatencyMs {
+		bucket.maxLatencyMs = ms
+	}
+
+	switch {
+	case status >= http.StatusInternalServerError:
+		bucket.serverErrors++
+	case status >= http.StatusBadRequest:
+		bucket.clientErrors++
+	}
+}
+
+func (srv *adminStatsService) requestStats(now time.Time) RequestStats {
+	stats := RequestStats{Window: AdminStatsWindow.String()}
+
+	oldest := now.Truncate(time.Minute).Add(-AdminStatsWindow + time.Minute)
+	current := now.Truncate(time.Minute)
+
+	var latencyMs int64
+
+	srv.mu.Lock()
+	for _, bucket := range srv.buckets {
+		if bucket.minute.Before(oldest) || bucket.minute.After(current) {
+			continue
+		}
+
+		stats.Requests += bucket.requests
+		stats.ClientErrors += bucket.clientErrors
+		stats.ServerErrors += bucket.serverErrors
+		latencyMs += bucket.latencyMs
+
+		if bucket.maxLatencyMs > stats.MaxLatencyMs {
+			stats.MaxLatencyMs = bucket.maxLatencyMs
+		}
+
+		if bucket.minute.Equal(current) {
+			stats.LastMinuteCount = bucket.requests
+		}
+	}
+	srv.mu.Unlock()
+
+	// only count the time the process has actually been up
+	window := AdminStatsWindow
+	if uptime := now.Sub(srv.startedAt); uptime < window {
+		window = uptime
+	}
+
+	if window > 0 {
+		stats.RequestsPerSec = float64(stats.Requests) / window.Seconds()
+	}
+
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.ServerErrors) / float64(stats.Requests)
+		stats.AvgLatencyMs = float64(latencyMs) / float64(stats.Requests)
+	}
+
+	return stats
+}
+
+// Stats collects every section; failing sections are reported in Errors
+// rather than failing the whole summary.
+func (srv *adminStatsService) Stats(ctx context.Context) *AdminStats {
+	now := time.Now()
+
+	stats := &AdminStats{
+		GeneratedAt: now,
+		Uptime:      now.Sub(srv.startedAt).Round(time.Second).String(),
+		Requests:    srv.requestStats(now),
+		DBReady:     srv.db.Ready(),
+		Queues:      make(map[string]int64),
+	}
+
+	if stats.DBReady {
+		if pool, err := srv.poolStats(ctx); err != nil {
+			stats.Errors = append(stats.Errors, err.Error())
+		} else {
+			stats.DBPool = pool
+		}
+
+		rows, err := srv.rowCounts(ctx)
+		if err != nil {
+			stats.Errors = append(stats.Errors, err.Error())
+		}
+
+		stats.Rows = rows
+	}
+
+	for _, reporter := range srv.reporters {
+		depths, err := reporter.QueueDepths(ctx)
+		if err != nil {
+			stats.Errors = append(stats.Errors, err.Error())
+			continue
+		}
+
+		for name, depth := range depths {
+			stats.Queues[name] = depth
+		}
+	}
+
+	return stats
+}
+
+func (srv *adminStatsService) poolStats(ctx context.Context) (*DBPoolStats, error) {
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	sqlDB, err := sesh.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	dbStats := sqlDB.Stats()
+
+	return &DBPoolStats{
+		MaxOpenConnections: dbStats.MaxOpenConnections,
+		OpenConnections:    dbStats.OpenConnections,
+		InUse:              dbStats.InUse,
+		Idle:               dbStats.Idle,
+		WaitCount:          dbStats.WaitCount,
+		WaitDuration:       dbStats.WaitDuration.String(),
+		MaxIdleClosed:      dbStats.MaxIdleClosed,
+		MaxLifetimeClosed:  dbStats.MaxLifetimeClosed,
+	}, nil
+}
+
+// rowCounts counts the rows of every model, reusing counts younger than
+// RowCountTTL.
+func (srv *adminStatsService) rowCounts(ctx context.Context) (map[string]int64, error) {
+	srv.rowsMu.Lock()
+	defer srv.rowsMu.Unlock()
+
+	if srv.rows != nil && time.Since(srv.rowsCountedAt) < RowCountTTL {
+		return srv.rows, nil
+	}
+
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	rows := make(map[string]int64, len(srv.models))
+
+	for _, model := range srv.models {
+		stmt := &gorm.Statement{DB: sesh}
+		if err := stmt.Parse(model); err != nil {
+			return rows, err
+		}
+
+		var count int64
+		if err := sesh.Model(model).Count(&count).Error; err != nil {
+			return rows, err
+		}
+
+		rows[stmt.Schema.Table] = count
+	}
+
+	srv.rows = rows
+	srv.rowsCountedAt = time.Now()
+
+	return rows, nil
+}
+
+func (srv *adminStatsService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *adminStatsService) handleStats(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, srv.Stats(r.Context()))
+}
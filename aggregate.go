@@ -0,0 +1,239 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// SaveAggregate saves record together with the named child associations in
+// one transaction. New children are inserted, existing ones updated, and
+// has-many children missing from the record are deleted. Many-to-many
+// associations are replaced. Only the named associations are written, and
+// existing children must already belong to record, so an aggregate can't
+// take over another's rows.
+func (srv *dbService) SaveAggregate(ctx context.Context, record interface{}, associations ...string) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		saveResult := tx.Omit(clause.Associations).Save(record)
+		if saveResult.Error != nil {
+			return fmt.Errorf("failed to save aggregate root: %w", saveResult.Error)
+		}
+
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(record); err != nil {
+			return fmt.Errorf("failed to parse aggregate: %w", err)
+		}
+
+		rootValue := reflect.Indirect(reflect.ValueOf(record))
+
+		for _, name := range associations {
+			rel, ok := stmt.Schema.Relationships.Relations[name]
+			if !ok {
+				return fmt.Errorf("unknown association %q on %s", name, stmt.Schema.Name)
+			}
+
+			switch rel.Type {
+			case schema.HasOne, schema.HasMany:
+				if err := saveOwnedChildren(ctx, tx, rel, rootValue); err != nil {
+					return fmt.Errorf("failed to save association %s: %w", name, err)
+				}
+
+				if rel.Type == schema.HasMany {
+					if err := pruneHasMany(ctx, tx, rel, rootValue); err != nil {
+						return fmt.Errorf("failed to prune association %s: %w", name, err)
+					}
+				}
+			case schema.Many2Many:
+				children := rel.Field.ReflectValueOf(ctx, rootValue)
+
+				if err := checkChildrenExist(ctx, tx, rel, children); err != nil {
+					return err
+				}
+
+				if err := tx.Model(record).Association(name).Replace(children.Interface()); err != nil {
+					return fmt.Errorf("failed to replace association %s: %w", name, err)
+				}
+			default:
+				return fmt.Errorf("association %s of %s is not part of the aggregate", name, stmt.Schema.Name)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("save aggregate failed: %w", err)
+	}
+
+	return nil
+}
+
+// saveOwnedChildren links the has-one or has-many children of the root to
+// it and saves them, after checking that children with IDs are already the
+// root's.
+func saveOwnedChildren(ctx context.Context, tx *gorm.DB, rel *schema.Relationship, rootValue reflect.Value) error {
+	childPK := rel.FieldSchema.PrioritizedPrimaryField
+	if childPK == nil {
+		return fmt.Errorf("%s has no primary key", rel.FieldSchema.Name)
+	}
+
+	children := []reflect.Value{}
+	childIDs := []interface{}{}
+
+	eachRow(rel.Field.ReflectValueOf(ctx, rootValue), func(child reflect.Value) {
+		if !child.IsValid() {
+			return
+		}
+
+		children = append(children, child)
+
+		if childID, isZero := childPK.ValueOf(ctx, child); !isZero {
+			childIDs = append(childIDs, childID)
+		}
+	})
+
+	if len(childIDs) > 0 {
+		var owned int64
+
+		query := ownedChildrenQuery(ctx, tx, rel, rootValue).Where(fmt.Sprintf("%s IN ?", childPK.DBName), childIDs)
+		if err := query.Count(&owned).Error; err != nil {
+			return fmt.Errorf("failed to check %s: %w", rel.Name, err)
+		}
+
+		if owned != int64(len(childIDs)) {
+			return fmt.Errorf("%w: %s ids don't belong to this %s", ErrValidation, rel.Name, rel.Schema.Name)
+		}
+	}
+
+	rootTenant, tenantScoped := rootValue.Addr().Interface().(TenantModel)
+
+	for _, child := range children {
+		if childTenant, ok := child.Addr().Interface().(TenantModel); ok && tenantScoped {
+			childTenant.SetTenantID(rootTenant.GetTenantID())
+		}
+
+		for _, ref := range rel.References {
+			var value interface{} = ref.PrimaryValue
+			if ref.OwnPrimaryKey {
+				value, _ = ref.PrimaryKey.ValueOf(ctx, rootValue)
+			}
+
+			if err := ref.ForeignKey.Set(ctx, child, value); err != nil {
+				return fmt.Errorf("failed to link %s: %w", rel.Name, err)
+			}
+		}
+
+		if err := tx.Omit(clause.Associations).Save(child.Addr().Interface()).Error; err != nil {
+			return fmt.Errorf("failed to save %s: %w", rel.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkChildrenExist makes sure many-to-many children are existing records,
+// which are linked but never created or changed.
+func checkChildrenExist(ctx context.Context, tx *gorm.DB, rel *schema.Relationship, children reflect.Value) error {
+	childPK := rel.FieldSchema.PrioritizedPrimaryField
+	if childPK == nil {
+		return fmt.Errorf("%s has no primary key", rel.FieldSchema.Name)
+	}
+
+	unique := make(map[interface{}]bool)
+	missingID := false
+
+	eachRow(children, func(child reflect.Value) {
+		if !child.IsValid() {
+			return
+		}
+
+		childID, isZero := childPK.ValueOf(ctx, child)
+		if isZero {
+			missingID = true
+			return
+		}
+
+		unique[childID] = true
+	})
+
+	if missingID {
+		return fmt.Errorf("%w: %s must reference existing records", ErrValidation, rel.Name)
+	}
+
+	if len(unique) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, 0, len(unique))
+	for id := range unique {
+		ids = append(ids, id)
+	}
+
+	var found int64
+
+	query := tx.Model(reflect.New(rel.FieldSchema.ModelType).Interface()).Where(fmt.Sprintf("%s IN ?", childPK.DBName), ids)
+	if err := query.Count(&found).Error; err != nil {
+		return fmt.Errorf("failed to check %s: %w", rel.Name, err)
+	}
+
+	if found != int64(len(ids)) {
+		return fmt.Errorf("%w: unknown %s ids", ErrValidation, rel.Name)
+	}
+
+	return nil
+}
+
+// ownedChildrenQuery selects the rows of rel's model that belong to the
+// root.
+func ownedChildrenQuery(ctx context.Context, tx *gorm.DB, rel *schema.Relationship, rootValue reflect.Value) *gorm.DB {
+	query := tx.Model(reflect.New(rel.FieldSchema.ModelType).Interface())
+
+	for _, ref := range rel.References {
+		if ref.OwnPrimaryKey {
+			rootID, _ := ref.PrimaryKey.ValueOf(ctx, rootValue)
+			query = query.Where(fmt.Sprintf("%s = ?", ref.ForeignKey.DBName), rootID)
+		} else {
+			query = query.Where(fmt.Sprintf("%s = ?", ref.ForeignKey.DBName), ref.PrimaryValue)
+		}
+	}
+
+	return query
+}
+
+// pruneHasMany deletes the children of the root that are no longer present
+// in its association slice.
+func pruneHasMany(ctx context.Context, tx *gorm.DB, rel *schema.Relationship, rootValue reflect.Value) error {
+	childPK := rel.FieldSchema.PrioritizedPrimaryField
+	if childPK == nil {
+		return fmt.Errorf("%s has no primary key", rel.FieldSchema.Name)
+	}
+
+	query := ownedChildrenQuery(ctx, tx, rel, rootValue)
+
+	children := rel.Field.ReflectValueOf(ctx, rootValue)
+	keepIDs := []interface{}{}
+
+	for i := 0; i < children.Len(); i++ {
+		childID, isZero := childPK.ValueOf(ctx, reflect.Indirect(children.Index(i)))
+		if !isZero {
+			keepIDs = append(keepIDs, childID)
+		}
+	}
+
+	if len(keepIDs) > 0 {
+		query = query.Where(fmt.Sprintf("%s NOT IN ?", childPK.DBName), keepIDs)
+	}
+
+	return query.Delete(reflect.New(rel.FieldSchema.ModelType).Interface()).Error
+}
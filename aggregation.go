@@ -0,0 +1,277 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AggregateFunc is a SQL aggregate function.
+type AggregateFunc string
+
+const (
+	AggCount         AggregateFunc = "COUNT"
+	AggCountDistinct AggregateFunc = "COUNT DISTINCT"
+	AggSum           AggregateFunc = "SUM"
+	AggAvg           AggregateFunc = "AVG"
+	AggMin           AggregateFunc = "MIN"
+	AggMax           AggregateFunc = "MAX"
+)
+
+// Aggregation is one aggregate column of an AggregateSpec, selected as As.
+type Aggregation struct {
+	Func   AggregateFunc
+	Column string
+	As     string
+}
+
+// CountAll counts the rows in each group.
+func CountAll(as string) Aggregation {
+	return Aggregation{Func: AggCount, As: as}
+}
+
+func CountOf(column, as string) Aggregation {
+	return Aggregation{Func: AggCount, Column: column, As: as}
+}
+
+func CountDistinctOf(column, as string) Aggregation {
+	return Aggregation{Func: AggCountDistinct, Column: column, As: as}
+}
+
+func SumOf(column, as string) Aggregation {
+	return Aggregation{Func: AggSum, Column: column, As: as}
+}
+
+func AvgOf(column, as string) Aggregation {
+	return Aggregation{Func: AggAvg, Column: column, As: as}
+}
+
+func MinOf(column, as string) Aggregation {
+	return Aggregation{Func: AggMin, Column: column, As: as}
+}
+
+func MaxOf(column, as string) Aggregation {
+	return Aggregation{Func: AggMax, Column: column, As: as}
+}
+
+// expr returns the aggregate expression, such as SUM(amount).
+func (a Aggregation) expr() (string, error) {
+	if a.Column == "" {
+		if a.Func != AggCount {
+			return "", fmt.Errorf("%s requires a column", a.Func)
+		}
+
+		return "COUNT(*)", nil
+	}
+
+	if err := checkCondColumn(a.Column); err != nil {
+		return "", err
+	}
+
+	switch a.Func {
+	case AggCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT %s)", a.Column), nil
+	case AggCount, AggSum, AggAvg, AggMin, AggMax:
+		return fmt.Sprintf("%s(%s)", a.Func, a.Column), nil
+	default:
+		return "", fmt.Errorf("unknown aggregate function %q", a.Func)
+	}
+}
+
+// Eq, Gt, Gte, Lt, and Lte compare the aggregate for use in HAVING.
+func (a Aggregation) Eq(value interface{}) Cond {
+	return a.compare("=", value)
+}
+
+func (a Aggregation) Gt(value interface{}) Cond {
+	return a.compare(">", value)
+}
+
+func (a Aggregation) Gte(value interface{}) Cond {
+	return a.compare(">=", value)
+}
+
+func (a Aggregation) Lt(value interface{}) Cond {
+	return a.compare("<", value)
+}
+
+func (a Aggregation) Lte(value interface{}) Cond {
+	return a.compare("<=", value)
+}
+
+func (a Aggregation) compare(operator string, value interface{}) Cond {
+	expr, err := a.expr()
+	if err != nil {
+		return Cond{err: err}
+	}
+
+	return Cond{sql: fmt.Sprintf("%s %s ?", expr, operator), args: []interface{}{value}}
+}
+
+// AggregateSpec describes a GROUP BY query. Where filters rows before
+// grouping and Having filters groups, typically with the Aggregation
+// comparison helpers. OrderBy may name group columns or aggregate aliases.
+// Columns may be qualified with their table when the repository joins
+// tables.
+type AggregateSpec struct {
+	GroupBy      []string
+	Aggregations []Aggregation
+	Where        Cond
+	Having       Cond
+	OrderBy      []SortKey
+	Limit        int
+}
+
+// selects returns the group columns followed by the aliased aggregates.
+func (spec AggregateSpec) selects() ([]string, error) {
+	if len(spec.Aggregations) == 0 {
+		return nil, fmt.Errorf("aggregate queries need at least one aggregation")
+	}
+
+	selects := make([]string, 0, len(spec.GroupBy)+len(spec.Aggregations))
+
+	for _, column := range spec.GroupBy {
+		if err := checkCondColumn(column); err != nil {
+			return nil, err
+		}
+
+		selects = append(selects, column)
+	}
+
+	for _, aggregation := range spec.Aggregations {
+		expr, err := aggregation.expr()
+		if err != nil {
+			return nil, err
+		}
+
+		alias := aggregation.As
+		if alias == "" {
+			alias = strings.ToLower(strings.ReplaceAll(string(aggregation.Func), " ", "_"))
+			if aggregation.Column != "" {
+				alias += "_" + aggregation.Column[strings.LastIndex(aggregation.Column, ".")+1:]
+			}
+		}
+
+		if !isValidColumnName(alias) {
+			return nil, fmt.Errorf("invalid aggregate alias %q", alias)
+		}
+
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, alias))
+	}
+
+	return selects, nil
+}
+
+// Aggregate runs spec against model's table, scanning one row per group
+// into dest, a pointer to a slice of structs or maps.
+func (srv *dbService) Aggregate(
+	ctx context.Context,
+	model interface{},
+	dest interface{},
+	joins []string,
+	spec AggregateSpec,
+	query interface{},
+	args ...interface{},
+) error {
+	selects, err := spec.selects()
+	if err != nil {
+		return err
+	}
+
+	having, havingArgs, err := spec.Having.Build()
+	if err != nil {
+		return err
+	}
+
+	order, err := orderClause(spec.OrderBy, func(name string) string { return name }, srv.Dialect())
+	if err != nil {
+		return err
+	}
+
+	sesh, cancel := srv.getReadSession(ctx)
+	defer cancel()
+
+	sesh = sesh.Model(model).Select(strings.Join(selects, ", "))
+
+	for _, join := range joins {
+		sesh = sesh.Joins(join)
+	}
+
+	if query != nil {
+		sesh = sesh.Where(query, args...)
+	}
+
+	for _, column := range spec.GroupBy {
+		sesh = sesh.Group(column)
+	}
+
+	if having != "" {
+		sesh = sesh.Having(having, havingArgs...)
+	}
+
+	if order != "" {
+		sesh = sesh.Order(order)
+	}
+
+	if spec.Limit > 0 {
+		sesh = sesh.Limit(spec.Limit)
+	}
+
+	if err := sesh.Scan(dest).Error; err != nil {
+		return fmt.Errorf("aggregate failed: %w", err)
+	}
+
+	return nil
+}
+
+// Aggregate runs spec over the items visible to ctx, scanning the groups
+// into dest.
+func (r *repository[M, K]) Aggregate(ctx context.Context, spec AggregateSpec, dest interface{}) error {
+	query, args, err := spec.Where.Build()
+	if err != nil {
+		return err
+	}
+
+	query, args, err = r.scopeQuery(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	query, args, err = r.filterQuery(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	var where interface{}
+	if query != "" {
+		where = query
+	}
+
+	if err := r.db.Aggregate(ctx, new(M), dest, r.joinTables, spec, where, args...); err != nil {
+		return fmt.Errorf("failed to aggregate items: %w", err)
+	}
+
+	return nil
+}
+
+// Aggregate runs spec with repo and returns the groups as R, a struct whose
+// fields match the group columns and aggregate aliases:
+//
+//	type StatusCount struct {
+//		Status string
+//		Total  int64
+//	}
+//
+//	counts, err := mochi.Aggregate[StatusCount](ctx, repo, mochi.AggregateSpec{
+//		GroupBy:      []string{"status"},
+//		Aggregations: []mochi.Aggregation{mochi.CountAll("total")},
+//	})
+func Aggregate[R any, M Model[K], K comparable](ctx context.Context, repo Repository[M, K], spec AggregateSpec) ([]R, error) {
+	var results []R
+
+	if err := repo.Aggregate(ctx, spec, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,293 @@
+package mochi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/fx"
+)
+
+const (
+	errorRateBuckets = 10
+)
+
+// ErrorRateAlert describes a route whose 5xx rate crossed the threshold, or
+// recovered below it when Resolved is set.
+type ErrorRateAlert struct {
+	Route     string        `json:"route"`
+	Window    time.Duration `json:"window"`
+	Requests  int64         `json:"requests"`
+	Errors    int64         `json:"errors"`
+	Rate      float64       `json:"rate"`
+	Threshold float64       `json:"threshold"`
+	Resolved  bool          `json:"resolved"`
+	At        time.Time     `json:"at"`
+}
+
+func (a ErrorRateAlert) String() string {
+	if a.Resolved {
+		return fmt.Sprintf("Resolved: %s error rate is %.1f%% over the last %s", a.Route, a.Rate*100, a.Window)
+	}
+
+	return fmt.Sprintf(
+		"%s error rate is %.1f%% (%d of %d requests) over the last %s, above %.1f%%",
+		a.Route, a.Rate*100, a.Errors, a.Requests, a.Window, a.Threshold*100,
+	)
+}
+
+// AlertHook delivers error rate alerts, for example to Slack, a webhook, or
+// a pager.
+type AlertHook interface {
+	Alert(ctx context.Context, alert ErrorRateAlert) error
+}
+
+type AlertHookFunc func(ctx context.Context, alert ErrorRateAlert) error
+
+func (f AlertHookFunc) Alert(ctx context.Context, alert ErrorRateAlert) error {
+	return f(ctx, alert)
+}
+
+type webhookAlertHook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAlertHook posts alerts as JSON. The payload includes a text
+// field so it can be used directly with Slack incoming webhooks.
+func NewWebhookAlertHook(url string, client *http.Client) AlertHook {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &webhookAlertHook{url: url, client: client}
+}
+
+func (h *webhookAlertHook) Alert(ctx context.Context, alert ErrorRateAlert) error {
+	payload, err := json.Marshal(struct {
+		Text string `json:"text"`
+		ErrorRateAlert
+	}{Text: alert.String(), ErrorRateAlert: alert})
+
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ErrorRateConfig controls when the monitor alerts. Routes need at least
+// MinRequests in the window before their rate is considered, and a route
+// alerts at most once per Cooldown.
+type ErrorRateConfig struct {
+	Window      time.Duration
+	Threshold   float64
+	MinRequests int64
+	Cooldown    time.Duration
+}
+
+var DefaultErrorRateConfig = ErrorRateConfig{
+	Window:      5 * time.Minute,
+	Threshold:   0.05,
+	MinRequests: 20,
+	Cooldown:    15 * time.Minute,
+}
+
+type errorRateBucket struct {
+	start    time.Time
+	requests int64
+	errors   int64
+}
+
+type routeErrorRate struct {
+	buckets   [errorRateBuckets]errorRateBucket
+	alerting  bool
+	lastAlert time.Time
+}
+
+// ErrorRateMonitor tracks rolling 5xx rates per route in process and calls
+// its AlertHook when a route crosses the threshold and when it recovers.
+type ErrorRateMonitor struct {
+	config ErrorRateConfig
+	hook   AlertHook
+	logger LoggerService
+
+	mu     sync.Mutex
+	routes map[string]*routeErrorRate
+}
+
+func NewErrorRateMonitor(logger LoggerService, hook AlertHook, config ErrorRateConfig) *ErrorRateMonitor {
+	if config.Window <= 0 {
+		config.Window = DefaultErrorRateConfig.Window
+	}
+
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultErrorRateConfig.Threshold
+	}
+
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultErrorRateConfig.Cooldown
+	}
+
+	return &ErrorRateMonitor{
+		config: config,
+		hook:   hook,
+		logger: logger,
+		routes: make(map[string]*routeErrorRate),
+	}
+}
+
+func (m *ErrorRateMonitor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.Observe(fmt.Sprintf("%s %s", r.Method, route), status >= http.StatusInternalServerError)
+	})
+}
+
+// Observe records a request outcome for route and alerts if its state
+// changes.
+func (m *ErrorRateMonitor) Observe(route string, failed bool) {
+	now := time.Now()
+
+	m.mu.Lock()
+
+	stats, ok := m.routes[route]
+	if !ok {
+		stats = &routeErrorRate{}
+		m.routes[route] = stats
+	}
+
+	bucketSize := m.config.Window / errorRateBuckets
+	bucketStart := now.Truncate(bucketSize)
+	bucket := &stats.buckets[(bucketStart.UnixNano()/int64(bucketSize))%errorRateBuckets]
+
+	if !bucket.start.Equal(bucketStart) {
+		*bucket = errorRateBucket{start: bucketStart}
+	}
+
+	bucket.requests++
+	if failed {
+		bucket.errors++
+	}
+
+	alert, send := m.evaluate(route, stats, now)
+
+	m.mu.Unlock()
+
+	if send {
+		go m.send(alert)
+	}
+}
+
+func (m *ErrorRateMonitor) evaluate(route string, stats *routeErrorRate, now time.Time) (ErrorRateAlert, bool) {
+	alert := ErrorRateAlert{
+		Route:     route,
+		Window:    m.config.Window,
+		Threshold: m.config.Threshold,
+		At:        now,
+	}
+
+	cutoff := now.Add(-m.config.Window)
+	for _, bucket := range stats.buckets {
+		if bucket.start.After(cutoff) {
+			alert.Requests += bucket.requests
+			alert.Errors += bucket.errors
+		}
+	}
+
+	if alert.Requests < m.config.MinRequests || alert.Requests == 0 {
+		return alert, false
+	}
+
+	alert.Rate = float64(alert.Errors) / float64(alert.Requests)
+
+	if alert.Rate >= m.config.Threshold {
+		if stats.alerting && now.Sub(stats.lastAlert) < m.config.Cooldown {
+			return alert, false
+		}
+
+		stats.alerting = true
+		stats.lastAlert = now
+
+		return alert, true
+	}
+
+	if stats.alerting {
+		stats.alerting = false
+		alert.Resolved = true
+
+		return alert, true
+	}
+
+	return alert, false
+}
+
+func (m *ErrorRateMonitor) send(alert ErrorRateAlert) {
+	m.logger.Warn("Error rate alert", "route", alert.Route, "rate", alert.Rate, "resolved", alert.Resolved)
+
+	if m.hook == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := m.hook.Alert(ctx, alert); err != nil {
+		m.logger.Error("Failed to deliver error rate alert", "route", alert.Route, "error", err)
+	}
+}
+
+type ErrorRateMiddlewareParams struct {
+	fx.In
+
+	Config *ErrorRateConfig `optional:"true"`
+	Hook   AlertHook        `optional:"true"`
+	Logger LoggerService
+}
+
+// NewErrorRateMiddleware builds a global ErrorRateMonitor from the optional
+// ErrorRateConfig and AlertHook. Register it with AsRouterMiddleware.
+// Without a hook, alerts are only logged.
+func NewErrorRateMiddleware(params ErrorRateMiddlewareParams) func(http.Handler) http.Handler {
+	config := DefaultErrorRateConfig
+	if params.Config != nil {
+		config = *params.Config
+	}
+
+	return NewErrorRateMonitor(params.Logger, params.Hook, config).Handler
+}
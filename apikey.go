@@ -0,0 +1,215 @@
+package mochi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	APIKeyHeaderName = "X-API-Key"
+	APIKeyPrefix     = "mk"
+)
+
+// APIKey is a hashed server-to-server credential owned by a user. Add it to
+// the app's ModelList when using the APIKeyService.
+type APIKey struct {
+	ID         uint `gorm:"primaryKey"`
+	UserID     uint `gorm:"index"`
+	Name       string
+	Prefix     string `gorm:"uniqueIndex"`
+	Hash       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (k *APIKey) GetID() uint {
+	return k.ID
+}
+
+func (k *APIKey) GetUserID() uint {
+	return k.UserID
+}
+
+type APIKeyService interface {
+	CreateKey(ctx context.Context, userID uint, name string) (string, *APIKey, error)
+	RotateKey(ctx context.Context, keyID uint) (string, *APIKey, error)
+	RevokeKey(ctx context.Context, keyID uint) error
+	ListKeys(ctx context.Context, userID uint) ([]*APIKey, error)
+	Authenticate(ctx context.Context, key string) (User, error)
+	APIKeyAuth() func(http.Handler) http.Handler
+}
+
+type APIKeyServiceParams struct {
+	fx.In
+
+	DB          DBService
+	Logger      LoggerService
+	UserService UserService
+}
+
+type APIKeyServiceResult struct {
+	fx.Out
+
+	APIKeyService APIKeyService
+}
+
+type apiKeyService struct {
+	logger      LoggerService
+	repo        Repository[*APIKey, uint]
+	userService UserService
+}
+
+func NewAPIKeyService(params APIKeyServiceParams) (APIKeyServiceResult, error) {
+	srv := &apiKeyService{
+		logger:      params.Logger,
+		repo:        NewRepository(params.DB, params.Logger, WithTableName[*APIKey]("api_keys")),
+		userService: params.UserService,
+	}
+
+	return APIKeyServiceResult{APIKeyService: srv}, nil
+}
+
+// CreateKey issues a new key for the user. The plaintext key is only
+// returned here; just its hash is stored.
+func (srv *apiKeyService) CreateKey(ctx context.Context, userID uint, name string) (string, *APIKey, error) {
+	prefix, err := randomHex(4)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	plaintext := fmt.Sprintf("%s_%s_%s", APIKeyPrefix, prefix, base64.RawURLEncoding.EncodeToString(secretBytes))
+
+	key := &APIKey{
+		UserID: userID,
+		Name:   name,
+		Prefix: prefix,
+		Hash:   hashAPIKey(plaintext),
+	}
+
+	if err := srv.repo.CreateOne(ctx, key); err != nil {
+		return "", nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	srv.logger.WithContext(ctx).Info("Created API key", "user", userID, "key", key.ID, "prefix", prefix)
+
+	return plaintext, key, nil
+}
+
+// RotateKey revokes the key and issues a replacement with the same name.
+func (srv *apiKeyService) RotateKey(ctx context.Context, keyID uint) (string, *APIKey, error) {
+	key, err := srv.repo.FindOneByID(ctx, keyID, "revoked_at IS NULL")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to find api key: %w", err)
+	}
+
+	if err := srv.RevokeKey(ctx, keyID); err != nil {
+		return "", nil, err
+	}
+
+	return srv.CreateKey(ctx, key.UserID, key.Name)
+}
+
+func (srv *apiKeyService) RevokeKey(ctx context.Context, keyID uint) error {
+	now := time.Now()
+
+	_, err := srv.repo.UpdateOne(ctx, keyID, &APIKey{ID: keyID, RevokedAt: &now})
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	srv.logger.WithContext(ctx).Info("Revoked API key", "key", keyID)
+
+	return nil
+}
+
+func (srv *apiKeyService) ListKeys(ctx context.Context, userID uint) ([]*APIKey, error) {
+	keys, err := srv.repo.FindManyByUser(ctx, userID, "revoked_at IS NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (srv *apiKeyService) Authenticate(ctx context.Context, plaintext string) (User, error) {
+	parts := strings.SplitN(plaintext, "_", 3)
+	if len(parts) != 3 || parts[0] != APIKeyPrefix {
+		return nil, fmt.Errorf("malformed api key")
+	}
+
+	key, err := srv.repo.FindOne(ctx, "prefix = ? AND revoked_at IS NULL", parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(plaintext))) != 1 {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	user, err := srv.userService.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key user: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := srv.repo.UpdateOne(ctx, key.ID, &APIKey{ID: key.ID, LastUsedAt: &now}); err != nil {
+		srv.logger.WithContext(ctx).Warn("Failed to record api key usage", "key", key.ID, "error", err)
+	}
+
+	return user, nil
+}
+
+// APIKeyAuth authenticates requests carrying an X-API-Key header and stores
+// the key's user in the context, where AuthRequired and controllers pick it
+// up. Requests without the header pass through untouched.
+func (srv *apiKeyService) APIKeyAuth() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plaintext := r.Header.Get(APIKeyHeaderName)
+			if plaintext == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := srv.Authenticate(r.Context(), plaintext)
+			if err != nil {
+				render.Render(w, r, ErrUnauthorized(err))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,564 @@
+package mochi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	ArchiveCheckInterval  = time.Hour
+	ArchiveTableSuffix    = "_archive"
+	ArchiveStoragePrefix  = "archive"
+	DefaultArchiveBatch   = 500
+	ArchivedRowsMetric    = "mochi_archived_rows_total"
+	ArchiveFailuresMetric = "mochi_archive_failures_total"
+)
+
+// ArchiveModel is implemented by models whose old rows are moved out of the
+// live table.
+type ArchiveModel interface {
+	ArchivePolicy() ArchivePolicy
+}
+
+// ArchivePolicy archives rows whose Column, created_at by default, is older
+// than After, BatchSize rows at a time.
+//
+// Rows go to the <table>_archive table, which keeps the model's schema and
+// can be served read-only with WithArchiveRoutes. With Storage set, they are
+// written as JSON Lines through the StorageService instead; each object is
+// written before its rows are deleted, so a failed delete can archive rows
+// twice but never loses them.
+type ArchivePolicy struct {
+	After     time.Duration
+	Column    string
+	BatchSize int
+	Storage   bool
+}
+
+type ArchiveReport struct {
+	Table    string    `json:"table"`
+	Cutoff   time.Time `json:"cutoff"`
+	Archived int64     `json:"archived"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (rep *ArchiveReport) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// ArchiveService archives the rows of the ArchiveModels in the ModelList
+// every ArchiveCheckInterval. GetRouter serves admin runs at POST /run.
+type ArchiveService interface {
+	Run(ctx context.Context) ([]ArchiveReport, error)
+	FindArchived(ctx context.Context, dest interface{}, query interface{}, args ...interface{}) error
+
+	GetRouter() *chi.Mux
+}
+
+type ArchiveServiceParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Auth      AuthService
+	DB        DBService
+	Logger    LoggerService
+	Metrics   MetricsService
+	Models    ModelList
+	Storage   StorageService `optional:"true"`
+	Shutdown  *Shutdown      `optional:"true"`
+}
+
+type ArchiveServiceResult struct {
+	fx.Out
+
+	ArchiveService ArchiveService
+}
+
+type archiveTarget struct {
+	table  string
+	model  reflect.Type
+	policy ArchivePolicy
+}
+
+type archiveService struct {
+	db      DBService
+	logger  LoggerService
+	metrics MetricsService
+	storage StorageService
+	targets []archiveTarget
+	Router  *chi.Mux
+
+	running sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewArchiveService(params ArchiveServiceParams) (ArchiveServiceResult, error) {
+	srv := &archiveService{
+		db:      params.DB,
+		logger:  params.Logger,
+		metrics: params.Metrics,
+		storage: params.Storage,
+	}
+
+	var schemaCache sync.Map
+
+	for _, model := range params.Models {
+		archived, ok := model.(ArchiveModel)
+		if !ok {
+			continue
+		}
+
+		policy := archived.ArchivePolicy()
+		if policy.After <= 0 {
+			return ArchiveServiceResult{}, fmt.Errorf("archive policy of %T needs a positive age", model)
+		}
+
+		if policy.Column == "" {
+			policy.Column = DefaultRetentionColumn
+		}
+
+		if !isValidColumnName(policy.Column) {
+			return ArchiveServiceResult{}, fmt.Errorf("invalid archive column %q on %T", policy.Column, model)
+		}
+
+		if policy.BatchSize <= 0 {
+			policy.BatchSize = DefaultArchiveBatch
+		}
+
+		if policy.Storage && params.Storage == nil {
+			return ArchiveServiceResult{}, fmt.Errorf("archive policy of %T needs a StorageService", model)
+		}
+
+		modelSchema, err := schema.Parse(model, &schemaCache, schema.NamingStrategy{})
+		if err != nil {
+			return ArchiveServiceResult{}, fmt.Errorf("failed to parse archive model %T: %w", model, err)
+		}
+
+		srv.targets = append(srv.targets, archiveTarget{
+			table:  modelSchema.Table,
+			model:  modelSchema.ModelType,
+			policy: policy,
+		})
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Post("/run", srv.handleRun)
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := srv.migrate(ctx); err != nil {
+				return err
+			}
+
+			srv.stop = make(chan struct{})
+			srv.done = make(chan struct{})
+
+			go srv.run()
+
+			return nil
+		},
+	})
+
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "archive",
+		Stage: StageWorkers,
+		Stop: func(ctx context.Context) error {
+			close(srv.stop)
+
+			select {
+			case <-srv.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+
+	return ArchiveServiceResult{ArchiveService: srv}, nil
+}
+
+// migrate creates the archive tables, mirroring their live tables.
+func (srv *archiveService) migrate(ctx context.Context) error {
+	if srv.db.IsReadOnly() {
+		return nil
+	}
+
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	for _, target := range srv.targets {
+		if target.policy.Storage {
+			continue
+		}
+
+		err := sesh.Table(archiveTable(target.table)).AutoMigrate(reflect.New(target.model).Interface())
+		if err != nil {
+			return fmt.Errorf("failed to migrate archive of %s: %w", target.table, err)
+		}
+	}
+
+	return nil
+}
+
+// Run archives expired rows of every table. A failing table doesn't stop
+// the others; the first error is returned with all reports.
+func (srv *archiveService) Run(ctx context.Context) ([]ArchiveReport, error) {
+	if srv.db.IsReadOnly() {
+		return nil, ErrReadOnly
+	}
+
+	srv.running.Lock()
+	defer srv.running.Unlock()
+
+	reports := make([]ArchiveReport, 0, len(srv.targets))
+
+	var firstErr error
+
+	for _, target := range srv.targets {
+		report, err := srv.archiveTarget(ctx, target)
+		if err != nil {
+			report.Error = err.Error()
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, firstErr
+}
+
+// FindArchived loads rows from the archive table of dest's model, read-only.
+// Tenant scoped models are limited to the context tenant like live rows.
+func (srv *archiveService) FindArchived(ctx context.Context, dest interface{}, query interface{}, args ...interface{}) error {
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	stmt := &gorm.Statement{DB: sesh}
+	if err := stmt.Parse(dest); err != nil {
+		return fmt.Errorf("failed to parse archived model: %w", err)
+	}
+
+	sesh = sesh.Table(archiveTable(stmt.Schema.Table))
+
+	if _, tenantScoped := reflect.New(stmt.Schema.ModelType).Interface().(TenantModel); tenantScoped {
+		tenantQuery, tenantArgs, err := tenantScope(ctx, "tenant_id")
+		if err != nil {
+			return fmt.Errorf("failed to scope archived rows to tenant: %w", err)
+		}
+
+		if tenantQuery != "" {
+			sesh = sesh.Where(tenantQuery, tenantArgs...)
+		}
+	}
+
+	if query != nil {
+		sesh = sesh.Where(query, args...)
+	}
+
+	if order := orderClauseFromCtx(ctx); order != "" {
+		sesh = sesh.Order(order)
+	}
+
+	if limit := resultLimitFromCtx(ctx); limit > 0 {
+		sesh = sesh.Limit(limit)
+	}
+
+	if err := sesh.Find(dest).Error; err != nil {
+		return fmt.Errorf("failed to find archived rows: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *archiveService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *archiveService) archiveTarget(ctx context.Context, target archiveTarget) (ArchiveReport, error) {
+	started := time.Now()
+	cutoff := started.Add(-target.policy.After)
+	labels := MetricLabels{"table": target.table}
+
+	report := ArchiveReport{Table: target.table, Cutoff: cutoff}
+
+	defer func() {
+		report.Duration = time.Since(started).String()
+	}()
+
+	for batch := 0; ; batch++ {
+		archived, err := srv.archiveBatch(ctx, target, cutoff, started, batch)
+		report.Archived += archived
+		srv.metrics.IncCounter(ArchivedRowsMetric, float64(archived), labels)
+
+		if err != nil {
+			srv.metrics.IncCounter(ArchiveFailuresMetric, 1, labels)
+			srv.logger.Error("Failed to archive rows", "table", target.table, "archived", report.Archived, "error", err)
+
+			return report, fmt.Errorf("failed to archive %s: %w", target.table, err)
+		}
+
+		if archived < int64(target.policy.BatchSize) {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+
+	if report.Archived > 0 {
+		srv.logger.Info("Archived rows", "table", target.table, "archived", report.Archived, "cutoff", cutoff)
+	}
+
+	return report, nil
+}
+
+// archiveBatch moves up to BatchSize expired rows in one transaction.
+func (srv *archiveService) archiveBatch(
+	ctx context.Context,
+	target archiveTarget,
+	cutoff time.Time,
+	runStarted time.Time,
+	batch int,
+) (int64, error) {
+	sesh, cancel := srv.db.GetSession(WithQueryTimeout(ctx, RetentionBatchTimeout))
+	defer cancel()
+
+	var archived int64
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		instance := reflect.New(target.model).Interface()
+
+		var rows []map[string]interface{}
+
+		err := tx.Unscoped().
+			Model(instance).
+			Where(fmt.Sprintf("%s < ?", target.policy.Column), cutoff).
+			Order("id").
+			Limit(target.policy.BatchSize).
+			Find(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to select rows: %w", err)
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if target.policy.Storage {
+			key := fmt.Sprintf("%s/%s/%s-%04d.jsonl", ArchiveStoragePrefix, target.table, runStarted.UTC().Format("20060102T150405Z"), batch)
+			if err := srv.putRows(ctx, key, rows); err != nil {
+				return err
+			}
+		} else if err := tx.Table(archiveTable(target.table)).CreateInBatches(&rows, CreateBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to copy rows: %w", err)
+		}
+
+		ids := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row["id"])
+		}
+
+		deleteResult := tx.Unscoped().Where("id IN ?", ids).Delete(instance)
+		if deleteResult.Error != nil {
+			return fmt.Errorf("failed to delete archived rows: %w", deleteResult.Error)
+		}
+
+		archived = int64(len(rows))
+
+		return nil
+	})
+
+	return archived, err
+}
+
+func (srv *archiveService) putRows(ctx context.Context, key string, rows []map[string]interface{}) error {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode archived row: %w", err)
+		}
+	}
+
+	if err := srv.storage.Put(ctx, key, &buf); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (srv *archiveService) run() {
+	defer close(srv.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-srv.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(ArchiveCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-srv.stop:
+			return
+		case <-ticker.C:
+			if srv.db.IsReadOnly() {
+				continue
+			}
+
+			if _, err := srv.Run(ctx); err != nil {
+				srv.logger.Error("Archive run failed", "error", err)
+			}
+		}
+	}
+}
+
+func (srv *archiveService) handleRun(w http.ResponseWriter, r *http.Request) {
+	reports, err := srv.Run(r.Context())
+	if err != nil && reports == nil {
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	if err != nil {
+		srv.logger.Error("Archive run failed", "error", err)
+	}
+
+	respList := make([]render.Renderer, 0, len(reports))
+	for i := range reports {
+		respList = append(respList, &reports[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
+
+func archiveTable(table string) string {
+	return table + ArchiveTableSuffix
+}
+
+// handleArchiveList lists the archived items the user may access, or every
+// archived item of the tenant for admins. Like the list route it's sorted
+// with the sort parameter and capped at DefaultMaxResults.
+func (c *controller[M, K]) handleArchiveList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var query interface{}
+	args := []interface{}{}
+
+	user, err := c.auth.GetUserFromCtx(ctx)
+	if err != nil {
+		c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+		return
+	}
+
+	// owned models are narrowed in SQL, the access func has the final say
+	if _, owned := newModelPtr[M]().(OwnedModel); owned && !user.IsAdmin() {
+		query = "user_id = ?"
+		args = append(args, user.GetID())
+	}
+
+	ctx, err = c.sortedContext(ctx, r)
+	if err != nil {
+		c.errorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	// one extra row tells a full page apart from a truncated one
+	ctx = withResultLimit(ctx, DefaultMaxResults+1)
+
+	var items []M
+	if err := c.archive.FindArchived(ctx, &items, query, args...); err != nil {
+		c.renderError(w, r, "failed to list archived items", err)
+		return
+	}
+
+	if len(items) > DefaultMaxResults {
+		items = items[:DefaultMaxResults]
+		setTruncationHeaders(w, &ResultInfo{Truncated: true, Limit: DefaultMaxResults})
+	}
+
+	respList := make([]render.Renderer, 0, len(items))
+	for _, item := range items {
+		if !user.IsAdmin() && c.userAccessFunc(user, item) != nil {
+			continue
+		}
+
+		respList = append(respList, c.dto(ctx, item))
+	}
+
+	if err := renderList(w, r, respList); err != nil {
+		c.renderError(w, r, "failed to render archived items", err)
+	}
+}
+
+// handleArchiveGet serves one archived item to its owner or an admin.
+func (c *controller[M, K]) handleArchiveGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := c.auth.GetUserFromCtx(ctx)
+	if err != nil {
+		c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+		return
+	}
+
+	itemID, err := c.idParser(chi.URLParam(r, c.idParamName))
+	if err != nil {
+		c.errorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	var items []M
+	if err := c.archive.FindArchived(ctx, &items, "id = ?", itemID); err != nil {
+		c.renderError(w, r, "failed to get archived item", err)
+		return
+	}
+
+	if len(items) == 0 {
+		c.errorHandler(w, r, ErrRecordNotFound)
+		return
+	}
+
+	if !user.IsAdmin() {
+		if err := c.userAccessFunc(user, items[0]); err != nil {
+			c.errorHandler(w, r, ErrRecordNotFound)
+			return
+		}
+	}
+
+	render.Render(w, r, c.dto(ctx, items[0]))
+}
+
+// WithArchiveRoutes serves archived items read-only at GET /archive and
+// GET /archive/{id}. The model's ArchivePolicy must use an archive table.
+func WithArchiveRoutes[M Resource[K], K comparable](archive ArchiveService) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.archive = archive
+	}
+}
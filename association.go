@@ -0,0 +1,376 @@
+package mochi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+)
+
+// AssociationMode selects how ModifyAssociation changes an association.
+type AssociationMode string
+
+const (
+	AssociationAppend  AssociationMode = "append"
+	AssociationReplace AssociationMode = "replace"
+	AssociationRemove  AssociationMode = "remove"
+)
+
+// ModifyAssociation appends values to, replaces, or removes values from the
+// named association of record in one transaction. For many-to-many
+// associations only the join table rows change; removed has-many children
+// have their foreign key set to NULL. Replacing with no values clears the
+// association.
+func (srv *dbService) ModifyAssociation(
+	ctx context.Context,
+	record interface{},
+	name string,
+	mode AssociationMode,
+	values ...interface{},
+) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		association := tx.Model(record).Association(name)
+		if association.Error != nil {
+			return association.Error
+		}
+
+		switch mode {
+		case AssociationAppend:
+			return association.Append(values...)
+		case AssociationReplace:
+			return association.Replace(values...)
+		case AssociationRemove:
+			return association.Delete(values...)
+		default:
+			return fmt.Errorf("unknown association mode %q", mode)
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("modify association failed: %w", err)
+	}
+
+	return nil
+}
+
+// FindAssociationTargets loads the records of the named association's model
+// with the given primary keys, returning a pointer to a slice of them ready
+// to pass to ModifyAssociation. Tenant scoped targets are limited to the
+// context tenant. IDs without a record are an ErrValidation.
+func (srv *dbService) FindAssociationTargets(ctx context.Context, model interface{}, name string, ids []interface{}) (interface{}, error) {
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	stmt := &gorm.Statement{DB: sesh}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+
+	rel, ok := stmt.Schema.Relationships.Relations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown association %q on %s", name, stmt.Schema.Name)
+	}
+
+	childPK := rel.FieldSchema.PrioritizedPrimaryField
+	if childPK == nil {
+		return nil, fmt.Errorf("%s has no primary key", rel.FieldSchema.Name)
+	}
+
+	targets := reflect.New(reflect.SliceOf(reflect.PointerTo(rel.FieldSchema.ModelType)))
+
+	if len(ids) == 0 {
+		return targets.Interface(), nil
+	}
+
+	unique := make(map[interface{}]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	query := sesh.Where(fmt.Sprintf("%s IN ?", childPK.DBName), ids)
+
+	if _, tenantScoped := reflect.New(rel.FieldSchema.ModelType).Interface().(TenantModel); tenantScoped {
+		tenantQuery, tenantArgs, err := tenantScope(ctx, "tenant_id")
+		if err != nil {
+			return nil, fmt.Errorf("failed to scope %s to tenant: %w", name, err)
+		}
+
+		if tenantQuery != "" {
+			query = query.Where(tenantQuery, tenantArgs...)
+		}
+	}
+
+	query = query.Find(targets.Interface())
+	if query.Error != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", name, query.Error)
+	}
+
+	if targets.Elem().Len() != len(unique) {
+		return nil, fmt.Errorf("%w: unknown %s ids", ErrValidation, name)
+	}
+
+	return targets.Interface(), nil
+}
+
+func (r *repository[M, K]) AppendAssociation(ctx context.Context, item M, name string, values ...interface{}) error {
+	return r.modifyAssociation(ctx, item, name, AssociationAppend, values)
+}
+
+func (r *repository[M, K]) ReplaceAssociation(ctx context.Context, item M, name string, values ...interface{}) error {
+	return r.modifyAssociation(ctx, item, name, AssociationReplace, values)
+}
+
+func (r *repository[M, K]) RemoveAssociation(ctx context.Context, item M, name string, values ...interface{}) error {
+	return r.modifyAssociation(ctx, item, name, AssociationRemove, values)
+}
+
+func (r *repository[M, K]) modifyAssociation(
+	ctx context.Context,
+	item M,
+	name string,
+	mode AssociationMode,
+	values []interface{},
+) error {
+	if r.viewBacked {
+		return ErrViewNotWritable
+	}
+
+	if err := r.checkTenantAccess(ctx, item.GetID()); err != nil {
+		return err
+	}
+
+	if err := r.db.ModifyAssociation(ctx, item, name, mode, values...); err != nil {
+		return fmt.Errorf("failed to %s association %s: %w", mode, name, err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Modified association", "item", item.GetID(), "table", r.tableName, "association", name, "mode", mode)
+
+	return nil
+}
+
+func (r *repository[M, K]) FindAssociationTargets(ctx context.Context, name string, ids []interface{}) (interface{}, error) {
+	targets, err := r.db.FindAssociationTargets(ctx, newModelPtr[M](), name, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find association targets: %w", err)
+	}
+
+	return targets, nil
+}
+
+// AssociationService changes an item's association by the IDs of the
+// associated records, for controllers using WithAssociationRoutes. The
+// default Service implements it.
+type AssociationService[M Resource[K], K comparable] interface {
+	// ModifyAssociation rejects the IDs of records access refuses, unless
+	// access is nil.
+	ModifyAssociation(
+		ctx context.Context,
+		itemID K,
+		name string,
+		mode AssociationMode,
+		ids []interface{},
+		access func(target interface{}) error,
+	) (M, error)
+}
+
+// ModifyAssociation changes the named association of the item and returns
+// the item with the association preloaded.
+func (s *service[M, K]) ModifyAssociation(
+	ctx context.Context,
+	itemID K,
+	name string,
+	mode AssociationMode,
+	ids []interface{},
+	access func(target interface{}) error,
+) (M, error) {
+	item, err := s.GetOne(ctx, itemID)
+	if err != nil {
+		return item, err
+	}
+
+	targets, err := s.repo.FindAssociationTargets(ctx, name, ids)
+	if err != nil {
+		return item, err
+	}
+
+	if access != nil {
+		rows := reflect.ValueOf(targets).Elem()
+
+		for i := 0; i < rows.Len(); i++ {
+			// reported like unknown IDs, so callers can't probe for records
+			if err := access(rows.Index(i).Interface()); err != nil {
+				return item, fmt.Errorf("%w: unknown %s ids", ErrValidation, name)
+			}
+		}
+	}
+
+	switch mode {
+	case AssociationAppend:
+		err = s.repo.AppendAssociation(ctx, item, name, targets)
+	case AssociationReplace:
+		err = s.repo.ReplaceAssociation(ctx, item, name, targets)
+	case AssociationRemove:
+		err = s.repo.RemoveAssociation(ctx, item, name, targets)
+	default:
+		err = fmt.Errorf("unknown association mode %q", mode)
+	}
+
+	if err != nil {
+		return item, err
+	}
+
+	return s.repo.FindOneByID(WithPreloads(ForcePrimary(ctx), name), itemID, "")
+}
+
+// AssociationRequest is the body of the association routes. IDs may be
+// JSON strings or numbers.
+type AssociationRequest struct {
+	IDs []json.RawMessage `json:"ids"`
+}
+
+func (req *AssociationRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+// values decodes the IDs, keeping integers as int64 and anything else as a
+// string.
+func (req *AssociationRequest) values() ([]interface{}, error) {
+	values := make([]interface{}, 0, len(req.IDs))
+
+	for _, raw := range req.IDs {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid id %s: %w", raw, err)
+		}
+
+		switch v := value.(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("invalid id %s: %w", raw, err)
+			}
+
+			values = append(values, n)
+		case string:
+			values = append(values, v)
+		default:
+			return nil, fmt.Errorf("invalid id %s", raw)
+		}
+	}
+
+	return values, nil
+}
+
+// handleAssociation returns a handler changing the named association of the
+// item in context with mode.
+func (c *controller[M, K]) handleAssociation(name string, mode AssociationMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		assocSvc, ok := c.svc.(AssociationService[M, K])
+		if !ok {
+			c.renderError(w, r, "failed to modify association", fmt.Errorf("%T does not support associations", c.svc))
+			return
+		}
+
+		item, err := c.ItemFromContext(ctx)
+		if err != nil {
+			c.errorHandler(w, r, InvalidRequest(err))
+			return
+		}
+
+		req := &AssociationRequest{}
+		if err := render.Bind(r, req); err != nil {
+			c.errorHandler(w, r, InvalidRequest(err))
+			return
+		}
+
+		ids, err := req.values()
+		if err != nil {
+			c.errorHandler(w, r, InvalidRequest(err))
+			return
+		}
+
+		access, err := c.associationAccess(ctx, name)
+		if err != nil {
+			c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+			return
+		}
+
+		updatedItem, err := assocSvc.ModifyAssociation(ctx, item.GetID(), name, mode, ids, access)
+		if err != nil {
+			c.renderError(w, r, "failed to modify association", err)
+			return
+		}
+
+		render.Render(w, r, c.dto(ctx, updatedItem))
+	}
+}
+
+// associationAccess returns the check the request's user must pass for each
+// associated record. Admin controllers skip it, like item access.
+func (c *controller[M, K]) associationAccess(ctx context.Context, name string) (func(target interface{}) error, error) {
+	if c.admin {
+		return nil, nil
+	}
+
+	user, err := c.auth.GetUserFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accessFunc, ok := c.associationAccessFuncs[name]
+	if !ok {
+		accessFunc = OwnerAccessFunc[interface{}]
+	}
+
+	return func(target interface{}) error {
+		return accessFunc(user, target)
+	}, nil
+}
+
+// WithAssociationAccessFunc sets the check for records linked through the
+// named association's routes. It defaults to OwnerAccessFunc, so records
+// without an owner, such as shared tags, need one.
+func WithAssociationAccessFunc[M Resource[K], K comparable](
+	association string,
+	accessFunc UserResourceAccessFunc[interface{}],
+) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		if c.associationAccessFuncs == nil {
+			c.associationAccessFuncs = make(map[string]UserResourceAccessFunc[interface{}])
+		}
+
+		c.associationAccessFuncs[association] = accessFunc
+	}
+}
+
+// WithAssociationRoutes mounts PUT, POST, and DELETE on path, such as
+// "/tags", under the item routes. They replace, append to, and remove from
+// the named association using a body of {"ids": [...]} and respond with the
+// item. They're mounted with the update route. Linked records must pass the
+// association's access func, see WithAssociationAccessFunc.
+func WithAssociationRoutes[M Resource[K], K comparable](path, association string) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		if c.associations == nil {
+			c.associations = make(map[string]string)
+		}
+
+		c.associations[path] = association
+	}
+}
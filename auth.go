@@ -2,10 +2,15 @@ package mochi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/fx"
@@ -15,23 +20,92 @@ type authContextkey int
 
 const (
 	AuthHeaderName = "Authorization"
+
+	SigningSecretEnvName  = "JWT_SIGNING_SECRET"
+	PrivateKeyFileEnvName = "JWT_PRIVATE_KEY_FILE"
+	KeyIDEnvName          = "JWT_KEY_ID"
+	IssuerEnvName         = "JWT_ISSUER"
+	AudienceEnvName       = "JWT_AUDIENCE"
 )
 
 const (
 	userContextKey authContextkey = iota
+	claimsContextKey
+	actorContextKey
+)
+
+const (
+	ImpersonateUserIDParam = "userID"
+
+	BearerScheme = "Bearer"
 )
 
+// RFC 6750 error codes returned in the WWW-Authenticate challenge.
+const (
+	BearerErrorInvalidRequest    = "invalid_request"
+	BearerErrorInvalidToken      = "invalid_token"
+	BearerErrorInsufficientScope = "insufficient_scope"
+)
+
+var ErrMissingToken = errors.New("missing bearer token")
+
 type AuthService interface {
 	AuthRequired() func(http.Handler) http.Handler
+	AuthOptional() func(http.Handler) http.Handler
 	AdminRequired() func(http.Handler) http.Handler
 	GetUserFromCtx(ctx context.Context) (User, error)
+	GetClaimsFromCtx(ctx context.Context) (*Claims, error)
+	GetActorFromCtx(ctx context.Context) (User, error)
+	ImpersonateUser(ctx context.Context, userID uint) (string, error)
+	ImpersonateHandler() http.HandlerFunc
+	IssueServiceAccountToken(principal *ServiceAccountPrincipal) (string, error)
+	RequireScope(scopes ...string) func(http.Handler) http.Handler
 	LoginUser(ctx context.Context, username, password string) (string, error)
+	LogoutUser(ctx context.Context) error
+	RefreshToken(ctx context.Context) (string, error)
+	RevokeToken(ctx context.Context, tokenString string) error
+	JWKSHandler() http.HandlerFunc
+	SetAuthCookie(w http.ResponseWriter, token string) error
+	ClearAuthCookie(w http.ResponseWriter) error
+	InvalidateUser(userID uint)
+}
+
+// AuthConfig controls the claims on issued tokens and how strictly incoming
+// tokens are validated. Issuer and Audience default to JWT_ISSUER and
+// JWT_AUDIENCE; when set, tokens without matching claims are rejected.
+type AuthConfig struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+
+	// Realm is included in WWW-Authenticate challenges when set.
+	Realm string
+
+	// UserCacheTTL is how long AuthRequired reuses a loaded user, defaulting
+	// to DefaultUserCacheTTL. A negative value disables the cache. Only the
+	// default principal resolver is cached, as custom ones may depend on
+	// per-token claims.
+	UserCacheTTL time.Duration
+
+	// Cookie enables reading the token from an httpOnly cookie when the
+	// Authorization header is absent. Pair it with CSRFProtect.
+	Cookie *CookieConfig
 }
 
 type AuthServiceParams struct {
 	fx.In
 
-	Logger      LoggerService
+	Config   *AuthConfig    `optional:"true"`
+	Denylist TokenDenylist  `optional:"true"`
+	Hasher   PasswordHasher `optional:"true"`
+	Keys     *KeySet        `optional:"true"`
+	Logger   LoggerService
+	Metrics  MetricsService `optional:"true"`
+
+	OIDC              *OIDCProvider     `optional:"true"`
+	OIDCResolver      OIDCUserResolver  `optional:"true"`
+	PrincipalResolver PrincipalResolver `optional:"true"`
+
 	UserService UserService
 }
 
@@ -42,20 +116,97 @@ type AuthServiceResult struct {
 }
 
 type authService struct {
-	logger        LoggerService
-	signingSecret string
-	userService   UserService
+	config      AuthConfig
+	denylist    TokenDenylist
+	hasher      PasswordHasher
+	keys        *KeySet
+	logger      LoggerService
+	metrics     MetricsService
+	resolver    PrincipalResolver
+	userCache   *userCache
+	userService UserService
+
+	oidc         *OIDCProvider
+	oidcResolver OIDCUserResolver
 }
 
 func NewAuthService(params AuthServiceParams) (AuthServiceResult, error) {
 	var result AuthServiceResult
 
-	signingSecret := os.Getenv("JWT_SIGNING_SECRET")
+	config := AuthConfig{
+		Issuer:   os.Getenv(IssuerEnvName),
+		Audience: os.Getenv(AudienceEnvName),
+	}
+
+	if params.Config != nil {
+		config = *params.Config
+	}
+
+	if config.Issuer == "" || config.Audience == "" {
+		params.Logger.Warn("JWT issuer or audience not configured, tokens from other systems may be accepted")
+	}
+
+	keys := params.Keys
+	if keys == nil {
+		var err error
+
+		keys, err = loadKeySetFromEnv()
+		if err != nil {
+			return result, fmt.Errorf("failed to load signing keys: %w", err)
+		}
+	}
+
+	if err := keys.Active().Validate(); err != nil {
+		return result, fmt.Errorf("invalid active signing key: %w", err)
+	}
+
+	denylist := params.Denylist
+	if denylist == nil {
+		params.Logger.Warn("No token denylist configured, revocations are kept in memory")
+		denylist = NewMemoryTokenDenylist()
+	}
+
+	hasher := params.Hasher
+	if hasher == nil {
+		hasher = DefaultPasswordHasher()
+	}
+
+	if params.OIDC != nil && params.OIDCResolver == nil {
+		return result, fmt.Errorf("an OIDCUserResolver is required when OIDC is configured")
+	}
+
+	var cache *userCache
+	if config.UserCacheTTL >= 0 && params.PrincipalResolver == nil {
+		ttl := config.UserCacheTTL
+		if ttl == 0 {
+			ttl = DefaultUserCacheTTL
+		}
+
+		cache = newUserCache(ttl, DefaultUserCacheSize)
+	}
+
+	resolver := params.PrincipalResolver
+	if resolver == nil {
+		resolver = NewDefaultPrincipalResolver(params.UserService)
+	}
+
+	metrics := params.Metrics
+	if metrics == nil {
+		metrics = NewNoopMetricsService()
+	}
 
 	result.AuthService = &authService{
-		logger:        params.Logger,
-		signingSecret: signingSecret,
-		userService:   params.UserService,
+		resolver:     resolver,
+		userCache:    cache,
+		oidc:         params.OIDC,
+		oidcResolver: params.OIDCResolver,
+		config:       config,
+		denylist:     denylist,
+		hasher:       hasher,
+		keys:         keys,
+		logger:       params.Logger,
+		metrics:      metrics,
+		userService:  params.UserService,
 	}
 
 	return result, nil
@@ -64,29 +215,168 @@ func NewAuthService(params AuthServiceParams) (AuthServiceResult, error) {
 func (svc *authService) AuthRequired() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tokenString, err := svc.getTokenStringFromAuthHeader(r)
-			if err != nil {
-				render.Render(w, r, render.Renderer(ErrUnauthorized(err)))
+			// already authenticated by an earlier strategy, such as APIKeyAuth
+			if _, err := svc.GetUserFromCtx(r.Context()); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tokenString, err := svc.getTokenString(r)
+			if errors.Is(err, ErrMissingToken) {
+				svc.renderBearerError(w, r, http.StatusUnauthorized, "", err)
 				return
 			}
 
-			claims, err := svc.validateUserToken(tokenString)
 			if err != nil {
-				render.Render(w, r, render.Renderer(ErrUnauthorized(err)))
+				svc.renderBearerError(w, r, http.StatusBadRequest, BearerErrorInvalidRequest, err)
 				return
 			}
 
-			user, err := svc.userService.GetUserByID(r.Context(), claims.Sub)
+			user, claims, err := svc.authenticateToken(r.Context(), tokenString)
+			if err == nil && claims.Act != nil {
+				var actor User
+
+				actor, err = svc.authenticateActor(r.Context(), claims)
+				r = r.WithContext(context.WithValue(r.Context(), actorContextKey, actor))
+			}
+
 			if err != nil {
-				render.Render(w, r, render.Renderer(ErrUnauthorized(err)))
+				if ErrorStatus(err) >= http.StatusInternalServerError {
+					svc.logger.WithContext(r.Context()).Error("failed to authenticate token", "error", err)
+					render.Render(w, r, ErrUnknown(err))
+				} else {
+					svc.renderBearerError(w, r, http.StatusUnauthorized, BearerErrorInvalidToken, err)
+				}
+
+				return
 			}
 
 			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, claimsContextKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// AuthOptional authenticates requests that carry credentials, like
+// AuthRequired, and lets anonymous requests through without a user.
+func (svc *authService) AuthOptional() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		required := svc.AuthRequired()(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := svc.getTokenString(r); errors.Is(err, ErrMissingToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			required.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticateToken validates a bearer token and resolves its user, routing
+// tokens from the configured OIDC provider to OIDC verification.
+func (svc *authService) authenticateToken(ctx context.Context, tokenString string) (User, *Claims, error) {
+	if svc.oidc != nil && svc.oidc.IssuedBy(tokenString) {
+		return svc.authenticateOIDCToken(ctx, tokenString)
+	}
+
+	claims, err := svc.validateUserToken(tokenString)
+	if err != nil {
+		return nil, nil, NewStatusError(http.StatusUnauthorized, err)
+	}
+
+	revoked, err := svc.denylist.IsRevoked(ctx, claims.Jti)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check token denylist: %w", err)
+	}
+
+	if revoked {
+		return nil, nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("token has been revoked"))
+	}
+
+	user, err := svc.resolvePrincipal(ctx, claims)
+	if err != nil {
+		return nil, nil, NewStatusError(http.StatusUnauthorized, err)
+	}
+
+	return user, claims, nil
+}
+
+// resolvePrincipal runs the PrincipalResolver. Users loaded by the default
+// resolver depend only on their ID and are cached by it; other principal
+// types and custom resolvers depend on per-token claims and aren't cached.
+func (svc *authService) resolvePrincipal(ctx context.Context, claims *Claims) (User, error) {
+	if claims.Pty != "" || svc.userCache == nil {
+		return svc.resolver.ResolvePrincipal(ctx, claims)
+	}
+
+	if user, ok := svc.userCache.get(claims.Sub); ok {
+		return user, nil
+	}
+
+	user, err := svc.resolver.ResolvePrincipal(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.userCache.set(claims.Sub, user)
+
+	return user, nil
+}
+
+// InvalidateUser drops the user from the auth user cache. Call it after
+// changing a user, such as their admin flag or password, so the change
+// applies to the next request on this instance.
+func (svc *authService) InvalidateUser(userID uint) {
+	if svc.userCache != nil {
+		svc.userCache.invalidate(userID)
+	}
+}
+
+// authenticateActor loads the admin behind an impersonation token. Tokens
+// stop working as soon as the actor loses admin rights.
+func (svc *authService) authenticateActor(ctx context.Context, claims *Claims) (User, error) {
+	actor, err := svc.resolvePrincipal(ctx, &Claims{Sub: claims.Act.Sub})
+	if err != nil {
+		return nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("failed to get impersonating user: %w", err))
+	}
+
+	if !actor.IsAdmin() {
+		return nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("impersonating user is not an admin"))
+	}
+
+	return actor, nil
+}
+
+func (svc *authService) authenticateOIDCToken(ctx context.Context, tokenString string) (User, *Claims, error) {
+	oidcClaims, err := svc.oidc.Verify(ctx, tokenString)
+	if err != nil {
+		return nil, nil, NewStatusError(http.StatusUnauthorized, err)
+	}
+
+	user, err := svc.oidcResolver.ResolveUser(ctx, oidcClaims)
+	if err != nil {
+		return nil, nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("failed to resolve oidc user: %w", err))
+	}
+
+	claims := &Claims{
+		Sub: user.GetID(),
+		Iss: oidcClaims.Issuer,
+		Jti: oidcClaims.ID,
+	}
+
+	claims.Exp = oidcClaims.ExpiresAt
+	claims.Iat = oidcClaims.IssuedAt
+
+	if len(oidcClaims.Audience) > 0 {
+		claims.Aud = oidcClaims.Audience[0]
+	}
+
+	return user, claims, nil
+}
+
 func (svc *authService) AdminRequired() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -94,12 +384,12 @@ func (svc *authService) AdminRequired() func(http.Handler) http.Handler {
 
 			user, err := svc.GetUserFromCtx(ctx)
 			if err != nil {
-				render.Render(w, r, ErrUnauthorized(err))
+				svc.renderBearerError(w, r, http.StatusUnauthorized, "", err)
 				return
 			}
 
 			if !user.IsAdmin() {
-				render.Render(w, r, ErrUnauthorized(fmt.Errorf("user is not an admin")))
+				svc.renderBearerError(w, r, http.StatusForbidden, BearerErrorInsufficientScope, fmt.Errorf("user is not an admin"))
 				return
 			}
 
@@ -117,10 +407,238 @@ func (svc *authService) GetUserFromCtx(ctx context.Context) (User, error) {
 	return user, nil
 }
 
+func (svc *authService) GetClaimsFromCtx(ctx context.Context) (*Claims, error) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	if !ok {
+		return nil, fmt.Errorf("could not get claims from context")
+	}
+
+	return claims, nil
+}
+
+// GetActorFromCtx returns the user actually making the request: the admin
+// for impersonated requests and the authenticated user otherwise.
+func (svc *authService) GetActorFromCtx(ctx context.Context) (User, error) {
+	if actor, ok := ctx.Value(actorContextKey).(User); ok {
+		return actor, nil
+	}
+
+	return svc.GetUserFromCtx(ctx)
+}
+
+// ImpersonateUser issues a short-lived token for userID on behalf of the
+// current admin, who is recorded in the act claim. Admins can't be
+// impersonated and impersonation can't be nested.
+func (svc *authService) ImpersonateUser(ctx context.Context, userID uint) (string, error) {
+	actor, err := svc.GetUserFromCtx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !actor.IsAdmin() {
+		return "", fmt.Errorf("%w: only admins can impersonate users", ErrForbidden)
+	}
+
+	if _, ok := ctx.Value(actorContextKey).(User); ok {
+		return "", fmt.Errorf("%w: cannot impersonate while impersonating", ErrForbidden)
+	}
+
+	user, err := svc.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user to impersonate: %w", err)
+	}
+
+	if user.IsAdmin() {
+		return "", fmt.Errorf("%w: admins cannot be impersonated", ErrForbidden)
+	}
+
+	claims := NewClaims(user, svc.config.Audience, svc.config.Issuer)
+	claims.Exp = jwt.NewNumericDate(claims.Iat.Add(ImpersonationExpirationTime))
+	claims.Act = &ActorClaims{Sub: actor.GetID()}
+
+	token, err := svc.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	svc.logger.WithContext(ctx).Warn("Admin impersonating user", "actor", actor.GetID(), "user", userID, "jti", claims.Jti)
+
+	return token, nil
+}
+
+// ImpersonateHandler serves POST /admin/impersonate/{userID}. It applies
+// AuthRequired and AdminRequired itself.
+func (svc *authService) ImpersonateHandler() http.HandlerFunc {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseUint(chi.URLParam(r, ImpersonateUserIDParam), 10, 0)
+		if err != nil {
+			DefaultErrorHandler(w, r, InvalidRequest(err))
+			return
+		}
+
+		token, err := svc.ImpersonateUser(r.Context(), uint(userID))
+		if err != nil {
+			DefaultErrorHandler(w, r, err)
+			return
+		}
+
+		render.Render(w, r, &TokenResponse{
+			AccessToken: token,
+			TokenType:   TokenTypeBearer,
+			ExpiresIn:   int(ImpersonationExpirationTime.Seconds()),
+		})
+	})
+
+	return svc.AuthRequired()(svc.AdminRequired()(handler)).ServeHTTP
+}
+
+// IssueServiceAccountToken signs a short-lived token for a service account
+// limited to the principal's scopes.
+func (svc *authService) IssueServiceAccountToken(principal *ServiceAccountPrincipal) (string, error) {
+	if len(principal.Scopes) == 0 {
+		return "", fmt.Errorf("service account tokens require at least one scope")
+	}
+
+	now := time.Now()
+
+	claims := &Claims{
+		Sub:   principal.AccountID,
+		Exp:   jwt.NewNumericDate(now.Add(ServiceAccountTokenTTL)),
+		Iat:   jwt.NewNumericDate(now),
+		Nbf:   jwt.NewNumericDate(now),
+		Aud:   svc.config.Audience,
+		Iss:   svc.config.Issuer,
+		Jti:   newTokenID(),
+		Pty:   PrincipalTypeServiceAccount,
+		Scope: strings.Join(principal.Scopes, " "),
+	}
+
+	return svc.keys.Sign(claims)
+}
+
+// RequireScope rejects scoped tokens missing any of the scopes with 403 and
+// an insufficient_scope challenge. First-party tokens without a scope
+// claim and API key requests are not limited. It must run after
+// AuthRequired.
+func (svc *authService) RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := svc.GetUserFromCtx(r.Context()); err != nil {
+				svc.renderBearerError(w, r, http.StatusUnauthorized, "", err)
+				return
+			}
+
+			claims, err := svc.GetClaimsFromCtx(r.Context())
+			if err != nil || (claims.Scope == "" && claims.Pty == "") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			granted := claims.Scopes()
+			for _, scope := range scopes {
+				if !containsScope(granted, scope) {
+					svc.renderBearerError(w, r, http.StatusForbidden, BearerErrorInsufficientScope, fmt.Errorf("token is missing scope %q", scope))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (svc *authService) LoginUser(ctx context.Context, username, password string) (string, error) {
-	user, err := svc.userService.GetUserByCredentials(ctx, username, password)
+	user, err := svc.authenticateCredentials(ctx, username, password)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := svc.generateUserToken(user)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate user token: %w", err)
+	}
+
+	return token, nil
+}
+
+// authenticateCredentials verifies the password with the PasswordHasher when
+// the UserService stores hashes, upgrading outdated hashes, and otherwise
+// defers to GetUserByCredentials.
+func (svc *authService) authenticateCredentials(ctx context.Context, username, password string) (User, error) {
+	store, ok := svc.userService.(PasswordCredentialStore)
+	if !ok {
+		user, err := svc.userService.GetUserByCredentials(ctx, username, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user by credentials: %w", err)
+		}
+
+		return user, nil
+	}
+
+	user, hash, err := store.GetUserPasswordHash(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user password hash: %w", err)
+	}
+
+	valid, err := svc.hasher.Verify(hash, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+
+	if !valid {
+		return nil, fmt.Errorf("invalid credentials")
+	}
+
+	if svc.hasher.NeedsRehash(hash) {
+		svc.upgradePasswordHash(ctx, store, user, password)
+	}
+
+	return user, nil
+}
+
+// upgradePasswordHash rehashes the password with the current algorithm.
+// Failures are logged and don't fail the login.
+func (svc *authService) upgradePasswordHash(ctx context.Context, store PasswordCredentialStore, user User, password string) {
+	hash, err := svc.hasher.Hash(password)
+	if err == nil {
+		err = store.SetUserPasswordHash(ctx, user.GetID(), hash)
+	}
+
 	if err != nil {
-		return "", fmt.Errorf("failed to get user by credentials: %w", err)
+		svc.logger.WithContext(ctx).Warn("Failed to upgrade password hash", "user", user.GetID(), "error", err)
+		return
+	}
+
+	svc.InvalidateUser(user.GetID())
+
+	svc.logger.WithContext(ctx).Info("Upgraded password hash", "user", user.GetID())
+}
+
+// LogoutUser revokes the token that authenticated the current request.
+func (svc *authService) LogoutUser(ctx context.Context) error {
+	claims, err := svc.GetClaimsFromCtx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get claims: %w", err)
+	}
+
+	return svc.revokeClaims(ctx, claims)
+}
+
+// RefreshToken issues a new token for the current user and revokes the
+// token that authenticated the request.
+func (svc *authService) RefreshToken(ctx context.Context) (string, error) {
+	user, err := svc.GetUserFromCtx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	claims, err := svc.GetClaimsFromCtx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get claims: %w", err)
+	}
+
+	if claims.Act != nil || claims.Pty != "" {
+		return "", fmt.Errorf("%w: only user tokens can be refreshed", ErrForbidden)
 	}
 
 	token, err := svc.generateUserToken(user)
@@ -128,14 +646,77 @@ func (svc *authService) LoginUser(ctx context.Context, username, password string
 		return "", fmt.Errorf("failed to generate user token: %w", err)
 	}
 
+	if claims.Jti != "" {
+		if err := svc.revokeClaims(ctx, claims); err != nil {
+			return "", err
+		}
+	}
+
 	return token, nil
 }
 
+// RevokeToken revokes a token before it expires, for example when it has
+// been compromised.
+func (svc *authService) RevokeToken(ctx context.Context, tokenString string) error {
+	claims, err := svc.validateUserToken(tokenString)
+	if err != nil {
+		return fmt.Errorf("failed to validate token: %w", err)
+	}
+
+	return svc.revokeClaims(ctx, claims)
+}
+
+func (svc *authService) revokeClaims(ctx context.Context, claims *Claims) error {
+	if claims.Jti == "" {
+		return fmt.Errorf("token has no ID and cannot be revoked")
+	}
+
+	err := svc.denylist.Revoke(ctx, claims.Jti, claims.ExpiresAt())
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	svc.logger.WithContext(ctx).Info("Revoked token", "user", claims.Sub, "jti", claims.Jti)
+
+	return nil
+}
+
+// SetAuthCookie writes the token to the auth cookie for browser clients.
+func (svc *authService) SetAuthCookie(w http.ResponseWriter, token string) error {
+	if svc.config.Cookie == nil {
+		return fmt.Errorf("cookie transport is not configured")
+	}
+
+	maxAge := svc.config.Cookie.MaxAge
+	if maxAge <= 0 {
+		maxAge = TokenExpirationTime
+	}
+
+	http.SetCookie(w, svc.config.Cookie.authCookie(token, maxAge))
+
+	return nil
+}
+
+func (svc *authService) ClearAuthCookie(w http.ResponseWriter) error {
+	if svc.config.Cookie == nil {
+		return fmt.Errorf("cookie transport is not configured")
+	}
+
+	cookie := svc.config.Cookie.authCookie("", 0)
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+
+	return nil
+}
+
+func (svc *authService) JWKSHandler() http.HandlerFunc {
+	return svc.keys.JWKSHandler()
+}
+
 func (svc *authService) generateUserToken(user User) (string, error) {
-	claims := NewClaims(user, "TODO", "TODO")
+	claims := NewClaims(user, svc.config.Audience, svc.config.Issuer)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(svc.signingSecret))
+	tokenString, err := svc.keys.Sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -147,10 +728,8 @@ func (svc *authService) validateUserToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(svc.signingSecret), nil
-		},
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		svc.keys.Keyfunc,
+		svc.parserOptions()...,
 	)
 
 	if err != nil {
@@ -165,12 +744,132 @@ func (svc *authService) validateUserToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+func (svc *authService) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(svc.keys.Methods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(svc.config.Leeway),
+	}
+
+	if svc.config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(svc.config.Issuer))
+	}
+
+	if svc.config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(svc.config.Audience))
+	}
+
+	return opts
+}
+
+// getTokenString reads the token from the Authorization header, falling
+// back to the auth cookie when cookie transport is enabled.
+func (svc *authService) getTokenString(r *http.Request) (string, error) {
+	if r.Header.Get(AuthHeaderName) == "" && svc.config.Cookie != nil {
+		cookie, err := r.Cookie(svc.config.Cookie.name())
+		if err == nil && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+
+	return svc.getTokenStringFromAuthHeader(r)
+}
+
+// getTokenStringFromAuthHeader requires the Bearer scheme, matched case
+// insensitively, followed by a token.
 func (svc *authService) getTokenStringFromAuthHeader(r *http.Request) (string, error) {
 	authHeader := r.Header.Get(AuthHeaderName)
 
 	if authHeader == "" {
-		return "", fmt.Errorf("missing auth header")
+		return "", ErrMissingToken
+	}
+
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, BearerScheme) {
+		return "", fmt.Errorf("auth header must use the %s scheme", BearerScheme)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" || strings.ContainsAny(token, " \t") {
+		return "", fmt.Errorf("malformed bearer token")
+	}
+
+	return token, nil
+}
+
+// renderBearerError responds with an RFC 6750 WWW-Authenticate challenge.
+// Requests without credentials get a challenge without an error code.
+func (svc *authService) renderBearerError(w http.ResponseWriter, r *http.Request, status int, code string, err error) {
+	reason := code
+	if reason == "" {
+		reason = "missing_credentials"
+	}
+
+	svc.metrics.IncCounter(AuthFailuresMetric, 1, MetricLabels{"reason": reason})
+
+	challenge := BearerScheme
+	params := []string{}
+
+	if svc.config.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", challengeValue(svc.config.Realm)))
+	}
+
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code))
+		params = append(params, fmt.Sprintf("error_description=%q", challengeValue(err.Error())))
+	}
+
+	if len(params) > 0 {
+		challenge = fmt.Sprintf("%s %s", challenge, strings.Join(params, ", "))
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+
+	render.Render(w, r, &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: status,
+		StatusText:     http.StatusText(status) + ".",
+		ErrorText:      err.Error(),
+	})
+}
+
+// challengeValue drops characters that can't appear in a quoted
+// WWW-Authenticate parameter.
+func challengeValue(value string) string {
+	return strings.Map(func(ch rune) rune {
+		if ch == '"' || ch == '\\' || ch < 0x20 || ch > 0x7e {
+			return -1
+		}
+
+		return ch
+	}, value)
+}
+
+// loadKeySetFromEnv uses the PEM private key at JWT_PRIVATE_KEY_FILE when
+// set, falling back to HS256 with JWT_SIGNING_SECRET.
+func loadKeySetFromEnv() (*KeySet, error) {
+	keyID := os.Getenv(KeyIDEnvName)
+
+	keyFile := os.Getenv(PrivateKeyFileEnvName)
+	if keyFile == "" {
+		secret := os.Getenv(SigningSecretEnvName)
+		if secret == "" {
+			return nil, fmt.Errorf("neither %s nor %s is set", PrivateKeyFileEnvName, SigningSecretEnvName)
+		}
+
+		return NewKeySet(NewHMACSigningKey(keyID, []byte(secret))), nil
+	}
+
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	key, err := ParsePrivateKeyPEM(keyID, pemBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	return authHeader[len("Bearer "):], nil
+	return NewKeySet(key), nil
 }
@@ -0,0 +1,183 @@
+package mochi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	TokenTypeBearer = "Bearer"
+)
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (req *LoginRequest) Bind(r *http.Request) error {
+	if req.Username == "" || req.Password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+
+	return nil
+}
+
+// TokenResponse is the token DTO returned by the auth routes.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+func NewTokenResponse(token string) *TokenResponse {
+	return &TokenResponse{
+		AccessToken: token,
+		TokenType:   TokenTypeBearer,
+		ExpiresIn:   int(TokenExpirationTime.Seconds()),
+	}
+}
+
+func (resp *TokenResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// UserResponse is returned by GET /me for users that don't implement
+// render.Renderer themselves.
+type UserResponse struct {
+	ID      uint `json:"id"`
+	IsAdmin bool `json:"is_admin"`
+}
+
+func (resp *UserResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// AuthController mounts the standard login, refresh, logout, and me
+// routes around AuthService.
+type AuthController interface {
+	Login(w http.ResponseWriter, r *http.Request)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	Me(w http.ResponseWriter, r *http.Request)
+
+	GetRouter() *chi.Mux
+}
+
+type AuthControllerParams struct {
+	fx.In
+
+	Auth   AuthService
+	Config *AuthConfig `optional:"true"`
+	Logger LoggerService
+}
+
+type AuthControllerResult struct {
+	fx.Out
+
+	AuthController AuthController
+}
+
+type authController struct {
+	auth       AuthService
+	logger     LoggerService
+	useCookies bool
+	Router     *chi.Mux
+}
+
+func NewAuthController(params AuthControllerParams) (AuthControllerResult, error) {
+	ctrl := &authController{
+		auth:       params.Auth,
+		logger:     params.Logger,
+		useCookies: params.Config != nil && params.Config.Cookie != nil,
+	}
+
+	ctrl.Router = chi.NewRouter()
+	ctrl.Router.Post("/login", ctrl.Login)
+
+	ctrl.Router.Group(func(r chi.Router) {
+		r.Use(params.Auth.AuthRequired())
+
+		r.Post("/refresh", ctrl.Refresh)
+		r.Post("/logout", ctrl.Logout)
+		r.Get("/me", ctrl.Me)
+	})
+
+	return AuthControllerResult{AuthController: ctrl}, nil
+}
+
+func (ctrl *authController) Login(w http.ResponseWriter, r *http.Request) {
+	req := &LoginRequest{}
+	if err := render.Bind(r, req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	token, err := ctrl.auth.LoginUser(r.Context(), req.Username, req.Password)
+	if err != nil {
+		ctrl.logger.WithContext(r.Context()).Info("Login failed", "username", req.Username, "error", err)
+		render.Render(w, r, ErrUnauthorized(fmt.Errorf("invalid username or password")))
+		return
+	}
+
+	ctrl.renderToken(w, r, token)
+}
+
+func (ctrl *authController) Refresh(w http.ResponseWriter, r *http.Request) {
+	token, err := ctrl.auth.RefreshToken(r.Context())
+	if err != nil {
+		ctrl.logger.WithContext(r.Context()).Error("Failed to refresh token", "error", err)
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	ctrl.renderToken(w, r, token)
+}
+
+func (ctrl *authController) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := ctrl.auth.LogoutUser(r.Context()); err != nil {
+		ctrl.logger.WithContext(r.Context()).Error("Failed to log out user", "error", err)
+		render.Render(w, r, ErrUnknown(err))
+		return
+	}
+
+	if ctrl.useCookies {
+		ctrl.auth.ClearAuthCookie(w)
+	}
+
+	render.NoContent(w, r)
+}
+
+func (ctrl *authController) Me(w http.ResponseWriter, r *http.Request) {
+	user, err := ctrl.auth.GetUserFromCtx(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrUnauthorized(err))
+		return
+	}
+
+	if renderer, ok := user.(render.Renderer); ok {
+		render.Render(w, r, renderer)
+		return
+	}
+
+	render.Render(w, r, &UserResponse{ID: user.GetID(), IsAdmin: user.IsAdmin()})
+}
+
+func (ctrl *authController) GetRouter() *chi.Mux {
+	return ctrl.Router
+}
+
+func (ctrl *authController) renderToken(w http.ResponseWriter, r *http.Request, token string) {
+	if ctrl.useCookies {
+		if err := ctrl.auth.SetAuthCookie(w, token); err != nil {
+			render.Render(w, r, ErrUnknown(err))
+			return
+		}
+	}
+
+	render.Render(w, r, NewTokenResponse(token))
+}
@@ -0,0 +1,209 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DefaultBackfillBatch = 500
+
+// BackfillCheckpoint records how far a backfill has progressed. Add it to
+// the app's ModelList when using backfills.
+type BackfillCheckpoint struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	LastID    string    `json:"last_id"`
+	Processed int64     `json:"processed"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Backfill walks M's table in primary key order, BatchSize rows at a time,
+// passing each batch to Transform. Each batch and its checkpoint commit in
+// one transaction, so a backfill stopped by a crash or deploy resumes after
+// the last committed batch. Query restricts the rows, such as
+// "slug IS NULL", and RowsPerSecond throttles writes.
+type Backfill[M Model[K], K comparable] struct {
+	Name          string
+	BatchSize     int
+	RowsPerSecond float64
+	Query         string
+	Args          []interface{}
+	Transform     func(ctx context.Context, tx *gorm.DB, batch []M) error
+}
+
+// Run runs the backfill from its last checkpoint until every row has been
+// processed or ctx is done. Completed backfills return immediately; use
+// ResetBackfill to run one again.
+func (b Backfill[M, K]) Run(ctx context.Context, db DBService, logger LoggerService) (*BackfillCheckpoint, error) {
+	if b.Name == "" || b.Transform == nil {
+		return nil, fmt.Errorf("backfills need a name and a transform")
+	}
+
+	if db.IsReadOnly() {
+		return nil, ErrReadOnly
+	}
+
+	batchSize := b.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatch
+	}
+
+	for {
+		started := time.Now()
+
+		checkpoint, count, err := b.runBatch(ctx, db, batchSize)
+		if err != nil {
+			return checkpoint, fmt.Errorf("backfill %s failed: %w", b.Name, err)
+		}
+
+		if checkpoint.Done {
+			logger.Info("Backfill complete", "backfill", b.Name, "processed", checkpoint.Processed)
+			return checkpoint, nil
+		}
+
+		logger.Debug("Backfilled batch", "backfill", b.Name, "rows", count, "processed", checkpoint.Processed, "last_id", checkpoint.LastID)
+
+		if err := b.throttle(ctx, count, time.Since(started)); err != nil {
+			return checkpoint, err
+		}
+	}
+}
+
+// runBatch processes the batch after the checkpoint, returning the updated
+// checkpoint and the number of rows in the batch.
+func (b Backfill[M, K]) runBatch(ctx context.Context, db DBService, batchSize int) (*BackfillCheckpoint, int, error) {
+	checkpoint := &BackfillCheckpoint{Name: b.Name}
+	count := 0
+
+	sesh, cancel := db.GetSession(ctx)
+	defer cancel()
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&BackfillCheckpoint{Name: b.Name}).Error
+		if err != nil {
+			return fmt.Errorf("failed to create checkpoint: %w", err)
+		}
+
+		// the lock keeps two runs of the same backfill from interleaving
+		lookup := tx
+		if db.Dialect() != DialectSQLite {
+			lookup = lookup.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+
+		if err := lookup.Where("name = ?", b.Name).First(checkpoint).Error; err != nil {
+			return fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+
+		if checkpoint.Done {
+			return nil
+		}
+
+		conds := []Cond{Raw(b.Query, b.Args...)}
+
+		if checkpoint.LastID != "" {
+			lastID, err := ParseID[K](checkpoint.LastID)
+			if err != nil {
+				return fmt.Errorf("invalid checkpoint: %w", err)
+			}
+
+			conds = append(conds, Gt("id", lastID))
+		}
+
+		query, args, err := And(conds...).Build()
+		if err != nil {
+			return err
+		}
+
+		var batch []M
+
+		batchQuery := tx.Order("id").Limit(batchSize)
+		if query != "" {
+			batchQuery = batchQuery.Where(query, args...)
+		}
+
+		if err := batchQuery.Find(&batch).Error; err != nil {
+			return fmt.Errorf("failed to load batch: %w", err)
+		}
+
+		count = len(batch)
+
+		if count > 0 {
+			if err := b.Transform(ctx, tx, batch); err != nil {
+				return fmt.Errorf("transform failed: %w", err)
+			}
+
+			lastID, err := formatID(batch[count-1].GetID())
+			if err != nil {
+				return err
+			}
+
+			checkpoint.LastID = lastID
+			checkpoint.Processed += int64(count)
+		}
+
+		checkpoint.Done = count < batchSize
+
+		return tx.Save(checkpoint).Error
+	})
+
+	return checkpoint, count, err
+}
+
+// throttle sleeps long enough to keep the backfill under RowsPerSecond.
+func (b Backfill[M, K]) throttle(ctx context.Context, rows int, elapsed time.Duration) error {
+	if b.RowsPerSecond <= 0 {
+		return ctx.Err()
+	}
+
+	wait := time.Duration(float64(rows)/b.RowsPerSecond*float64(time.Second)) - elapsed
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Task wraps the backfill as a Task named after it. Passing --reset starts
+// it over from the first row.
+func (b Backfill[M, K]) Task(db DBService, logger LoggerService, description string) Task {
+	return Task{
+		Name:        b.Name,
+		Description: description,
+		Run: func(ctx context.Context, args []string) error {
+			for _, arg := range args {
+				if arg == "--reset" {
+					if err := ResetBackfill(ctx, db, b.Name); err != nil {
+						return err
+					}
+				}
+			}
+
+			_, err := b.Run(ctx, db, logger)
+
+			return err
+		},
+	}
+}
+
+// ResetBackfill forgets a backfill's progress so its next run starts over.
+func ResetBackfill(ctx context.Context, db DBService, name string) error {
+	err := db.DeleteMany(ctx, &BackfillCheckpoint{}, "name = ?", name)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return fmt.Errorf("failed to reset backfill %s: %w", name, err)
+	}
+
+	return nil
+}
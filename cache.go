@@ -0,0 +1,31 @@
+package mochi
+
+import (
+	"context"
+	"time"
+)
+
+// CacheService stores short-lived values by key.
+type CacheService interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type noopCacheService struct{}
+
+func NewNoopCacheService() CacheService {
+	return noopCacheService{}
+}
+
+func (noopCacheService) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (noopCacheService) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (noopCacheService) Delete(ctx context.Context, key string) error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package mochi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CachePolicy describes the Cache-Control header sent with successful
+// responses. Private should be set for anything that varies by user unless
+// the route is public. With ETag, responses carry a content hash and
+// matching If-None-Match requests get 304 Not Modified.
+type CachePolicy struct {
+	MaxAge  time.Duration
+	Private bool
+	NoStore bool
+	ETag    bool
+}
+
+// Header returns the Cache-Control header value for the policy.
+func (p CachePolicy) Header() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	directives := []string{"public"}
+	if p.Private {
+		directives[0] = "private"
+	}
+
+	if p.MaxAge > 0 {
+		directives = append(directives, fmt.Sprintf("max-age=%d", int(p.MaxAge.Seconds())))
+	} else {
+		directives = append(directives, "no-cache")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// CacheControl applies policy to 2xx responses. Error responses are never
+// marked cacheable.
+func CacheControl(policy CachePolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw := &bufferedResponseWriter{ResponseWriter: w}
+
+			next.ServeHTTP(bw, r)
+
+			status := bw.statusCode()
+			if status >= 200 && status < 300 {
+				w.Header().Set("Cache-Control", policy.Header())
+				w.Header().Add("Vary", "Authorization")
+
+				if policy.ETag && !policy.NoStore && status == http.StatusOK && isSafeMethod(r.Method) {
+					etag := contentETag(bw.body.Bytes())
+					w.Header().Set("ETag", etag)
+
+					if etagMatches(r.Header.Get("If-None-Match"), etag) {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+				}
+			}
+
+			w.WriteHeader(status)
+			w.Write(bw.body.Bytes())
+		})
+	}
+}
+
+// bufferedResponseWriter holds the response so headers can be set after
+// the handler has run.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements the weak comparison used for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
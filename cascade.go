@@ -0,0 +1,123 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DeleteCascade deletes the record with recordID together with the named
+// child associations, in one transaction. Children of the nullify
+// associations are kept but detached: has-one and has-many foreign keys are
+// set to NULL and many-to-many links removed. record must be a pointer to
+// a model and is loaded before it is deleted.
+func (srv *dbService) DeleteCascade(
+	ctx context.Context,
+	recordID interface{},
+	record interface{},
+	deletes []string,
+	nullifies []string,
+) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", recordID).First(record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecordNotFound
+			}
+
+			return fmt.Errorf("failed to load record: %w", err)
+		}
+
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(record); err != nil {
+			return fmt.Errorf("failed to parse record: %w", err)
+		}
+
+		rootValue := reflect.Indirect(reflect.ValueOf(record))
+
+		for _, name := range nullifies {
+			rel, err := childRelation(stmt.Schema, name)
+			if err != nil {
+				return err
+			}
+
+			if err := nullifyChildren(ctx, tx, record, rel, rootValue); err != nil {
+				return fmt.Errorf("failed to detach association %s: %w", name, err)
+			}
+		}
+
+		for _, name := range deletes {
+			if _, err := childRelation(stmt.Schema, name); err != nil {
+				return err
+			}
+		}
+
+		deleteQuery := tx
+		if len(deletes) > 0 {
+			deleteQuery = tx.Select(deletes)
+		}
+
+		if err := deleteQuery.Delete(record).Error; err != nil {
+			return fmt.Errorf("failed to delete record: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return err
+		}
+
+		return fmt.Errorf("delete cascade failed: %w", err)
+	}
+
+	return nil
+}
+
+// childRelation looks up an association owned by the model; belongs-to
+// associations point at parents and can't cascade.
+func childRelation(modelSchema *schema.Schema, name string) (*schema.Relationship, error) {
+	rel, ok := modelSchema.Relationships.Relations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown association %q on %s", name, modelSchema.Name)
+	}
+
+	if rel.Type == schema.BelongsTo {
+		return nil, fmt.Errorf("association %q on %s belongs to a parent and can't cascade", name, modelSchema.Name)
+	}
+
+	return rel, nil
+}
+
+func nullifyChildren(ctx context.Context, tx *gorm.DB, record interface{}, rel *schema.Relationship, rootValue reflect.Value) error {
+	if rel.Type == schema.Many2Many {
+		return tx.Model(record).Association(rel.Name).Clear()
+	}
+
+	query := tx.Model(reflect.New(rel.FieldSchema.ModelType).Interface())
+	updates := map[string]interface{}{}
+
+	for _, ref := range rel.References {
+		if ref.OwnPrimaryKey {
+			rootID, _ := ref.PrimaryKey.ValueOf(ctx, rootValue)
+			query = query.Where(fmt.Sprintf("%s = ?", ref.ForeignKey.DBName), rootID)
+		} else {
+			query = query.Where(fmt.Sprintf("%s = ?", ref.ForeignKey.DBName), ref.PrimaryValue)
+		}
+
+		updates[ref.ForeignKey.DBName] = nil
+	}
+
+	return query.Updates(updates).Error
+}
@@ -0,0 +1,124 @@
+package mochi
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	ChaosEnabledEnvName = "CHAOS_ENABLED"
+	ChaosHeaderName     = "X-Chaos-Injected"
+)
+
+// ChaosRule injects latency and errors into requests matching Method and
+// PathPrefix. Empty Method or PathPrefix match everything.
+type ChaosRule struct {
+	Method     string
+	PathPrefix string
+
+	Latency       time.Duration
+	LatencyJitter time.Duration
+
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+type ChaosConfig struct {
+	Rules []ChaosRule
+}
+
+type ChaosMiddlewareParams struct {
+	fx.In
+
+	Config ChaosConfig `optional:"true"`
+	Env    AppEnv
+	Logger LoggerService
+}
+
+// NewChaosMiddleware builds a fault-injection middleware for resilience
+// testing. It is a no-op unless CHAOS_ENABLED=true and the app is not running
+// in production. Register it with AsRouterMiddleware.
+func NewChaosMiddleware(params ChaosMiddlewareParams) func(http.Handler) http.Handler {
+	if !chaosEnabled(params.Env) || len(params.Config.Rules) == 0 {
+		return passthroughMiddleware
+	}
+
+	params.Logger.Warn("Chaos middleware enabled", "rules", len(params.Config.Rules))
+
+	return ChaosMiddleware(params.Logger, params.Config.Rules...)
+}
+
+func ChaosMiddleware(logger LoggerService, rules ...ChaosRule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule, ok := matchChaosRule(r, rules)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if delay := rule.delay(); delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+				status := rule.ErrorStatus
+				if status == 0 {
+					status = http.StatusServiceUnavailable
+				}
+
+				logger.Debug("Injecting chaos error", "path", r.URL.Path, "status", status)
+
+				w.Header().Set(ChaosHeaderName, "error")
+				render.Render(w, r, ErrInjectedFault(status))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rule ChaosRule) delay() time.Duration {
+	delay := rule.Latency
+	if rule.LatencyJitter > 0 {
+		delay += rand.N(rule.LatencyJitter)
+	}
+
+	return delay
+}
+
+func matchChaosRule(r *http.Request, rules []ChaosRule) (ChaosRule, bool) {
+	for _, rule := range rules {
+		if rule.Method != "" && !strings.EqualFold(rule.Method, r.Method) {
+			continue
+		}
+
+		if rule.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+			continue
+		}
+
+		return rule, true
+	}
+
+	return ChaosRule{}, false
+}
+
+func chaosEnabled(env AppEnv) bool {
+	return os.Getenv(ChaosEnabledEnvName) == "true" && !env.IsProduction()
+}
+
+func passthroughMiddleware(next http.Handler) http.Handler {
+	return next
+}
@@ -1,48 +1,85 @@
 package mochi
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Claims are the claims of tokens mochi issues. They encode like RFC 7519
+// registered claims, with sub as a string and the times as NumericDate
+// seconds, so other services can verify them with standard JWT libraries.
 type Claims struct {
-	Sub uint      `json:"sub"`
-	Exp time.Time `json:"exp"`
-	Iat time.Time `json:"iat"`
-	Nbf time.Time `json:"nbf"`
-	Aud string    `json:"aud"`
-	Iss string    `json:"iss"`
+	Sub uint             `json:"sub"`
+	Exp *jwt.NumericDate `json:"exp,omitempty"`
+	Iat *jwt.NumericDate `json:"iat,omitempty"`
+	Nbf *jwt.NumericDate `json:"nbf,omitempty"`
+	Aud string           `json:"aud"`
+	Iss string           `json:"iss"`
+	Tid uint             `json:"tid,omitempty"`
+	Jti string           `json:"jti,omitempty"`
+
+	// Act identifies the admin acting as Sub in impersonation tokens.
+	Act *ActorClaims `json:"act,omitempty"`
+
+	// Pty marks tokens for non-user principals, in which case Sub is the
+	// principal's ID. Scope limits the token to space separated scopes.
+	Pty   string `json:"pty,omitempty"`
+	Scope string `json:"scope,omitempty"`
+}
+
+// ActorClaims follows the RFC 8693 act claim.
+type ActorClaims struct {
+	Sub uint `json:"sub"`
 }
 
 const (
-	TokenExpirationTime = time.Hour * 24
+	TokenExpirationTime         = time.Hour * 24
+	ImpersonationExpirationTime = time.Hour
 )
 
 func NewClaims(user User, audience, issuer string) *Claims {
 	now := time.Now()
 
-	return &Claims{
+	claims := &Claims{
 		Sub: user.GetID(),
-		Exp: now.Add(TokenExpirationTime),
-		Iat: now,
-		Nbf: now,
+		Exp: jwt.NewNumericDate(now.Add(TokenExpirationTime)),
+		Iat: jwt.NewNumericDate(now),
+		Nbf: jwt.NewNumericDate(now),
 		Aud: audience,
 		Iss: issuer,
+		Jti: newTokenID(),
+	}
+
+	if tenantUser, ok := user.(TenantUser); ok {
+		claims.Tid = tenantUser.GetTenantID()
 	}
+
+	return claims
+}
+
+// Scopes returns the token's scopes. Tokens without a scope claim are
+// first-party tokens and are not limited by scopes.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
 }
 
 func (c *Claims) GetExpirationTime() (*jwt.NumericDate, error) {
-	return jwt.NewNumericDate(c.Exp), nil
+	return c.Exp, nil
 }
 
 func (c *Claims) GetIssuedAt() (*jwt.NumericDate, error) {
-	return jwt.NewNumericDate(c.Iat), nil
+	return c.Iat, nil
 }
 
 func (c *Claims) GetNotBefore() (*jwt.NumericDate, error) {
-	return jwt.NewNumericDate(c.Nbf), nil
+	return c.Nbf, nil
 }
 
 func (c *Claims) GetIssuer() (string, error) {
@@ -56,3 +93,99 @@ func (c *Claims) GetSubject() (string, error) {
 func (c *Claims) GetAudience() (jwt.ClaimStrings, error) {
 	return []string{c.Aud}, nil
 }
+
+// ExpiresAt returns the expiry, or the zero time for tokens without one.
+func (c *Claims) ExpiresAt() time.Time {
+	if c.Exp == nil {
+		return time.Time{}
+	}
+
+	return c.Exp.Time
+}
+
+type claimsAlias Claims
+
+func (c *Claims) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		*claimsAlias
+		Sub string `json:"sub"`
+	}{
+		claimsAlias: (*claimsAlias)(c),
+		Sub:         strconv.FormatUint(uint64(c.Sub), 10),
+	})
+}
+
+// UnmarshalJSON also accepts the numeric sub and RFC 3339 times of tokens
+// issued by earlier versions.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*claimsAlias
+		Sub json.RawMessage `json:"sub"`
+		Exp json.RawMessage `json:"exp"`
+		Iat json.RawMessage `json:"iat"`
+		Nbf json.RawMessage `json:"nbf"`
+	}{claimsAlias: (*claimsAlias)(c)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Sub) > 0 {
+		var sub string
+		if err := json.Unmarshal(aux.Sub, &sub); err != nil {
+			sub = string(aux.Sub)
+		}
+
+		id, err := strconv.ParseUint(sub, 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid sub claim %s: %w", aux.Sub, err)
+		}
+
+		c.Sub = uint(id)
+	}
+
+	var err error
+
+	if c.Exp, err = parseNumericDate(aux.Exp); err != nil {
+		return fmt.Errorf("invalid exp claim: %w", err)
+	}
+
+	if c.Iat, err = parseNumericDate(aux.Iat); err != nil {
+		return fmt.Errorf("invalid iat claim: %w", err)
+	}
+
+	if c.Nbf, err = parseNumericDate(aux.Nbf); err != nil {
+		return fmt.Errorf("invalid nbf claim: %w", err)
+	}
+
+	return nil
+}
+
+func parseNumericDate(raw json.RawMessage) (*jwt.NumericDate, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var legacy time.Time
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &legacy); err != nil {
+			return nil, err
+		}
+
+		return jwt.NewNumericDate(legacy), nil
+	}
+
+	date := &jwt.NumericDate{}
+	if err := date.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+
+	return date, nil
+}
+
+func newTokenID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
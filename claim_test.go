@@ -0,0 +1,90 @@
+package mochi
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claimTestUser struct {
+	id uint
+}
+
+func (u claimTestUser) IsAdmin() bool { return false }
+func (u claimTestUser) GetID() uint   { return u.id }
+
+var claimTestSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestClaimsParseAsRegisteredClaims(t *testing.T) {
+	keys := NewKeySet(NewHMACSigningKey("test", claimTestSecret))
+
+	token, err := keys.Sign(NewClaims(claimTestUser{id: 42}, "api", "mochi"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	registered := &jwt.RegisteredClaims{}
+
+	_, err = jwt.ParseWithClaims(token, registered, keys.Keyfunc,
+		jwt.WithAudience("api"),
+		jwt.WithIssuer("mochi"),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		t.Fatalf("parse as registered claims: %v", err)
+	}
+
+	if registered.Subject != "42" {
+		t.Errorf("sub = %q, want 42", registered.Subject)
+	}
+
+	if until := time.Until(registered.ExpiresAt.Time); until <= 0 || until > TokenExpirationTime {
+		t.Errorf("exp = %v, want within %v", registered.ExpiresAt.Time, TokenExpirationTime)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+
+	if strings.Contains(string(payload), `"exp":"`) {
+		t.Errorf("exp is not a NumericDate: %s", payload)
+	}
+}
+
+func TestClaimsRoundTrip(t *testing.T) {
+	keys := NewKeySet(NewHMACSigningKey("test", claimTestSecret))
+
+	minted := NewClaims(claimTestUser{id: 7}, "api", "mochi")
+
+	token, err := keys.Sign(minted)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	parsed := &Claims{}
+	if _, err := jwt.ParseWithClaims(token, parsed, keys.Keyfunc); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if parsed.Sub != 7 || parsed.Jti != minted.Jti || !parsed.Exp.Equal(minted.Exp.Truncate(time.Second)) {
+		t.Errorf("parsed %+v, want %+v", parsed, minted)
+	}
+}
+
+func TestClaimsUnmarshalLegacy(t *testing.T) {
+	claims := &Claims{}
+
+	err := claims.UnmarshalJSON([]byte(`{"sub":3,"exp":"2030-01-02T03:04:05Z","iat":"2029-01-02T03:04:05Z","aud":"api"}`))
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if claims.Sub != 3 || claims.ExpiresAt().Year() != 2030 || claims.Iat.Year() != 2029 || claims.Nbf != nil {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
@@ -0,0 +1,99 @@
+package mochi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// RequestKeyFunc identifies requests whose responses are interchangeable.
+type RequestKeyFunc func(r *http.Request) string
+
+// UserRequestKey treats GETs for the same URL by the same user in the same
+// tenant, asking for the same representation, as identical. Anonymous
+// requests share a key per URL. The tenant is taken from the context, or
+// from the tenant header when Coalesce runs before TenantMiddleware.
+func UserRequestKey(auth AuthService) RequestKeyFunc {
+	return func(r *http.Request) string {
+		userID := "anonymous"
+		if user, err := auth.GetUserFromCtx(r.Context()); err == nil {
+			userID = fmt.Sprint(user.GetID())
+		}
+
+		tenant := r.Header.Get(TenantHeaderName)
+		if tenantID, ok := TenantFromCtx(r.Context()); ok {
+			tenant = fmt.Sprint(tenantID)
+		}
+
+		return fmt.Sprintf("%s %q %q %s %s", userID, tenant, r.Header.Get("Accept"), r.Method, r.URL.RequestURI())
+	}
+}
+
+// Coalesce serves concurrent identical GET and HEAD requests from a single
+// run of the handler, so retry storms hit the database once. The shared run
+// is detached from the first caller's cancellation so one client
+// disconnecting doesn't fail the others.
+func Coalesce(key RequestKeyFunc) func(http.Handler) http.Handler {
+	var group singleflight.Group
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, _, _ := group.Do(key(r), func() (interface{}, error) {
+				rec := &coalescedResponse{header: make(http.Header)}
+				next.ServeHTTP(rec, r.WithContext(context.WithoutCancel(r.Context())))
+
+				return rec, nil
+			})
+
+			result.(*coalescedResponse).writeTo(w)
+		})
+	}
+}
+
+// coalescedResponse records a response so it can be replayed to every
+// caller sharing it.
+type coalescedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *coalescedResponse) Header() http.Header {
+	return c.header
+}
+
+func (c *coalescedResponse) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *coalescedResponse) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	return c.body.Write(b)
+}
+
+func (c *coalescedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range c.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	w.Write(c.body.Bytes())
+}
@@ -0,0 +1,167 @@
+package mochi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cond is a parameterized WHERE condition built from column names and
+// values, so values never end up in the SQL text. Columns may be qualified
+// as table.column; invalid names make Build fail rather than reach the
+// query.
+type Cond struct {
+	sql  string
+	args []interface{}
+	err  error
+}
+
+// Eq matches rows where column equals value. A nil value matches NULL.
+func Eq(column string, value interface{}) Cond {
+	if value == nil {
+		return IsNull(column)
+	}
+
+	return compare(column, "=", value)
+}
+
+// Ne matches rows where column differs from value.
+func Ne(column string, value interface{}) Cond {
+	if value == nil {
+		return IsNotNull(column)
+	}
+
+	return compare(column, "<>", value)
+}
+
+func Gt(column string, value interface{}) Cond {
+	return compare(column, ">", value)
+}
+
+func Gte(column string, value interface{}) Cond {
+	return compare(column, ">=", value)
+}
+
+func Lt(column string, value interface{}) Cond {
+	return compare(column, "<", value)
+}
+
+func Lte(column string, value interface{}) Cond {
+	return compare(column, "<=", value)
+}
+
+// In matches rows where column is one of values, which must be a slice. An
+// empty slice matches nothing.
+func In(column string, values interface{}) Cond {
+	return compare(column, "IN", values)
+}
+
+// Like matches column against a LIKE pattern.
+func Like(column string, pattern string) Cond {
+	return compare(column, "LIKE", pattern)
+}
+
+func IsNull(column string) Cond {
+	if err := checkCondColumn(column); err != nil {
+		return Cond{err: err}
+	}
+
+	return Cond{sql: fmt.Sprintf("%s IS NULL", column)}
+}
+
+func IsNotNull(column string) Cond {
+	if err := checkCondColumn(column); err != nil {
+		return Cond{err: err}
+	}
+
+	return Cond{sql: fmt.Sprintf("%s IS NOT NULL", column)}
+}
+
+// Raw wraps a hand-written condition, such as an existing query string, so
+// it can be combined with built ones.
+func Raw(sql string, args ...interface{}) Cond {
+	return Cond{sql: sql, args: args}
+}
+
+// And matches rows matching every cond. Empty conds are skipped, and And of
+// nothing matches every row.
+func And(conds ...Cond) Cond {
+	return join(" AND ", conds)
+}
+
+// Or matches rows matching any cond. Empty conds are skipped.
+func Or(conds ...Cond) Cond {
+	return join(" OR ", conds)
+}
+
+// Not negates cond.
+func Not(cond Cond) Cond {
+	if cond.err != nil || cond.sql == "" {
+		return cond
+	}
+
+	return Cond{sql: fmt.Sprintf("NOT (%s)", cond.sql), args: cond.args}
+}
+
+// Build returns the condition and its arguments for use wherever a query
+// string is accepted. An empty Cond builds an empty query.
+func (c Cond) Build() (string, []interface{}, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+
+	return c.sql, c.args, nil
+}
+
+func compare(column, operator string, value interface{}) Cond {
+	if err := checkCondColumn(column); err != nil {
+		return Cond{err: err}
+	}
+
+	placeholder := "?"
+	if operator == "IN" {
+		placeholder = "(?)"
+	}
+
+	return Cond{
+		sql:  fmt.Sprintf("%s %s %s", column, operator, placeholder),
+		args: []interface{}{value},
+	}
+}
+
+func join(separator string, conds []Cond) Cond {
+	kept := make([]Cond, 0, len(conds))
+
+	for _, cond := range conds {
+		if cond.err != nil {
+			return cond
+		}
+
+		if cond.sql != "" {
+			kept = append(kept, cond)
+		}
+	}
+
+	if len(kept) == 1 {
+		return kept[0]
+	}
+
+	parts := make([]string, 0, len(kept))
+	args := []interface{}{}
+
+	for _, cond := range kept {
+		parts = append(parts, fmt.Sprintf("(%s)", cond.sql))
+		args = append(args, cond.args...)
+	}
+
+	return Cond{sql: strings.Join(parts, separator), args: args}
+}
+
+func checkCondColumn(column string) error {
+	for _, part := range strings.Split(column, ".") {
+		if !isValidColumnName(part) {
+			return fmt.Errorf("invalid column name %q", column)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package mochi
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+const (
+	maxEmailLength = 254
+	minPhoneDigits = 7
+	maxPhoneDigits = 15
+)
+
+// EmailAddress is an email address normalized to lowercase, so lookups and
+// unique indexes don't depend on how the user typed it. JSON input and list
+// filters are normalized with ParseEmailAddress, and it is stored as a plain
+// string column.
+type EmailAddress string
+
+// ParseEmailAddress trims and lowercases an address such as "Ada@Example.com",
+// rejecting display names and domains without a dot.
+func ParseEmailAddress(value string) (EmailAddress, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+
+	addr, err := mail.ParseAddress(normalized)
+	if err != nil || addr.Name != "" || addr.Address != normalized || len(normalized) > maxEmailLength {
+		return "", fmt.Errorf("%w: invalid email address %q", ErrValidation, value)
+	}
+
+	_, domain, _ := strings.Cut(normalized, "@")
+	if !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", fmt.Errorf("%w: invalid email address %q", ErrValidation, value)
+	}
+
+	return EmailAddress(normalized), nil
+}
+
+// Validate checks that e is a normalized email address.
+func (e EmailAddress) Validate() error {
+	normalized, err := ParseEmailAddress(string(e))
+	if err != nil {
+		return err
+	}
+
+	if normalized != e {
+		return fmt.Errorf("%w: email address %q is not normalized", ErrValidation, string(e))
+	}
+
+	return nil
+}
+
+// Domain returns the part after the @.
+func (e EmailAddress) Domain() string {
+	_, domain, _ := strings.Cut(string(e), "@")
+
+	return domain
+}
+
+func (e EmailAddress) String() string {
+	return string(e)
+}
+
+// UnmarshalText normalizes JSON and form input. An empty value is kept so
+// optional fields can be left unset.
+func (e *EmailAddress) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*e = ""
+		return nil
+	}
+
+	email, err := ParseEmailAddress(string(text))
+	if err != nil {
+		return err
+	}
+
+	*e = email
+
+	return nil
+}
+
+// PhoneNumber is a phone number in E.164 format, such as "+14155550123".
+type PhoneNumber string
+
+// ParsePhoneNumber normalizes an international number to E.164, dropping
+// spaces, dots, dashes, and parentheses and accepting a 00 prefix in place
+// of +. Numbers without a country code are rejected since the country
+// can't be guessed.
+func ParsePhoneNumber(value string) (PhoneNumber, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', '\t':
+			return -1
+		}
+
+		return r
+	}, value)
+
+	if strings.HasPrefix(normalized, "00") {
+		normalized = "+" + normalized[2:]
+	}
+
+	digits, ok := strings.CutPrefix(normalized, "+")
+	if !ok || len(digits) < minPhoneDigits || len(digits) > maxPhoneDigits || digits[0] == '0' {
+		return "", fmt.Errorf("%w: invalid phone number %q, expected international format like +14155550123", ErrValidation, value)
+	}
+
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("%w: invalid phone number %q, expected international format like +14155550123", ErrValidation, value)
+		}
+	}
+
+	return PhoneNumber(normalized), nil
+}
+
+// Validate checks that p is in E.164 format.
+func (p PhoneNumber) Validate() error {
+	normalized, err := ParsePhoneNumber(string(p))
+	if err != nil {
+		return err
+	}
+
+	if normalized != p {
+		return fmt.Errorf("%w: phone number %q is not in E.164 format", ErrValidation, string(p))
+	}
+
+	return nil
+}
+
+func (p PhoneNumber) String() string {
+	return string(p)
+}
+
+// UnmarshalText normalizes JSON and form input. An empty value is kept so
+// optional fields can be left unset.
+func (p *PhoneNumber) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*p = ""
+		return nil
+	}
+
+	phone, err := ParsePhoneNumber(string(text))
+	if err != nil {
+		return err
+	}
+
+	*p = phone
+
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -13,7 +12,7 @@ import (
 
 type ResourceContextKey int
 
-type ResourceRequestConstructor[M Resource] func(*http.Request, User) (M, error)
+type ResourceRequestConstructor[M any] func(*http.Request, User) (M, error)
 
 type Route struct {
 	Method  string
@@ -21,7 +20,21 @@ type Route struct {
 	Handler http.HandlerFunc
 }
 
-type Controller[M Resource] interface {
+const (
+	DefaultIDParamName = "id"
+)
+
+type ControllerRoute string
+
+const (
+	RouteList   ControllerRoute = "list"
+	RouteCreate ControllerRoute = "create"
+	RouteGet    ControllerRoute = "get"
+	RouteUpdate ControllerRoute = "update"
+	RouteDelete ControllerRoute = "delete"
+)
+
+type Controller[M Resource[K], K comparable] interface {
 	List(w http.ResponseWriter, r *http.Request)
 	Create(w http.ResponseWriter, r *http.Request)
 	Get(w http.ResponseWriter, r *http.Request)
@@ -35,141 +48,334 @@ type Controller[M Resource] interface {
 	GetRouter() *chi.Mux
 }
 
-type UserResourceAccessFunc[M Resource] func(User, M) error
+type UserResourceAccessFunc[M any] func(User, M) error
 
-func defaultUserResourceAccessFunc[M Resource](u User, item M) error {
-	return fmt.Errorf("user access func not implemented")
+// OwnedModel is implemented by models that belong to a single user.
+type OwnedModel interface {
+	GetUserID() uint
 }
 
-type controller[M Resource] struct {
-	additionalDetailRoutes []Route
-	contextKey             ResourceContextKey
+// OwnerAccessFunc grants access when the item belongs to the user. It is the
+// default access func and requires M to implement OwnedModel.
+func OwnerAccessFunc[M any](u User, item M) error {
+	owned, ok := any(item).(OwnedModel)
+	if !ok {
+		return fmt.Errorf("%T does not implement OwnedModel, provide a user access func", item)
+	}
+
+	if owned.GetUserID() != u.GetID() {
+		return fmt.Errorf("user %d does not own item", u.GetID())
+	}
 
-	auth   AuthService
-	logger LoggerService
-	svc    Service[M]
-	Router *chi.Mux
+	return nil
+}
 
+type controller[M Resource[K], K comparable] struct {
+	additionalDetailRoutes []Route
+	associations           map[string]string
+	associationAccessFuncs map[string]UserResourceAccessFunc[interface{}]
+	contextKey             ResourceContextKey
+	cachePolicies          map[ControllerRoute]CachePolicy
+	deprecatedRoutes       map[ControllerRoute]Deprecation
+	disabledRoutes         map[ControllerRoute]bool
+	middlewares            []func(http.Handler) http.Handler
+
+	archive ArchiveService
+	auth    AuthService
+	logger  LoggerService
+	metrics MetricsService
+	svc     Service[M, K]
+	readSvc ReadService[M, K]
+	Router  *chi.Mux
+
+	errorHandler   ErrorHandler
+	idParamName    string
+	idParser       IDParser[K]
+	admin          bool
+	coalesce       bool
+	lookupField    string
+	lookupParser   IDParser[string]
+	slugHistory    DBService
+	filterFields   []FilterField
+	publicRead     bool
+	readScopes     []string
+	sortableFields map[string]bool
+	transferUsers  UserService
+	writeScopes    []string
 	userAccessFunc UserResourceAccessFunc[M]
 
 	createRequestConstructor ResourceRequestConstructor[M]
 	updateRequestConstructor ResourceRequestConstructor[M]
 }
 
-type ControllerOption[M Resource] func(*controller[M])
+type ControllerOption[M Resource[K], K comparable] func(*controller[M, K])
 
-func NewController[M Resource](
-	svc Service[M],
+func NewController[M Resource[K], K comparable](
+	svc Service[M, K],
 	logger LoggerService,
 	authSvc AuthService,
 	createRequestConstructor ResourceRequestConstructor[M],
 	updateRequestConstructor ResourceRequestConstructor[M],
-	opts ...ControllerOption[M],
-) Controller[M] {
-	ctrl := &controller[M]{
+	opts ...ControllerOption[M, K],
+) Controller[M, K] {
+	ctrl := &controller[M, K]{
 		additionalDetailRoutes: make([]Route, 0),
+		disabledRoutes:         make(map[ControllerRoute]bool),
 
-		auth:   authSvc,
-		logger: logger,
-		svc:    svc,
+		auth:    authSvc,
+		logger:  logger,
+		metrics: NewNoopMetricsService(),
+		svc:     svc,
+		readSvc: svc,
 
-		userAccessFunc: defaultUserResourceAccessFunc[M],
+		errorHandler:   DefaultErrorHandler,
+		idParamName:    DefaultIDParamName,
+		idParser:       ParseID[K],
+		userAccessFunc: OwnerAccessFunc[M],
 
 		createRequestConstructor: createRequestConstructor,
 		updateRequestConstructor: updateRequestConstructor,
 	}
 
+	if isViewModel[M]() {
+		WithReadOnly[M]()(ctrl)
+	}
+
 	for _, opt := range opts {
 		opt(ctrl)
 	}
 
+	ctrl.mount()
+
+	return ctrl
+}
+
+// mount builds the router once options are applied.
+func (ctrl *controller[M, K]) mount() {
 	ctrl.Router = chi.NewRouter()
-	ctrl.Router.Use(authSvc.AuthRequired())
+	ctrl.Router.Use(PrimaryForWrites)
+
+	switch {
+	case ctrl.admin:
+		ctrl.Router.Use(ctrl.auth.AuthRequired())
+		ctrl.Router.Use(ctrl.auth.AdminRequired())
+	case ctrl.publicRead:
+		ctrl.Router.Use(ctrl.auth.AuthOptional())
+		ctrl.Router.Use(ctrl.writeAuthMiddleware)
+	default:
+		ctrl.Router.Use(ctrl.auth.AuthRequired())
+	}
+
+	if len(ctrl.readScopes) > 0 || len(ctrl.writeScopes) > 0 {
+		ctrl.Router.Use(ctrl.scopeMiddleware)
+	}
+
+	ctrl.Router.Use(ctrl.middlewares...)
+
+	if ctrl.coalesce {
+		ctrl.Router.Use(Coalesce(UserRequestKey(ctrl.auth)))
+	}
+
+	if ctrl.routeEnabled(RouteList) {
+		ctrl.Router.With(ctrl.routeMiddlewares(RouteList)...).Get("/", ctrl.List)
+	}
+
+	if ctrl.routeEnabled(RouteCreate) {
+		ctrl.Router.With(ctrl.routeMiddlewares(RouteCreate)...).Post("/", ctrl.Create)
+	}
+
+	if ctrl.archive != nil {
+		ctrl.Router.Get("/archive", ctrl.handleArchiveList)
+		ctrl.Router.Get(fmt.Sprintf("/archive/{%s}", ctrl.idParamName), ctrl.handleArchiveGet)
+	}
+
+	if ctrl.transferUsers != nil && ctrl.routeEnabled(RouteTransfer) && ctrl.routeEnabled(RouteUpdate) {
+		ctrl.Router.With(ctrl.routeMiddlewares(RouteTransfer)...).Post(TransferPath, ctrl.handleTransfer)
+	}
 
-	ctrl.Router.Get("/", ctrl.List)
-	ctrl.Router.Post("/", ctrl.Create)
+	if ctrl.hasDetailRoutes() {
+		ctrl.Router.Route(fmt.Sprintf("/{%s}", ctrl.idParamName), func(r chi.Router) {
+			r.Use(ctrl.ItemContextMiddleware)
+			r.Use(ctrl.UserAccessMiddleware)
 
-	ctrl.Router.Route("/{id}", func(r chi.Router) {
-		r.Use(ctrl.ItemContextMiddleware)
-		r.Use(ctrl.UserAccessMiddleware)
+			if ctrl.routeEnabled(RouteGet) {
+				r.With(ctrl.routeMiddlewares(RouteGet)...).Get("/", ctrl.Get)
+			}
 
-		r.Get("/", ctrl.Get)
-		r.Patch("/", ctrl.Update)
-		r.Delete("/", ctrl.Delete)
+			if ctrl.routeEnabled(RouteUpdate) {
+				r.With(ctrl.routeMiddlewares(RouteUpdate)...).Patch("/", ctrl.Update)
+			}
 
-		for _, route := range ctrl.additionalDetailRoutes {
-			r.Method(route.Method, route.Path, route.Handler)
+			if ctrl.routeEnabled(RouteDelete) {
+				r.With(ctrl.routeMiddlewares(RouteDelete)...).Delete("/", ctrl.Delete)
+			}
+
+			if ctrl.routeEnabled(RouteUpdate) {
+				for path, association := range ctrl.associations {
+					r.Put(path, ctrl.handleAssociation(association, AssociationReplace))
+					r.Post(path, ctrl.handleAssociation(association, AssociationAppend))
+					r.Delete(path, ctrl.handleAssociation(association, AssociationRemove))
+				}
+			}
+
+			for _, route := range ctrl.additionalDetailRoutes {
+				r.Method(route.Method, route.Path, route.Handler)
+			}
+		})
+	}
+}
+
+// writeAuthMiddleware requires a user for unsafe methods on public
+// controllers.
+func (c *controller[M, K]) writeAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isSafeMethod(r.Method) {
+			if _, err := c.auth.GetUserFromCtx(r.Context()); err != nil {
+				c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+				return
+			}
 		}
+
+		next.ServeHTTP(w, r)
 	})
+}
 
-	return ctrl
+// routeMiddlewares returns the deprecation and cache policy middlewares
+// configured for a single route.
+func (c *controller[M, K]) routeMiddlewares(route ControllerRoute) []func(http.Handler) http.Handler {
+	middlewares := []func(http.Handler) http.Handler{}
+
+	if deprecation, ok := c.deprecatedRoutes[route]; ok {
+		middlewares = append(middlewares, Deprecate(deprecation, c.logger, c.metrics))
+	}
+
+	if policy, ok := c.cachePolicies[route]; ok {
+		middlewares = append(middlewares, CacheControl(policy))
+	}
+
+	return middlewares
 }
 
-func (c *controller[M]) List(w http.ResponseWriter, r *http.Request) {
+// scopeMiddleware requires the read scopes for safe methods and the write
+// scopes for everything else.
+func (c *controller[M, K]) scopeMiddleware(next http.Handler) http.Handler {
+	read := c.auth.RequireScope(c.readScopes...)(next)
+	write := c.auth.RequireScope(c.writeScopes...)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSafeMethod(r.Method) {
+			if _, err := c.auth.GetUserFromCtx(r.Context()); err != nil && c.publicRead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			read.ServeHTTP(w, r)
+		} else {
+			write.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (c *controller[M, K]) routeEnabled(route ControllerRoute) bool {
+	return !c.disabledRoutes[route]
+}
+
+func (c *controller[M, K]) hasDetailRoutes() bool {
+	return c.routeEnabled(RouteGet) ||
+		c.routeEnabled(RouteUpdate) ||
+		c.routeEnabled(RouteDelete) ||
+		len(c.additionalDetailRoutes) > 0 ||
+		len(c.associations) > 0
+}
+
+func (c *controller[M, K]) List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	user, err := c.auth.GetUserFromCtx(ctx)
+	if err != nil && !c.publicRead {
+		c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+		return
+	}
+
+	ctx, err = c.sortedContext(ctx, r)
 	if err != nil {
-		render.Render(w, r, ErrUnauthorized(err))
+		c.errorHandler(w, r, InvalidRequest(err))
 		return
 	}
 
-	items, err := c.svc.ListByUser(ctx, user.GetID())
+	filters, err := ParseFilters(r.URL.Query(), c.filterFields)
 	if err != nil {
-		c.logger.Error("failed to list items", "error", err)
-		render.Render(w, r, ErrUnknown(err))
+		c.errorHandler(w, r, InvalidRequest(err))
+		return
+	}
 
+	if len(filters) > 0 {
+		ctx = WithFilters[M](ctx, filters...)
+	}
+
+	ctx, info := WithResultInfo(ctx)
+
+	items, err := c.listItems(ctx, user)
+	if err != nil {
+		c.renderError(w, r, "failed to list items", err)
 		return
 	}
 
-	respList := []render.Renderer{}
-	for _, item := range items {
-		respList = append(respList, item.ToDTO())
+	setTruncationHeaders(w, info)
+
+	respList := make([]render.Renderer, len(items))
+	for i, item := range items {
+		respList[i] = c.dto(r.Context(), item)
 	}
 
-	render.RenderList(w, r, respList)
+	if err := renderList(w, r, respList); err != nil {
+		c.renderError(w, r, "failed to render items", err)
+	}
 }
 
-func (c *controller[M]) Create(w http.ResponseWriter, r *http.Request) {
+func (c *controller[M, K]) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	user, err := c.auth.GetUserFromCtx(ctx)
 	if err != nil {
-		render.Render(w, r, ErrUnauthorized(err))
+		c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
 		return
 	}
 
 	newItem, err := c.createRequestConstructor(r, user)
 	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(err))
+		c.errorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	if err := ValidateEnums(newItem); err != nil {
+		c.errorHandler(w, r, err)
 		return
 	}
 
 	item, err := c.svc.CreateOne(ctx, user.GetID(), newItem)
 	if err != nil {
-		c.logger.Error("failed to create item", "error", err)
-		render.Render(w, r, ErrUnknown(err))
-
+		c.renderError(w, r, "failed to create item", err)
 		return
 	}
 
 	render.Status(r, http.StatusCreated)
-	render.Render(w, r, item.ToDTO())
+	render.Render(w, r, c.dto(ctx, item))
 }
 
-func (c *controller[M]) Get(w http.ResponseWriter, r *http.Request) {
+func (c *controller[M, K]) Get(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	item, err := c.ItemFromContext(ctx)
 	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(err))
+		c.errorHandler(w, r, InvalidRequest(err))
 		return
 	}
 
-	render.Render(w, r, item.ToDTO())
+	render.Render(w, r, c.dto(ctx, item))
 }
 
-func (c *controller[M]) Update(w http.ResponseWriter, r *http.Request) {
+func (c *controller[M, K]) Update(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// user is already checked in UserAccessMiddleware so we can safely ignore the error
@@ -177,48 +383,96 @@ func (c *controller[M]) Update(w http.ResponseWriter, r *http.Request) {
 
 	item, err := c.ItemFromContext(ctx)
 	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(err))
+		c.errorHandler(w, r, InvalidRequest(err))
 		return
 	}
 
 	update, err := c.updateRequestConstructor(r, user)
 	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(err))
+		c.errorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	if err := ValidateEnums(update); err != nil {
+		c.errorHandler(w, r, err)
 		return
 	}
 
 	updatedItem, err := c.svc.UpdateOne(ctx, item.GetID(), update)
 	if err != nil {
-		c.logger.Error("failed to update item", "error", err)
-		render.Render(w, r, ErrUnknown(err))
-
+		c.renderError(w, r, "failed to update item", err)
 		return
 	}
 
-	render.Render(w, r, updatedItem.ToDTO())
+	render.Render(w, r, c.dto(ctx, updatedItem))
 }
 
-func (c *controller[M]) Delete(w http.ResponseWriter, r *http.Request) {
+func (c *controller[M, K]) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	item, err := c.ItemFromContext(ctx)
 	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(err))
+		c.errorHandler(w, r, InvalidRequest(err))
 		return
 	}
 
 	err = c.svc.DeleteOne(ctx, item.GetID())
 	if err != nil {
-		c.logger.Error("failed to delete item", "error", err)
-		render.Render(w, r, ErrUnknown(err))
-
+		c.renderError(w, r, "failed to delete item", err)
 		return
 	}
 
 	render.NoContent(w, r)
 }
 
-func (c *controller[M]) ItemFromContext(ctx context.Context) (M, error) {
+// listItems lists the user's items, every user's items on admin
+// controllers, or the public listing for anonymous requests to public
+// controllers.
+func (c *controller[M, K]) listItems(ctx context.Context, user User) ([]M, error) {
+	if c.admin {
+		adminSvc, ok := c.readSvc.(AdminReadService[M, K])
+		if !ok {
+			return nil, fmt.Errorf("read service does not support listing all users")
+		}
+
+		return adminSvc.ListAll(ctx)
+	}
+
+	if user != nil {
+		return c.readSvc.ListByUser(ctx, user.GetID())
+	}
+
+	publicSvc, ok := c.readSvc.(PublicReadService[M, K])
+	if !ok {
+		return nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("read service does not support public listing"))
+	}
+
+	return publicSvc.ListPublic(ctx)
+}
+
+// sortedContext applies the sort query parameter, restricted to the
+// controller's sortable fields.
+func (c *controller[M, K]) sortedContext(ctx context.Context, r *http.Request) (context.Context, error) {
+	value := r.URL.Query().Get(SortQueryParam)
+	if value == "" {
+		return ctx, nil
+	}
+
+	keys, err := ParseSort(value)
+	if err != nil {
+		return ctx, err
+	}
+
+	for _, key := range keys {
+		if !c.sortableFields[key.Field] {
+			return ctx, fmt.Errorf("cannot sort by %q", key.Field)
+		}
+	}
+
+	return WithSort[M](ctx, keys...), nil
+}
+
+func (c *controller[M, K]) ItemFromContext(ctx context.Context) (M, error) {
 	var item M
 
 	item, ok := ctx.Value(c.contextKey).(M)
@@ -229,72 +483,170 @@ func (c *controller[M]) ItemFromContext(ctx context.Context) (M, error) {
 	return item, nil
 }
 
-func (c *controller[M]) ItemContextMiddleware(next http.Handler) http.Handler {
+func (c *controller[M, K]) ItemContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		itemID := chi.URLParam(r, "id")
+		itemID := chi.URLParam(r, c.idParamName)
 		if itemID == "" {
-			render.Render(w, r, ErrNotFound)
+			c.errorHandler(w, r, ErrRecordNotFound)
 			return
 		}
 
-		itemIDInt, err := strconv.Atoi(itemID)
+		item, err := c.lookupItem(ctx, r.Method, itemID)
 		if err != nil {
-			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("failed to parse ID: %w", err)))
+			c.renderError(w, r, "failed to look up item", err)
 			return
 		}
 
-		item, err := c.svc.GetOne(ctx, uint(itemIDInt))
-		if err != nil {
-			if errors.Is(err, ErrRecordNotFound) {
-				render.Render(w, r, ErrNotFound)
-			} else {
-				c.logger.Error("failed to look up item", "error", err)
-				render.Render(w, r, ErrUnknown(err))
+		ctxWithTask := context.WithValue(r.Context(), c.contextKey, item)
+
+		next.ServeHTTP(w, r.WithContext(ctxWithTask))
+	})
+}
+
+// lookupItem loads the detail route item, from the read service for safe
+// methods and from the write service otherwise so writes never act on a
+// stale read model.
+func (c *controller[M, K]) lookupItem(ctx context.Context, method, itemID string) (M, error) {
+	var svc ReadService[M, K] = c.svc
+	if method == http.MethodGet || method == http.MethodHead {
+		svc = c.readSvc
+	}
+
+	if c.lookupField != "" {
+		if c.lookupParser != nil {
+			parsed, err := c.lookupParser(itemID)
+			if err != nil {
+				var item M
+				return item, err
 			}
 
-			return
+			itemID = parsed
 		}
 
-		ctxWithTask := context.WithValue(r.Context(), c.contextKey, item)
+		item, err := svc.GetOneByField(ctx, c.lookupField, itemID)
+		if errors.Is(err, ErrRecordNotFound) && c.slugHistory != nil {
+			return c.lookupSlugHistory(ctx, svc, itemID)
+		}
 
-		next.ServeHTTP(w, r.WithContext(ctxWithTask))
-	})
+		return item, err
+	}
+
+	parsedID, err := c.idParser(itemID)
+	if err != nil {
+		var item M
+		return item, InvalidRequest(err)
+	}
+
+	return svc.GetOne(ctx, parsedID)
 }
 
-func (c *controller[M]) UserAccessMiddleware(next http.Handler) http.Handler {
+// lookupSlugHistory loads the item that used to have slug.
+func (c *controller[M, K]) lookupSlugHistory(ctx context.Context, svc ReadService[M, K], slug string) (M, error) {
+	var item M
+
+	table, err := modelTable[M]()
+	if err != nil {
+		return item, err
+	}
+
+	entry, err := FindSlugHistory(ctx, c.slugHistory, table, slug)
+	if err != nil {
+		return item, err
+	}
+
+	itemID, err := c.idParser(entry.RecordID)
+	if err != nil {
+		return item, fmt.Errorf("invalid slug history record ID %q: %w", entry.RecordID, err)
+	}
+
+	return svc.GetOne(ctx, itemID)
+}
+
+func (c *controller[M, K]) UserAccessMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
+		// admins can access any item
+		if c.admin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		user, err := c.auth.GetUserFromCtx(ctx)
-		if err != nil {
-			render.Render(w, r, ErrUnauthorized(err))
+		if err != nil && !(c.publicRead && isSafeMethod(r.Method)) {
+			c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
 			return
 		}
 
 		item, err := c.ItemFromContext(ctx)
 		if err != nil {
-			render.Render(w, r, ErrInvalidRequest(err))
+			c.errorHandler(w, r, InvalidRequest(err))
 			return
 		}
 
-		accessErr := c.userAccessFunc(user, item)
-		if accessErr != nil {
-			render.Render(w, r, ErrNotFound)
+		if user != nil && c.userAccessFunc(user, item) == nil {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		// public items are readable by anyone, see WithPublicRead
+		if c.publicRead && isSafeMethod(r.Method) {
+			public, err := c.isPublic(ctx, item)
+			if err != nil {
+				c.renderError(w, r, "failed to check public item", err)
+				return
+			}
+
+			if public {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		c.errorHandler(w, r, ErrRecordNotFound)
 	})
 }
 
-func (c *controller[M]) GetRouter() *chi.Mux {
+func (c *controller[M, K]) isPublic(ctx context.Context, item M) (bool, error) {
+	publicSvc, ok := c.readSvc.(PublicReadService[M, K])
+	if !ok {
+		return false, nil
+	}
+
+	public, err := publicSvc.IsPublic(ctx, item.GetID())
+	if ErrorStatus(err) == http.StatusUnauthorized {
+		return false, nil
+	}
+
+	return public, err
+}
+
+// dto renders item for the request's viewer, stripping fields it may not
+// see.
+func (c *controller[M, K]) dto(ctx context.Context, item M) render.Renderer {
+	dto := toDTO[K](ctx, item)
+	return ApplyVisibility(dto, NewViewer(ctx, c.auth, item))
+}
+
+// renderError logs and reports server-side failures and hands err to the
+// error handler.
+func (c *controller[M, K]) renderError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if status := ErrorStatus(err); status >= http.StatusInternalServerError {
+		c.logger.WithContext(r.Context()).Error(msg, "error", err)
+		ReportError(r, err, status)
+	}
+
+	c.errorHandler(w, r, err)
+}
+
+func (c *controller[M, K]) GetRouter() *chi.Mux {
 	return c.Router
 }
 
-func WithDetailRoute[M Resource](method, path string, handler http.HandlerFunc) ControllerOption[M] {
-	return func(c *controller[M]) {
+func WithDetailRoute[M Resource[K], K comparable](method, path string, handler http.HandlerFunc) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
 		c.additionalDetailRoutes = append(c.additionalDetailRoutes, Route{
 			Method:  method,
 			Path:    path,
@@ -303,14 +655,184 @@ func WithDetailRoute[M Resource](method, path string, handler http.HandlerFunc)
 	}
 }
 
-func WithContextKey[M Resource](key ResourceContextKey) ControllerOption[M] {
-	return func(c *controller[M]) {
+func WithContextKey[M Resource[K], K comparable](key ResourceContextKey) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
 		c.contextKey = key
 	}
 }
 
-func WithUserAccessFunc[M Resource](accessFunc UserResourceAccessFunc[M]) ControllerOption[M] {
-	return func(c *controller[M]) {
+func WithUserAccessFunc[M Resource[K], K comparable](accessFunc UserResourceAccessFunc[M]) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
 		c.userAccessFunc = accessFunc
 	}
 }
+
+// WithIDParamName sets the chi URL parameter used for the item ID, so nested
+// controllers don't collide on {id}.
+func WithIDParamName[M Resource[K], K comparable](name string) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.idParamName = name
+	}
+}
+
+// WithIDParser overrides how the detail route URL parameter is converted
+// into a model ID.
+func WithIDParser[M Resource[K], K comparable](parser IDParser[K]) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.idParser = parser
+	}
+}
+
+// WithLookupField resolves detail routes by an alternate unique column, such
+// as a slug, instead of the ID.
+func WithLookupField[M Resource[K], K comparable](field string) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.lookupField = field
+	}
+}
+
+// WithPublicID resolves detail routes by the prefixed public ID of a
+// PublicIDModel instead of the numeric ID. IDs with the wrong prefix are
+// rejected as not found by PublicIDParser.
+func WithPublicID[M Resource[K], K comparable]() ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.lookupField = PublicIDColumn
+
+		if public, ok := newModelPtr[M]().(PublicIDModel); ok {
+			c.lookupParser = PublicIDParser(public.PublicIDPrefix())
+		}
+	}
+}
+
+// WithSlugHistory makes detail routes using WithLookupField also resolve
+// slugs an item had before, as recorded in SlugHistory for SlugVersioned
+// slugs.
+func WithSlugHistory[M Resource[K], K comparable](db DBService) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.slugHistory = db
+	}
+}
+
+// WithErrorHandler overrides how the controller renders errors. Handlers
+// receive the classified error and can use ErrorStatus to pick a status code.
+func WithErrorHandler[M Resource[K], K comparable](handler ErrorHandler) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.errorHandler = handler
+	}
+}
+
+// WithMiddleware adds middlewares to the controller router. They run after
+// authentication, so the user is available from the request context.
+func WithMiddleware[M Resource[K], K comparable](middlewares ...func(http.Handler) http.Handler) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithReadService serves List and Get from a dedicated read service while
+// writes keep using the controller's Service.
+func WithReadService[M Resource[K], K comparable](readSvc ReadService[M, K]) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.readSvc = readSvc
+	}
+}
+
+// WithReadOnly mounts only the List and Get routes.
+func WithReadOnly[M Resource[K], K comparable]() ControllerOption[M, K] {
+	return WithDisabledRoutes[M, K](RouteCreate, RouteUpdate, RouteDelete)
+}
+
+// WithDisabledRoutes skips mounting the given CRUD routes.
+func WithDisabledRoutes[M Resource[K], K comparable](routes ...ControllerRoute) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		for _, route := range routes {
+			c.disabledRoutes[route] = true
+		}
+	}
+}
+
+// WithSortableFields allows List to be ordered by the given columns through
+// the sort query parameter. Without it, sort requests are rejected.
+func WithSortableFields[M Resource[K], K comparable](fields ...string) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		if c.sortableFields == nil {
+			c.sortableFields = make(map[string]bool)
+		}
+
+		for _, field := range fields {
+			c.sortableFields[field] = true
+		}
+	}
+}
+
+// WithFilterFields allows List to be filtered by the given fields through
+// query parameters.
+func WithFilterFields[M Resource[K], K comparable](fields ...FilterField) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.filterFields = append(c.filterFields, fields...)
+	}
+}
+
+// WithScopes requires scoped tokens to carry the read scope for List and
+// Get and the write scope for the other routes, such as "tasks:read" and
+// "tasks:write". First-party tokens without scopes are not limited.
+func WithScopes[M Resource[K], K comparable](read, write string) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.readScopes = append(c.readScopes, read)
+		c.writeScopes = append(c.writeScopes, write)
+	}
+}
+
+// WithPublicRead serves List and Get without authentication while the
+// other routes still require it. Anonymous lists use the read service's
+// ListPublic, and items can be read by ID when IsPublic allows it or the
+// user may access them. With the default Service nothing is public until
+// WithPublicListQuery is set.
+func WithPublicRead[M Resource[K], K comparable]() ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.publicRead = true
+	}
+}
+
+// WithCachePolicy sets the Cache-Control policy for a route, for example
+// WithCachePolicy[*Task](RouteGet, CachePolicy{MaxAge: 5 * time.Minute, Private: true, ETag: true}).
+func WithCachePolicy[M Resource[K], K comparable](route ControllerRoute, policy CachePolicy) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		if c.cachePolicies == nil {
+			c.cachePolicies = make(map[ControllerRoute]CachePolicy)
+		}
+
+		c.cachePolicies[route] = policy
+	}
+}
+
+// WithDeprecation marks routes as deprecated, or the whole controller when
+// no routes are given. metrics may be nil.
+func WithDeprecation[M Resource[K], K comparable](deprecation Deprecation, metrics MetricsService, routes ...ControllerRoute) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		if metrics != nil {
+			c.metrics = metrics
+		}
+
+		if len(routes) == 0 {
+			c.middlewares = append(c.middlewares, Deprecate(deprecation, c.logger, c.metrics))
+			return
+		}
+
+		if c.deprecatedRoutes == nil {
+			c.deprecatedRoutes = make(map[ControllerRoute]Deprecation)
+		}
+
+		for _, route := range routes {
+			c.deprecatedRoutes[route] = deprecation
+		}
+	}
+}
+
+// WithCoalescing shares one response between concurrent identical List and
+// Get requests from the same user.
+func WithCoalescing[M Resource[K], K comparable]() ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.coalesce = true
+	}
+}
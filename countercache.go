@@ -0,0 +1,272 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	CounterCacheTaskName  = "reconcile-counter-caches"
+	counterCacheDeltasKey = "mochi:counter_cache_deltas"
+)
+
+// CounterCache keeps Column of the parent Table equal to the number of
+// child rows whose ForeignKey points at it, such as users.tasks_count for
+// tasks.user_id.
+type CounterCache struct {
+	ForeignKey string
+	Table      string
+	Column     string
+}
+
+// CounterCacheModel is implemented by child models that maintain counter
+// caches on their parents. Counters are adjusted in the transaction that
+// creates or deletes the children. Changing a child's foreign key isn't
+// tracked; run the reconcile-counter-caches task to correct drift.
+type CounterCacheModel interface {
+	CounterCaches() []CounterCache
+}
+
+type counterCaches struct {
+	byModel map[reflect.Type][]CounterCache
+}
+
+// registerCounterCaches installs the create and delete callbacks keeping
+// the counter caches of models up to date.
+func registerCounterCaches(db *gorm.DB, models ModelList) error {
+	cc := &counterCaches{byModel: make(map[reflect.Type][]CounterCache)}
+
+	for _, model := range models {
+		cached, ok := model.(CounterCacheModel)
+		if !ok {
+			continue
+		}
+
+		caches := cached.CounterCaches()
+		for _, cache := range caches {
+			if err := checkIdentifiers(cache.ForeignKey, cache.Table, cache.Column); err != nil {
+				return fmt.Errorf("invalid counter cache on %T: %w", model, err)
+			}
+		}
+
+		cc.byModel[reflect.Indirect(reflect.ValueOf(model)).Type()] = caches
+	}
+
+	if len(cc.byModel) == 0 {
+		return nil
+	}
+
+	err := db.Callback().Create().Before("gorm:after_create").Register("mochi:counter_cache_create", cc.afterCreate)
+	if err != nil {
+		return err
+	}
+
+	err = db.Callback().Delete().Before("gorm:delete").Register("mochi:counter_cache_count", cc.beforeDelete)
+	if err != nil {
+		return err
+	}
+
+	return db.Callback().Delete().Before("gorm:after_delete").Register("mochi:counter_cache_delete", cc.afterDelete)
+}
+
+func (cc *counterCaches) lookup(db *gorm.DB) []CounterCache {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil
+	}
+
+	return cc.byModel[db.Statement.Schema.ModelType]
+}
+
+// afterCreate increments the parents of the created rows.
+func (cc *counterCaches) afterCreate(db *gorm.DB) {
+	caches := cc.lookup(db)
+
+	for _, cache := range caches {
+		field := db.Statement.Schema.LookUpField(cache.ForeignKey)
+		if field == nil {
+			db.AddError(fmt.Errorf("counter cache foreign key %q not found on %s", cache.ForeignKey, db.Statement.Schema.Name))
+			return
+		}
+
+		deltas := map[interface{}]int64{}
+
+		eachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+			value, isZero := field.ValueOf(db.Statement.Context, row)
+			if isZero {
+				return
+			}
+
+			if parentID := reflect.Indirect(reflect.ValueOf(value)); parentID.IsValid() {
+				deltas[parentID.Interface()]++
+			}
+		})
+
+		cc.apply(db, cache, deltas, 1)
+	}
+}
+
+// beforeDelete counts the rows about to be deleted per parent, since the
+// delete statement itself only carries its conditions.
+func (cc *counterCaches) beforeDelete(db *gorm.DB) {
+	caches := cc.lookup(db)
+	if len(caches) == 0 {
+		return
+	}
+
+	stmt := db.Statement
+	conds := statementConds(stmt)
+
+	// gorm refuses deletes without conditions
+	if len(conds) == 0 {
+		return
+	}
+
+	deltas := make([]map[interface{}]int64, len(caches))
+
+	for i, cache := range caches {
+		var counts []struct {
+			ParentID interface{}
+			Count    int64
+		}
+
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Model(reflect.New(stmt.Schema.ModelType).Interface()).
+			Clauses(conds...).
+			Select(fmt.Sprintf("%s AS parent_id, COUNT(*) AS count", cache.ForeignKey)).
+			Where(fmt.Sprintf("%s IS NOT NULL", cache.ForeignKey)).
+			Group(cache.ForeignKey).
+			Scan(&counts).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to count %s for counter cache: %w", cache.ForeignKey, err))
+			return
+		}
+
+		deltas[i] = make(map[interface{}]int64, len(counts))
+		for _, count := range counts {
+			deltas[i][count.ParentID] = count.Count
+		}
+	}
+
+	db.InstanceSet(counterCacheDeltasKey, deltas)
+}
+
+// afterDelete decrements the parents counted by beforeDelete.
+func (cc *counterCaches) afterDelete(db *gorm.DB) {
+	caches := cc.lookup(db)
+
+	value, ok := db.InstanceGet(counterCacheDeltasKey)
+	if len(caches) == 0 || !ok {
+		return
+	}
+
+	deltas := value.([]map[interface{}]int64)
+
+	for i, cache := range caches {
+		cc.apply(db, cache, deltas[i], -1)
+	}
+}
+
+func (cc *counterCaches) apply(db *gorm.DB, cache CounterCache, deltas map[interface{}]int64, sign int64) {
+	for parentID, delta := range deltas {
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Table(cache.Table).
+			Where("id = ?", parentID).
+			UpdateColumn(cache.Column, gorm.Expr(fmt.Sprintf("%s + ?", cache.Column), sign*delta)).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to update counter cache %s.%s: %w", cache.Table, cache.Column, err))
+			return
+		}
+	}
+}
+
+func eachRow(value reflect.Value, fn func(row reflect.Value)) {
+	value = reflect.Indirect(value)
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fn(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+// ReconcileCounterCaches recounts every counter cache declared by models,
+// returning the number of parent rows corrected.
+func ReconcileCounterCaches(ctx context.Context, db DBService, models ModelList) (int64, error) {
+	var schemaCache sync.Map
+	var fixed int64
+
+	for _, model := range models {
+		cached, ok := model.(CounterCacheModel)
+		if !ok {
+			continue
+		}
+
+		modelSchema, err := schema.Parse(model, &schemaCache, schema.NamingStrategy{})
+		if err != nil {
+			return fixed, fmt.Errorf("failed to parse counter cache model %T: %w", model, err)
+		}
+
+		for _, cache := range cached.CounterCaches() {
+			if err := checkIdentifiers(cache.ForeignKey, cache.Table, cache.Column); err != nil {
+				return fixed, fmt.Errorf("invalid counter cache on %T: %w", model, err)
+			}
+
+			count := fmt.Sprintf(
+				"SELECT COUNT(*) FROM %[1]s WHERE %[1]s.%[2]s = %[3]s.id",
+				modelSchema.Table, cache.ForeignKey, cache.Table,
+			)
+
+			if field := modelSchema.LookUpField("deleted_at"); field != nil {
+				count += fmt.Sprintf(" AND %s.deleted_at IS NULL", modelSchema.Table)
+			}
+
+			rows, err := db.Exec(ctx, fmt.Sprintf(
+				"UPDATE %[1]s SET %[2]s = (%[3]s) WHERE %[2]s <> (%[3]s)",
+				cache.Table, cache.Column, count,
+			))
+			if err != nil {
+				return fixed, fmt.Errorf("failed to reconcile %s.%s: %w", cache.Table, cache.Column, err)
+			}
+
+			fixed += rows
+		}
+	}
+
+	return fixed, nil
+}
+
+type CounterCacheTaskParams struct {
+	fx.In
+
+	DB     DBService
+	Logger LoggerService
+	Models ModelList
+}
+
+// NewCounterCacheTask returns the reconcile-counter-caches task. Register
+// it with AsTask and run it on a schedule or after bulk changes.
+func NewCounterCacheTask(params CounterCacheTaskParams) Task {
+	return Task{
+		Name:        CounterCacheTaskName,
+		Description: "Recount counter caches that drifted from their child rows",
+		Run: func(ctx context.Context, args []string) error {
+			fixed, err := ReconcileCounterCaches(ctx, params.DB, params.Models)
+			if err != nil {
+				return err
+			}
+
+			params.Logger.Info("Reconciled counter caches", "fixed", fixed)
+
+			return nil
+		},
+	}
+}
@@ -5,19 +5,49 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/fx"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 type DBService interface {
 	CreateOne(ctx context.Context, record interface{}) error
-	UpdateOne(ctx context.Context, recordID uint, record interface{}) error
+	CreateOrUpdate(ctx context.Context, record interface{}, conflictColumns ...string) error
+	CreateMany(ctx context.Context, records interface{}, batchSize int) error
+	UpdateOne(ctx context.Context, recordID interface{}, record interface{}) error
+	UpdateFields(ctx context.Context, recordID interface{}, model interface{}, fields map[string]interface{}) error
 	DEPUpdateOne(ctx context.Context, record interface{}) error
-	DeleteOne(ctx context.Context, recordID uint, record interface{}) error
+	DeleteOne(ctx context.Context, recordID interface{}, record interface{}) error
+	DeleteCascade(ctx context.Context, recordID interface{}, record interface{}, deletes []string, nullifies []string) error
+	DeleteMany(ctx context.Context, record interface{}, query interface{}, args ...interface{}) error
+	SaveAggregate(ctx context.Context, record interface{}, associations ...string) error
+	Aggregate(
+		ctx context.Context,
+		model interface{},
+		dest interface{},
+		joins []string,
+		spec AggregateSpec,
+		query interface{},
+		args ...interface{},
+	) error
+	ModifyAssociation(ctx context.Context, record interface{}, name string, mode AssociationMode, values ...interface{}) error
+	FindAssociationTargets(ctx context.Context, model interface{}, name string, ids []interface{}) (interface{}, error)
+	TransferOwnership(
+		ctx context.Context,
+		model interface{},
+		change OwnershipChange,
+		query interface{},
+		args ...interface{},
+	) (int64, error)
 	FindOne(
 		ctx context.Context,
 		result interface{},
@@ -34,69 +64,343 @@ type DBService interface {
 		query interface{},
 		args ...interface{},
 	) error
+	Count(ctx context.Context, model interface{}, joins []string, query interface{}, args ...interface{}) (int64, error)
+	FindInBatches(
+		ctx context.Context,
+		batch interface{},
+		batchSize int,
+		joins []string,
+		preloads []string,
+		fn func() error,
+		query interface{},
+		args ...interface{},
+	) error
+
+	Exec(ctx context.Context, sql string, args ...interface{}) (int64, error)
+	Raw(ctx context.Context, dest interface{}, sql string, args ...interface{}) error
+	Query(ctx context.Context, dest interface{}, sql string, args ...interface{}) error
 
 	GetSession(ctx context.Context) (*gorm.DB, context.CancelFunc)
+	Dialect() Dialect
+	SetReadOnly(readOnly bool)
+	IsReadOnly() bool
+	Ready() bool
 	Migrate(ctx context.Context) error
-	DropAll(ctx context.Context) error
+	MigratePlan(ctx context.Context) ([]string, error)
+	VerifyIndexes(ctx context.Context) ([]string, error)
+}
+
+// DBDropper drops every registered table. It is kept off DBService so only
+// test and dev tooling that asks for it explicitly can reach it.
+type DBDropper interface {
+	DropAll(ctx context.Context, confirmation string) error
 }
 
+const (
+	DropAllConfirmation = "drop all tables"
+)
+
 type ModelList []interface{}
 
 const (
 	QueryTimeout = time.Second
+
+	MigrateDryRunEnvName = "DB_MIGRATE_DRY_RUN"
+	ReadOnlyEnvName      = "DB_READ_ONLY"
 )
 
 type DBServiceParams struct {
 	fx.In
 
-	Models ModelList
+	Env       AppEnv
+	Lifecycle fx.Lifecycle
+	Logger    LoggerService
+	Models    ModelList
+	Shutdown  *Shutdown `optional:"true"`
+
+	Migrations Migrations `optional:"true"`
+
+	// Dialector selects the database driver, such as mysql.Open(dsn) or
+	// sqlite.Open("dev.db"). It defaults to PostgreSQL at DATABASE_URL.
+	Dialector gorm.Dialector `optional:"true"`
+
+	// Pool defaults to LoadDBPoolConfigFromEnv.
+	Pool  *DBPoolConfig  `optional:"true"`
+	Retry *DBRetryConfig `optional:"true"`
+
+	// Log defaults to LoadDBLogConfigFromEnv.
+	Log     *DBLogConfig   `optional:"true"`
+	Metrics MetricsService `optional:"true"`
+
+	// Replicas default to the PostgreSQL DSNs in DATABASE_REPLICA_URLS.
+	Replicas DBReplicas `optional:"true"`
 }
 
 type DbServiceResult struct {
 	fx.Out
 
 	DBService DBService
+	DBDropper DBDropper
+	Migrator  Migrator
 }
 
 type dbService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	dialector gorm.Dialector
+	env       AppEnv
 
-	models []interface{}
+	replicaDialectors DBReplicas
+	replicas          []*gorm.DB
+	nextReplica       atomic.Uint64
+
+	pool       DBPoolConfig
+	retry      DBRetryConfig
+	logger     LoggerService
+	gormLogger *gormLogger
+	readOnly   atomic.Bool
+	ready      atomic.Bool
+
+	migrator *migrator
+	models   []interface{}
 }
 
 func NewDBService(params DBServiceParams) (DbServiceResult, error) {
+	var pool DBPoolConfig
+
+	if params.Pool != nil {
+		pool = *params.Pool
+	} else {
+		envPool, err := LoadDBPoolConfigFromEnv()
+		if err != nil {
+			return DbServiceResult{}, err
+		}
+
+		pool = envPool
+	}
+
+	var retry DBRetryConfig
+	if params.Retry != nil {
+		retry = *params.Retry
+	}
+
+	var logConfig DBLogConfig
+
+	if params.Log != nil {
+		logConfig = *params.Log
+	} else {
+		envLog, err := LoadDBLogConfigFromEnv(params.Env)
+		if err != nil {
+			return DbServiceResult{}, err
+		}
+
+		logConfig = envLog
+	}
+
+	metrics := params.Metrics
+	if metrics == nil {
+		metrics = NewNoopMetricsService()
+	}
+
 	srv := &dbService{
-		models: params.Models,
+		pool:      pool.withDefaults(),
+		retry:     retry.withDefaults(),
+		dialector: params.Dialector,
+		env:       params.Env,
+
+		replicaDialectors: params.Replicas,
+		logger:            params.Logger,
+		gormLogger:        newGormLogger(logConfig, params.Logger, metrics),
+		models:            params.Models,
 	}
 
-	srv.Init()
+	migrator, err := newMigrator(srv, params.Migrations)
+	if err != nil {
+		return DbServiceResult{}, err
+	}
 
-	return DbServiceResult{DBService: srv}, nil
-}
+	srv.migrator = migrator
 
-func (srv *dbService) Init() error {
-	dbUrl := os.Getenv("DATABASE_URL")
+	params.Lifecycle.Append(fx.Hook{OnStart: srv.start})
 
-	db, err := gorm.Open(postgres.New(postgres.Config{
-		DSN:                  dbUrl,
-		PreferSimpleProtocol: true,
-	}), &gorm.Config{})
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "database",
+		Stage: StageClose,
+		Stop:  srv.stop,
+	})
+
+	return DbServiceResult{DBService: srv, DBDropper: srv, Migrator: migrator}, nil
+}
 
+// start connects, retrying while the database comes up, and migrates.
+func (srv *dbService) start(ctx context.Context) error {
+	db, err := srv.connectWithRetry(ctx, srv.primaryDialector())
 	if err != nil {
 		return err
 	}
 
+	if err := registerCounterCaches(db, srv.models); err != nil {
+		return fmt.Errorf("failed to register counter caches: %w", err)
+	}
+
+	if err := registerDenormalizations(db, srv.models); err != nil {
+		return fmt.Errorf("failed to register denormalizations: %w", err)
+	}
+
+	if err := registerSlugs(db, srv.models); err != nil {
+		return fmt.Errorf("failed to register slugs: %w", err)
+	}
+
+	if err := registerPublicIDs(db, srv.models); err != nil {
+		return fmt.Errorf("failed to register public IDs: %w", err)
+	}
+
 	srv.db = db
+	srv.connectReplicas(ctx)
+	srv.SetReadOnly(os.Getenv(ReadOnlyEnvName) == "true")
+
+	if err := srv.migrateOnStart(ctx); err != nil {
+		return err
+	}
+
+	srv.ready.Store(true)
+	srv.logger.Info("Database ready", "dialect", srv.Dialect())
 
-	err = srv.Migrate(context.Background())
+	return nil
+}
+
+func (srv *dbService) stop(ctx context.Context) error {
+	srv.ready.Store(false)
+
+	if srv.db == nil {
+		return nil
+	}
+
+	for _, replica := range srv.replicas {
+		if sqlDB, err := replica.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+
+	sqlDB, err := srv.db.DB()
 	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+func (srv *dbService) migrateOnStart(ctx context.Context) error {
+	if srv.IsReadOnly() {
+		srv.logger.Warn("Database is read-only, skipping migrations")
+		return nil
+	}
+
+	if os.Getenv(MigrateDryRunEnvName) == "true" {
+		return srv.logMigratePlan(ctx)
+	}
+
+	if len(srv.migrator.migrations) > 0 {
+		if err := srv.migrator.Up(ctx); err != nil {
+			return err
+		}
+	}
+
+	if !srv.autoMigrate() {
+		return nil
+	}
+
+	if err := srv.Migrate(ctx); err != nil {
 		return fmt.Errorf("migrate failed: %w", err)
 	}
 
 	return nil
 }
 
+// autoMigrate reports whether AutoMigrate runs on start. DB_AUTO_MIGRATE
+// decides when set; otherwise it runs in development, and elsewhere only
+// for apps that have no versioned migrations yet.
+func (srv *dbService) autoMigrate() bool {
+	if value := os.Getenv(AutoMigrateEnvName); value != "" {
+		return value == "true"
+	}
+
+	if srv.env.IsDevelopment() {
+		return true
+	}
+
+	if len(srv.migrator.migrations) > 0 {
+		return false
+	}
+
+	srv.logger.Warn("Running AutoMigrate outside development, register versioned Migrations instead", "env", srv.env)
+
+	return true
+}
+
+// primaryDialector returns the configured dialector, defaulting to
+// PostgreSQL at DATABASE_URL.
+func (srv *dbService) primaryDialector() gorm.Dialector {
+	if srv.dialector != nil {
+		return srv.dialector
+	}
+
+	return newPostgresDialector(os.Getenv("DATABASE_URL"))
+}
+
+func newPostgresDialector(dsn string) gorm.Dialector {
+	return postgres.New(postgres.Config{
+		DSN:                  dsn,
+		PreferSimpleProtocol: true,
+	})
+}
+
+// open connects to the database and configures the pool.
+func (srv *dbService) open(dialector gorm.Dialector) (*gorm.DB, error) {
+	gormConfig := &gorm.Config{Logger: srv.gormLogger}
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		// a failed ping still leaves a pool open
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
+
+		return nil, err
+	}
+
+	if err := srv.pool.apply(db); err != nil {
+		return nil, err
+	}
+
+	if err := registerTracing(db); err != nil {
+		return nil, fmt.Errorf("failed to register tracing: %w", err)
+	}
+
+	return db, nil
+}
+
+func (srv *dbService) logMigratePlan(ctx context.Context) error {
+	plan, err := srv.MigratePlan(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate plan failed: %w", err)
+	}
+
+	srv.logger.Warn("Migration dry run, schema changes were not applied", "statements", len(plan))
+
+	for _, statement := range plan {
+		srv.logger.Info("Planned migration statement", "sql", statement)
+	}
+
+	return nil
+}
+
 func (srv *dbService) CreateOne(ctx context.Context, record interface{}) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
 	sesh, cancel := srv.GetSession(ctx)
 	defer cancel()
 
@@ -108,7 +412,121 @@ func (srv *dbService) CreateOne(ctx context.Context, record interface{}) error {
 	return nil
 }
 
-func (srv *dbService) UpdateOne(ctx context.Context, recordID uint, record interface{}) error {
+// CreateMany inserts records, a slice of models, batchSize rows per INSERT
+// within one transaction.
+func (srv *dbService) CreateMany(ctx context.Context, records interface{}, batchSize int) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	if batchSize <= 0 {
+		return fmt.Errorf("create many requires a positive batch size")
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	createResult := sesh.CreateInBatches(records, batchSize)
+	if createResult.Error != nil {
+		return fmt.Errorf("create many failed: %w", createResult.Error)
+	}
+
+	return nil
+}
+
+// CreateOrUpdate inserts record, a model or a slice of models, updating
+// the existing row instead when one conflicts on conflictColumns, which
+// default to the primary key. The primary key and the user_id and
+// tenant_id columns are never overwritten, and rows of tenant scoped models
+// are only updated within their own tenant. MySQL can't restrict the update
+// to a tenant, so tenant scoped upserts fail there.
+func (srv *dbService) CreateOrUpdate(ctx context.Context, record interface{}, conflictColumns ...string) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	stmt := &gorm.Statement{DB: sesh}
+	if err := stmt.Parse(record); err != nil {
+		return fmt.Errorf("failed to parse upserted model: %w", err)
+	}
+
+	_, tenantScoped := reflect.New(stmt.Schema.ModelType).Interface().(TenantModel)
+	if tenantScoped && srv.Dialect() == DialectMySQL {
+		return fmt.Errorf("tenant scoped upserts are not supported on %s", DialectMySQL)
+	}
+
+	if len(conflictColumns) == 0 {
+		for _, field := range stmt.Schema.PrimaryFields {
+			conflictColumns = append(conflictColumns, field.DBName)
+		}
+	}
+
+	onConflict := clause.OnConflict{DoUpdates: clause.AssignmentColumns(upsertColumns(stmt.Schema, conflictColumns))}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+
+	if len(onConflict.DoUpdates) == 0 {
+		onConflict.DoNothing = true
+	}
+
+	if tenantScoped {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{clause.Expr{
+			SQL:  "?.tenant_id = excluded.tenant_id",
+			Vars: []interface{}{clause.Table{Name: clause.CurrentTable}},
+		}}}
+	}
+
+	upsertResult := sesh.Clauses(onConflict).Create(record)
+	if upsertResult.Error != nil {
+		return fmt.Errorf("create or update failed: %w", upsertResult.Error)
+	}
+
+	if tenantScoped {
+		var rows int64
+		eachRow(reflect.ValueOf(record), func(reflect.Value) { rows++ })
+
+		if upsertResult.RowsAffected < rows {
+			return fmt.Errorf("%w: conflicting row belongs to another tenant", ErrRecordNotFound)
+		}
+	}
+
+	return nil
+}
+
+// upsertColumns lists the columns CreateOrUpdate overwrites on conflict,
+// leaving out keys, ownership, and creation columns like gorm's UpdateAll
+// leaves out keys.
+func upsertColumns(modelSchema *schema.Schema, conflictColumns []string) []string {
+	columns := []string{}
+
+	for _, field := range modelSchema.Fields {
+		switch {
+		case field.DBName == "" || !field.Creatable || field.PrimaryKey || field.AutoCreateTime > 0:
+			continue
+		case field.DBName == "user_id" || field.DBName == "tenant_id" || slices.Contains(conflictColumns, field.DBName):
+			continue
+		case field.HasDefaultValue && field.DefaultValueInterface == nil && !strings.EqualFold(field.DefaultValue, "NULL"):
+			// left out of the insert while zero, so excluded has no value
+			continue
+		}
+
+		columns = append(columns, field.DBName)
+	}
+
+	return columns
+}
+
+// UpdateOne updates the non-zero fields of record and refreshes record with
+// the stored row.
+func (srv *dbService) UpdateOne(ctx context.Context, recordID interface{}, record interface{}) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
 	sesh, cancel := srv.GetSession(ctx)
 	defer cancel()
 
@@ -122,10 +540,49 @@ func (srv *dbService) UpdateOne(ctx context.Context, recordID uint, record inter
 		return fmt.Errorf("update one failed: %w", updateResult.Error)
 	}
 
+	// without RETURNING the row is read back so record reflects defaults and
+	// triggers
+	if !srv.Dialect().supportsReturning() {
+		if err := sesh.Where("id = ?", recordID).First(record).Error; err != nil {
+			return fmt.Errorf("failed to reload updated record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateFields sets the given columns of model's row, zero values included,
+// which struct based updates skip.
+func (srv *dbService) UpdateFields(
+	ctx context.Context,
+	recordID interface{},
+	model interface{},
+	fields map[string]interface{},
+) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	if len(fields) == 0 {
+		return fmt.Errorf("update fields requires at least one field")
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	updateResult := sesh.Model(model).Where("id = ?", recordID).Updates(fields)
+	if updateResult.Error != nil {
+		return fmt.Errorf("update fields failed: %w", updateResult.Error)
+	}
+
 	return nil
 }
 
 func (srv *dbService) DEPUpdateOne(ctx context.Context, record interface{}) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
 	sesh, cancel := srv.GetSession(ctx)
 	defer cancel()
 
@@ -137,11 +594,16 @@ func (srv *dbService) DEPUpdateOne(ctx context.Context, record interface{}) erro
 	return nil
 }
 
-func (srv *dbService) DeleteOne(ctx context.Context, recordID uint, record interface{}) error {
+func (srv *dbService) DeleteOne(ctx context.Context, recordID interface{}, record interface{}) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
 	sesh, cancel := srv.GetSession(ctx)
 	defer cancel()
 
-	deleteResult := sesh.Delete(record, recordID)
+	// an explicit condition keeps string keys from being treated as raw SQL
+	deleteResult := sesh.Where("id = ?", recordID).Delete(record)
 	if deleteResult.Error != nil {
 		return fmt.Errorf("delete one failed: %w", deleteResult.Error)
 	}
@@ -149,6 +611,28 @@ func (srv *dbService) DeleteOne(ctx context.Context, recordID uint, record inter
 	return nil
 }
 
+// DeleteMany deletes the rows of record's table matching query. An empty
+// query is rejected rather than deleting the whole table.
+func (srv *dbService) DeleteMany(ctx context.Context, record interface{}, query interface{}, args ...interface{}) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	if query == nil || query == "" {
+		return fmt.Errorf("delete many requires a query")
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	deleteResult := sesh.Where(query, args...).Delete(record)
+	if deleteResult.Error != nil {
+		return fmt.Errorf("delete many failed: %w", deleteResult.Error)
+	}
+
+	return nil
+}
+
 func (srv *dbService) FindOne(
 	ctx context.Context,
 	result interface{},
@@ -157,7 +641,7 @@ func (srv *dbService) FindOne(
 	query interface{},
 	args ...interface{},
 ) error {
-	sesh, cancel := srv.GetSession(ctx)
+	sesh, cancel := srv.getReadSession(ctx)
 	defer cancel()
 
 	for _, join := range joins {
@@ -192,7 +676,7 @@ func (srv *dbService) FindMany(
 	query interface{},
 	args ...interface{},
 ) error {
-	sesh, cancel := srv.GetSession(ctx)
+	sesh, cancel := srv.getReadSession(ctx)
 	defer cancel()
 
 	for _, join := range joins {
@@ -207,6 +691,14 @@ func (srv *dbService) FindMany(
 		sesh = sesh.Where(query, args...)
 	}
 
+	if order := orderClauseFromCtx(ctx); order != "" {
+		sesh = sesh.Order(order)
+	}
+
+	if limit := resultLimitFromCtx(ctx); limit > 0 {
+		sesh = sesh.Limit(limit)
+	}
+
 	queryResult := sesh.Find(result)
 	if queryResult.Error != nil {
 		return fmt.Errorf("find many failed: %w", queryResult.Error)
@@ -215,21 +707,279 @@ func (srv *dbService) FindMany(
 	return nil
 }
 
+// Exec runs a raw statement, such as a data fix, and returns the number of
+// rows it affected.
+func (srv *dbService) Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	if err := srv.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	execResult := sesh.Exec(sql, args...)
+	if execResult.Error != nil {
+		return 0, fmt.Errorf("exec failed: %w", execResult.Error)
+	}
+
+	return execResult.RowsAffected, nil
+}
+
+// Raw scans the rows of a raw statement into dest. It runs on the primary
+// since the statement may write, for example with RETURNING, so it fails in
+// read-only mode. Use Query for reads.
+func (srv *dbService) Raw(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	rawResult := sesh.Raw(sql, args...).Scan(dest)
+	if rawResult.Error != nil {
+		return fmt.Errorf("raw query failed: %w", rawResult.Error)
+	}
+
+	return nil
+}
+
+// Query scans the rows of a raw read-only query, such as a report, into
+// dest. It runs on a replica when one is configured.
+func (srv *dbService) Query(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	sesh, cancel := srv.getReadSession(ctx)
+	defer cancel()
+
+	queryResult := sesh.Raw(sql, args...).Scan(dest)
+	if queryResult.Error != nil {
+		return fmt.Errorf("query failed: %w", queryResult.Error)
+	}
+
+	return nil
+}
+
+// Count counts model rows matching query without loading them.
+func (srv *dbService) Count(ctx context.Context, model interface{}, joins []string, query interface{}, args ...interface{}) (int64, error) {
+	sesh, cancel := srv.getReadSession(ctx)
+	defer cancel()
+
+	sesh = sesh.Model(model)
+
+	for _, join := range joins {
+		sesh = sesh.Joins(join)
+	}
+
+	if query != nil {
+		sesh = sesh.Where(query, args...)
+	}
+
+	var count int64
+
+	countResult := sesh.Count(&count)
+	if countResult.Error != nil {
+		return 0, fmt.Errorf("count failed: %w", countResult.Error)
+	}
+
+	return count, nil
+}
+
+// FindInBatches loads matching rows into batch, a pointer to a slice, one
+// batch at a time and calls fn after each. Streams run until ctx is done
+// rather than being bound by the default query timeout.
+func (srv *dbService) FindInBatches(
+	ctx context.Context,
+	batch interface{},
+	batchSize int,
+	joins []string,
+	preloads []string,
+	fn func() error,
+	query interface{},
+	args ...interface{},
+) error {
+	sesh := srv.readDB(ctx).WithContext(ctx)
+
+	for _, join := range joins {
+		sesh = sesh.Joins(join)
+	}
+
+	for _, preload := range preloads {
+		sesh = sesh.Preload(preload)
+	}
+
+	if query != nil {
+		sesh = sesh.Where(query, args...)
+	}
+
+	result := sesh.FindInBatches(batch, batchSize, func(tx *gorm.DB, batchNum int) error {
+		return fn()
+	})
+
+	if result.Error != nil {
+		return fmt.Errorf("find in batches failed: %w", result.Error)
+	}
+
+	return nil
+}
+
 func (srv *dbService) Migrate(ctx context.Context) error {
-	for _, model := range srv.models {
-		if err := srv.db.AutoMigrate(model); err != nil {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	tables, views := splitViewModels(srv.models)
+
+	for _, model := range tables {
+		if err := srv.db.WithContext(ctx).AutoMigrate(model); err != nil {
 			return fmt.Errorf("migrate failed for model %v: %w", model, err)
 		}
+
+		if err := srv.migrateIndexes(ctx, model); err != nil {
+			return fmt.Errorf("migrate indexes failed for model %v: %w", model, err)
+		}
+
+		if err := srv.migrateEnumChecks(ctx, model); err != nil {
+			return fmt.Errorf("migrate enum checks failed for model %v: %w", model, err)
+		}
+	}
+
+	if err := srv.migrateViews(ctx, views); err != nil {
+		return fmt.Errorf("migrate views failed: %w", err)
+	}
+
+	missing, err := srv.VerifyIndexes(ctx)
+	if err != nil {
+		return fmt.Errorf("verify indexes failed: %w", err)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("indexes missing after migrate: %s", strings.Join(missing, ", "))
 	}
 
 	return nil
 }
 
-func (srv *dbService) DropAll(ctx context.Context) error {
+// MigratePlan returns the statements starting the service would execute,
+// without applying them: pending versioned migrations followed by the
+// AutoMigrate DDL when AutoMigrate is enabled. The current schema is still
+// read from the database.
+func (srv *dbService) MigratePlan(ctx context.Context) ([]string, error) {
+	plan, err := srv.migrator.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !srv.autoMigrate() {
+		return plan, nil
+	}
+
+	autoPlan, err := srv.autoMigratePlan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(plan, autoPlan...), nil
+}
+
+// dryRunSession returns a session that records statements instead of
+// executing them.
+func (srv *dbService) dryRunSession(ctx context.Context) (*gorm.DB, *ddlRecorder) {
+	recorder := &ddlRecorder{Interface: logger.Discard}
+
+	return srv.db.Session(&gorm.Session{
+		Context: ctx,
+		DryRun:  true,
+		Logger:  recorder,
+	}), recorder
+}
+
+// autoMigratePlan returns the DDL statements Migrate would execute.
+func (srv *dbService) autoMigratePlan(ctx context.Context) ([]string, error) {
+	sesh, recorder := srv.dryRunSession(ctx)
+
+	tables, views := splitViewModels(srv.models)
+
+	for _, model := range tables {
+		if err := sesh.AutoMigrate(model); err != nil {
+			return nil, fmt.Errorf("migrate plan failed for model %v: %w", model, err)
+		}
+
+		indexStatements, err := missingIndexStatements(srv.db.WithContext(ctx), model)
+		if err != nil {
+			return nil, fmt.Errorf("migrate plan failed for model %v: %w", model, err)
+		}
+
+		recorder.statements = append(recorder.statements, indexStatements...)
+
+		enumStatements, err := missingEnumCheckStatements(srv.db.WithContext(ctx), model)
+		if err != nil {
+			return nil, fmt.Errorf("migrate plan failed for model %v: %w", model, err)
+		}
+
+		recorder.statements = append(recorder.statements, enumStatements...)
+	}
+
+	for _, view := range views {
+		statements, err := viewStatements(srv.db, view)
+		if err != nil {
+			return nil, fmt.Errorf("migrate plan failed for view %v: %w", view, err)
+		}
+
+		recorder.statements = append(recorder.statements, statements...)
+
+		if isMaterializedView(srv.db, view) {
+			indexStatements, err := missingIndexStatements(srv.db.WithContext(ctx), view)
+			if err != nil {
+				return nil, fmt.Errorf("migrate plan failed for view %v: %w", view, err)
+			}
+
+			recorder.statements = append(recorder.statements, indexStatements...)
+		}
+	}
+
+	return recorder.statements, nil
+}
+
+func (srv *dbService) DropAll(ctx context.Context, confirmation string) error {
+	if err := srv.checkWritable(); err != nil {
+		return err
+	}
+
+	if srv.env.IsProduction() {
+		srv.logger.Error("Refusing to drop all tables", "env", srv.env)
+		return fmt.Errorf("drop all is disabled in %s", srv.env)
+	}
+
+	if confirmation != DropAllConfirmation {
+		return fmt.Errorf("drop all requires the confirmation %q", DropAllConfirmation)
+	}
+
+	srv.logger.Error("DROPPING ALL TABLES", "env", srv.env, "models", len(srv.models))
+
 	sesh, cancel := srv.GetSession(ctx)
 	defer cancel()
 
-	for _, model := range srv.models {
+	tables, views := splitViewModels(srv.models)
+
+	for _, view := range views {
+		stmt := &gorm.Statement{DB: sesh}
+		if err := stmt.Parse(view); err != nil {
+			return fmt.Errorf("drop all failed: %w", err)
+		}
+
+		if _, ok := view.(MaterializedViewModel); ok && srv.Dialect().SupportsMaterializedViews() {
+			if err := sesh.Exec(fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s", stmt.Schema.Table)).Error; err != nil {
+				return fmt.Errorf("drop all failed: %w", err)
+			}
+
+			continue
+		}
+
+		if err := sesh.Migrator().DropView(stmt.Schema.Table); err != nil {
+			return fmt.Errorf("drop all failed: %w", err)
+		}
+	}
+
+	for _, model := range tables {
 		err := sesh.Migrator().DropTable(model)
 		if err != nil {
 			return fmt.Errorf("drop all failed: %w", err)
@@ -239,10 +989,95 @@ func (srv *dbService) DropAll(ctx context.Context) error {
 	return nil
 }
 
+// SetReadOnly toggles rejecting writes with ErrReadOnly, for failovers and
+// maintenance windows where the app points at a replica.
+func (srv *dbService) SetReadOnly(readOnly bool) {
+	if srv.readOnly.Swap(readOnly) != readOnly {
+		srv.logger.Warn("Database read-only mode changed", "read_only", readOnly)
+	}
+}
+
+func (srv *dbService) IsReadOnly() bool {
+	return srv.readOnly.Load()
+}
+
+func (srv *dbService) checkWritable() error {
+	if srv.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	return nil
+}
+
+// Dialect reports which database the service is connected to.
+func (srv *dbService) Dialect() Dialect {
+	if srv.db == nil {
+		if srv.dialector != nil {
+			return Dialect(srv.dialector.Name())
+		}
+
+		return DialectPostgres
+	}
+
+	return dialectOf(srv.db)
+}
+
+// GetSession returns a session bound to ctx. A deadline already set on ctx
+// is respected as is; otherwise the query timeout from WithQueryTimeout, or
+// QueryTimeout, is applied. Canceling ctx, for example when the client
+// disconnects, cancels the running query.
 func (srv *dbService) GetSession(ctx context.Context) (*gorm.DB, context.CancelFunc) {
-	timeoutCtx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	sessionCtx, cancel := queryContext(ctx)
 
 	return srv.db.Session(&gorm.Session{
-		Context: timeoutCtx,
+		Context: sessionCtx,
 	}), cancel
 }
+
+type queryTimeoutContextKey int
+
+const (
+	queryTimeoutKey queryTimeoutContextKey = iota
+)
+
+// WithQueryTimeout overrides the default query timeout for sessions created
+// from the returned context.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey, timeout)
+}
+
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+
+	timeout, ok := ctx.Value(queryTimeoutKey).(time.Duration)
+	if !ok || timeout <= 0 {
+		timeout = QueryTimeout
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ddlRecorder collects the statements gorm traces during a dry-run
+// migration, skipping the queries it uses to inspect the current schema.
+type ddlRecorder struct {
+	logger.Interface
+
+	statements []string
+}
+
+func (r *ddlRecorder) LogMode(level logger.LogLevel) logger.Interface {
+	return r
+}
+
+func (r *ddlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	statement, _ := fc()
+	statement = strings.TrimSpace(statement)
+
+	if statement == "" || strings.HasPrefix(strings.ToUpper(statement), "SELECT") {
+		return
+	}
+
+	r.statements = append(r.statements, statement)
+}
@@ -0,0 +1,154 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	DBSlowQueryThresholdEnvName = "DB_SLOW_QUERY_THRESHOLD"
+	DBLogQueriesEnvName         = "DB_LOG_QUERIES"
+
+	DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+	QueryDurationMetric = "mochi_db_query_duration_seconds"
+	SlowQueriesMetric   = "mochi_db_slow_queries_total"
+)
+
+// DBLogConfig controls how queries are logged. Queries slower than
+// SlowThreshold are logged at WARN; a negative threshold disables this.
+// LogQueries logs every query at DEBUG, and LogParams includes the bound
+// values rather than placeholders. Both default to on in development only,
+// since values may be personal data.
+type DBLogConfig struct {
+	SlowThreshold time.Duration
+	LogQueries    bool
+	LogParams     bool
+}
+
+// LoadDBLogConfigFromEnv reads DB_SLOW_QUERY_THRESHOLD, in Go duration
+// syntax, and DB_LOG_QUERIES.
+func LoadDBLogConfigFromEnv(env AppEnv) (DBLogConfig, error) {
+	config := DBLogConfig{
+		LogQueries: env.IsDevelopment(),
+		LogParams:  env.IsDevelopment(),
+	}
+
+	threshold, err := durationFromEnv(DBSlowQueryThresholdEnvName)
+	if err != nil {
+		return config, err
+	}
+
+	config.SlowThreshold = threshold
+
+	if value := os.Getenv(DBLogQueriesEnvName); value != "" {
+		config.LogQueries = value == "true"
+	}
+
+	return config, nil
+}
+
+func (c DBLogConfig) withDefaults() DBLogConfig {
+	if c.SlowThreshold == 0 {
+		c.SlowThreshold = DefaultSlowQueryThreshold
+	}
+
+	return c
+}
+
+// gormLogger sends gorm's logs to the LoggerService and records query
+// durations.
+type gormLogger struct {
+	config  DBLogConfig
+	level   logger.LogLevel
+	logger  LoggerService
+	metrics MetricsService
+}
+
+func newGormLogger(config DBLogConfig, log LoggerService, metrics MetricsService) *gormLogger {
+	return &gormLogger{
+		config:  config.withDefaults(),
+		level:   logger.Info,
+		logger:  log,
+		metrics: metrics,
+	}
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.level = level
+
+	return &copied
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.logger.Info(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.logger.Warn(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.logger.Error(fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace logs failed queries at ERROR, slow ones at WARN, and the rest at
+// DEBUG when LogQueries is set. Missing records aren't failures.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	sql, rows := fc()
+	operation := queryOperation(sql)
+
+	l.metrics.ObserveHistogram(QueryDurationMetric, elapsed.Seconds(), MetricLabels{"operation": operation})
+
+	slow := l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold
+	if slow {
+		l.metrics.IncCounter(SlowQueriesMetric, 1, MetricLabels{"operation": operation})
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		l.logger.WithContext(ctx).Error("Query failed", "sql", sql, "duration", elapsed, "rows", rows, "error", err)
+	case slow && l.level >= logger.Warn:
+		l.logger.WithContext(ctx).Warn("Slow query", "sql", sql, "duration", elapsed, "rows", rows, "threshold", l.config.SlowThreshold)
+	case l.config.LogQueries && l.level >= logger.Info:
+		l.logger.WithContext(ctx).Debug("Query", "sql", sql, "duration", elapsed, "rows", rows)
+	}
+}
+
+// ParamsFilter drops bound values from logged SQL unless LogParams is set.
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.LogParams {
+		return sql, params
+	}
+
+	return sql, nil
+}
+
+// queryOperation returns the statement's leading keyword, such as SELECT.
+func queryOperation(sql string) string {
+	keyword, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	if keyword == "" {
+		return "UNKNOWN"
+	}
+
+	return strings.ToUpper(keyword)
+}
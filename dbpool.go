@@ -0,0 +1,128 @@
+package mochi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DBMaxOpenConnsEnvName    = "DB_MAX_OPEN_CONNS"
+	DBMaxIdleConnsEnvName    = "DB_MAX_IDLE_CONNS"
+	DBConnMaxLifetimeEnvName = "DB_CONN_MAX_LIFETIME"
+	DBConnMaxIdleTimeEnvName = "DB_CONN_MAX_IDLE_TIME"
+
+	DefaultDBMaxOpenConns    = 25
+	DefaultDBMaxIdleConns    = 25
+	DefaultDBConnMaxLifetime = 30 * time.Minute
+	DefaultDBConnMaxIdleTime = 5 * time.Minute
+)
+
+// DBPoolConfig tunes the database connection pool. MaxOpenConns should stay
+// below the server's connection limit divided by the number of app
+// instances. Zero values fall back to the defaults. A negative
+// MaxOpenConns or duration removes the limit, and a negative MaxIdleConns
+// keeps no idle connections.
+type DBPoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// LoadDBPoolConfigFromEnv reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS,
+// DB_CONN_MAX_LIFETIME, and DB_CONN_MAX_IDLE_TIME. Durations use Go syntax
+// such as 30m.
+func LoadDBPoolConfigFromEnv() (DBPoolConfig, error) {
+	var config DBPoolConfig
+	var err error
+
+	if config.MaxOpenConns, err = intFromEnv(DBMaxOpenConnsEnvName); err != nil {
+		return config, err
+	}
+
+	if config.MaxIdleConns, err = intFromEnv(DBMaxIdleConnsEnvName); err != nil {
+		return config, err
+	}
+
+	if config.ConnMaxLifetime, err = durationFromEnv(DBConnMaxLifetimeEnvName); err != nil {
+		return config, err
+	}
+
+	if config.ConnMaxIdleTime, err = durationFromEnv(DBConnMaxIdleTimeEnvName); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+func (c DBPoolConfig) withDefaults() DBPoolConfig {
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = DefaultDBMaxOpenConns
+	}
+
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = DefaultDBMaxIdleConns
+	}
+
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		c.MaxIdleConns = c.MaxOpenConns
+	}
+
+	if c.ConnMaxLifetime == 0 {
+		c.ConnMaxLifetime = DefaultDBConnMaxLifetime
+	}
+
+	if c.ConnMaxIdleTime == 0 {
+		c.ConnMaxIdleTime = DefaultDBConnMaxIdleTime
+	}
+
+	return c
+}
+
+// apply configures the pool behind db. database/sql treats zero and
+// negative values as no limit.
+func (c DBPoolConfig) apply(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get connection pool: %w", err)
+	}
+
+	sqlDB.SetMaxOpenConns(max(c.MaxOpenConns, 0))
+	sqlDB.SetMaxIdleConns(c.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(max(c.ConnMaxLifetime, 0))
+	sqlDB.SetConnMaxIdleTime(max(c.ConnMaxIdleTime, 0))
+
+	return nil
+}
+
+func intFromEnv(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return n, nil
+}
+
+func durationFromEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return d, nil
+}
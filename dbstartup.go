@@ -0,0 +1,90 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReadinessPath = "/readyz"
+
+	DefaultDBConnectAttempts = 10
+	DefaultDBInitialBackoff  = 500 * time.Millisecond
+	DefaultDBMaxBackoff      = 10 * time.Second
+)
+
+// DBRetryConfig controls how DBService retries connecting at startup. The
+// retries run in an fx OnStart hook, so the total wait must fit within the
+// app's fx.StartTimeout.
+type DBRetryConfig struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (c DBRetryConfig) withDefaults() DBRetryConfig {
+	if c.Attempts <= 0 {
+		c.Attempts = DefaultDBConnectAttempts
+	}
+
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultDBInitialBackoff
+	}
+
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultDBMaxBackoff
+	}
+
+	return c
+}
+
+// connectWithRetry opens the database, backing off exponentially between
+// failed attempts until the attempts run out or ctx is done.
+func (srv *dbService) connectWithRetry(ctx context.Context, dialector gorm.Dialector) (*gorm.DB, error) {
+	backoff := srv.retry.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		db, err := srv.open(dialector)
+		if err == nil {
+			return db, nil
+		}
+
+		if attempt >= srv.retry.Attempts {
+			return nil, fmt.Errorf("failed to connect after %d attempts: %w", attempt, err)
+		}
+
+		srv.logger.Warn("Database not available, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up connecting to database: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, srv.retry.MaxBackoff)
+	}
+}
+
+// Ready reports whether the database is connected and migrated.
+func (srv *dbService) Ready() bool {
+	return srv.ready.Load()
+}
+
+// ReadinessHandler responds 503 until the database is ready, for load
+// balancer and orchestrator readiness probes.
+func ReadinessHandler(db DBService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !db.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("database not ready"))
+
+			return
+		}
+
+		w.Write([]byte("ready"))
+	}
+}
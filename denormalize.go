@@ -0,0 +1,373 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DenormalizeTaskName    = "rebuild-denormalizations"
+	denormalizeParentIDKey = "mochi:denormalize_parent_ids"
+)
+
+// Denormalization copies Source of the parent Table row that ForeignKey
+// points at into Column, such as a project's name onto its tasks as
+// project_name so tasks can be sorted by it.
+type Denormalization struct {
+	Column     string
+	ForeignKey string
+	Table      string
+	Source     string
+}
+
+// DenormalizedModel is implemented by models with denormalized columns.
+// Columns are filled when rows are created or their foreign key changes,
+// and refreshed when the parent's source column is updated through gorm.
+// Writes that bypass gorm, such as raw SQL, aren't tracked; run the
+// rebuild-denormalizations task to repair them.
+type DenormalizedModel interface {
+	Denormalizations() []Denormalization
+}
+
+type denormalizedChild struct {
+	table           string
+	denormalization Denormalization
+}
+
+type denormalizations struct {
+	byModel  map[reflect.Type][]Denormalization
+	byParent map[string][]denormalizedChild
+}
+
+// registerDenormalizations installs the callbacks keeping the denormalized
+// columns of models in sync.
+func registerDenormalizations(db *gorm.DB, models ModelList) error {
+	dn := &denormalizations{
+		byModel:  make(map[reflect.Type][]Denormalization),
+		byParent: make(map[string][]denormalizedChild),
+	}
+
+	for _, model := range models {
+		denormalized, ok := model.(DenormalizedModel)
+		if !ok {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse denormalized model %T: %w", model, err)
+		}
+
+		for _, d := range denormalized.Denormalizations() {
+			if err := checkIdentifiers(d.Column, d.ForeignKey, d.Table, d.Source); err != nil {
+				return fmt.Errorf("invalid denormalization on %T: %w", model, err)
+			}
+
+			if stmt.Schema.LookUpField(d.Column) == nil || stmt.Schema.LookUpField(d.ForeignKey) == nil {
+				return fmt.Errorf("denormalization columns %s and %s must be fields of %T", d.Column, d.ForeignKey, model)
+			}
+
+			dn.byModel[stmt.Schema.ModelType] = append(dn.byModel[stmt.Schema.ModelType], d)
+			dn.byParent[d.Table] = append(dn.byParent[d.Table], denormalizedChild{table: stmt.Schema.Table, denormalization: d})
+		}
+	}
+
+	if len(dn.byModel) == 0 {
+		return nil
+	}
+
+	err := db.Callback().Create().Before("gorm:create").Register("mochi:denormalize_create", dn.beforeCreate)
+	if err != nil {
+		return err
+	}
+
+	err = db.Callback().Update().Before("gorm:update").Register("mochi:denormalize_parent_ids", dn.beforeUpdate)
+	if err != nil {
+		return err
+	}
+
+	return db.Callback().Update().Before("gorm:after_update").Register("mochi:denormalize_update", dn.afterUpdate)
+}
+
+// beforeCreate fills the denormalized columns of new rows from their
+// parents.
+func (dn *denormalizations) beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+
+	for _, d := range dn.byModel[stmt.Schema.ModelType] {
+		column := stmt.Schema.LookUpField(d.Column)
+		foreignKey := stmt.Schema.LookUpField(d.ForeignKey)
+		parentIDs := []interface{}{}
+
+		eachRow(stmt.ReflectValue, func(row reflect.Value) {
+			if value, isZero := foreignKey.ValueOf(stmt.Context, row); !isZero {
+				parentIDs = append(parentIDs, value)
+			}
+		})
+
+		if len(parentIDs) == 0 {
+			continue
+		}
+
+		var parents []map[string]interface{}
+
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Table(d.Table).
+			Select(fmt.Sprintf("id, %s AS source", d.Source)).
+			Where("id IN ?", parentIDs).
+			Scan(&parents).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to load %s.%s for denormalization: %w", d.Table, d.Source, err))
+			return
+		}
+
+		sources := make(map[string]interface{}, len(parents))
+		for _, parent := range parents {
+			sources[fmt.Sprint(parent["id"])] = parent["source"]
+		}
+
+		eachRow(stmt.ReflectValue, func(row reflect.Value) {
+			value, isZero := foreignKey.ValueOf(stmt.Context, row)
+			if isZero {
+				return
+			}
+
+			source, ok := sources[fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)).Interface())]
+			if !ok || source == nil {
+				return
+			}
+
+			if err := column.Set(stmt.Context, row, source); err != nil {
+				db.AddError(fmt.Errorf("failed to set denormalized %s: %w", d.Column, err))
+			}
+		})
+	}
+}
+
+// beforeUpdate records which parent rows an update of a source column
+// targets, since its conditions may no longer match them afterwards.
+func (dn *denormalizations) beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+	children := dn.byParent[stmt.Schema.Table]
+
+	touched := false
+	for _, child := range children {
+		if updatesColumn(stmt, child.denormalization.Source) {
+			touched = true
+			break
+		}
+	}
+
+	if !touched {
+		return
+	}
+
+	var parentIDs []interface{}
+
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Model(reflect.New(stmt.Schema.ModelType).Interface()).
+		Clauses(statementConds(stmt)...).
+		Pluck("id", &parentIDs).Error
+	if err != nil {
+		db.AddError(fmt.Errorf("failed to find denormalized parents: %w", err))
+		return
+	}
+
+	db.InstanceSet(denormalizeParentIDKey, parentIDs)
+}
+
+// afterUpdate refreshes children whose foreign key changed and the
+// children of updated parents.
+func (dn *denormalizations) afterUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+
+	for _, d := range dn.byModel[stmt.Schema.ModelType] {
+		if !updatesColumn(stmt, d.ForeignKey) {
+			continue
+		}
+
+		// the conditions already carry the soft delete clause
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Table(stmt.Table).
+			Clauses(statementConds(stmt)...).
+			UpdateColumn(d.Column, gorm.Expr(denormalizedSource(stmt.Schema.Table, d))).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to refresh denormalized %s: %w", d.Column, err))
+			return
+		}
+	}
+
+	value, ok := db.InstanceGet(denormalizeParentIDKey)
+	if !ok {
+		return
+	}
+
+	parentIDs := value.([]interface{})
+	if len(parentIDs) == 0 {
+		return
+	}
+
+	for _, child := range dn.byParent[stmt.Schema.Table] {
+		d := child.denormalization
+		if !updatesColumn(stmt, d.Source) {
+			continue
+		}
+
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Table(child.table).
+			Where(fmt.Sprintf("%s IN ?", d.ForeignKey), parentIDs).
+			UpdateColumn(d.Column, gorm.Expr(denormalizedSource(child.table, d))).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to refresh %s.%s: %w", child.table, d.Column, err))
+			return
+		}
+	}
+}
+
+// denormalizedSource is the subquery selecting the parent's source value
+// for a row of table.
+func denormalizedSource(table string, d Denormalization) string {
+	return fmt.Sprintf(
+		"(SELECT %[1]s.%[2]s FROM %[1]s WHERE %[1]s.id = %[3]s.%[4]s)",
+		d.Table, d.Source, table, d.ForeignKey,
+	)
+}
+
+// statementConds returns the conditions of stmt, including the primary key
+// of the model it was given.
+func statementConds(stmt *gorm.Statement) []clause.Expression {
+	conds := []clause.Expression{}
+
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		conds = append(conds, where.Expression)
+	}
+
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		for _, field := range stmt.Schema.PrimaryFields {
+			if value, isZero := field.ValueOf(stmt.Context, stmt.ReflectValue); !isZero {
+				conds = append(conds, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+			}
+		}
+	}
+
+	return conds
+}
+
+// updatesColumn reports whether an update statement writes column.
+func updatesColumn(stmt *gorm.Statement, column string) bool {
+	field := stmt.Schema.LookUpField(column)
+	if field == nil {
+		return false
+	}
+
+	selects, restricted := stmt.SelectAndOmitColumns(false, true)
+	if selected, ok := selects[field.DBName]; ok {
+		return selected
+	}
+
+	if restricted {
+		return false
+	}
+
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		_, byName := dest[field.Name]
+		_, byColumn := dest[field.DBName]
+
+		return byName || byColumn
+	case nil:
+		return false
+	}
+
+	destValue := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+	if destValue.Kind() != reflect.Struct || destValue.Type() != stmt.Schema.ModelType {
+		return false
+	}
+
+	_, isZero := field.ValueOf(stmt.Context, destValue)
+
+	return !isZero
+}
+
+// RebuildDenormalizations recomputes every denormalized column declared by
+// models, returning the number of rows written.
+func RebuildDenormalizations(ctx context.Context, db DBService, models ModelList) (int64, error) {
+	var schemaCache sync.Map
+	var rows int64
+
+	for _, model := range models {
+		denormalized, ok := model.(DenormalizedModel)
+		if !ok {
+			continue
+		}
+
+		modelSchema, err := schema.Parse(model, &schemaCache, schema.NamingStrategy{})
+		if err != nil {
+			return rows, fmt.Errorf("failed to parse denormalized model %T: %w", model, err)
+		}
+
+		for _, d := range denormalized.Denormalizations() {
+			if err := checkIdentifiers(d.Column, d.ForeignKey, d.Table, d.Source); err != nil {
+				return rows, fmt.Errorf("invalid denormalization on %T: %w", model, err)
+			}
+
+			written, err := db.Exec(ctx, fmt.Sprintf(
+				"UPDATE %s SET %s = %s WHERE %s IS NOT NULL",
+				modelSchema.Table, d.Column, denormalizedSource(modelSchema.Table, d), d.ForeignKey,
+			))
+			if err != nil {
+				return rows, fmt.Errorf("failed to rebuild %s.%s: %w", modelSchema.Table, d.Column, err)
+			}
+
+			rows += written
+		}
+	}
+
+	return rows, nil
+}
+
+type DenormalizeTaskParams struct {
+	fx.In
+
+	DB     DBService
+	Logger LoggerService
+	Models ModelList
+}
+
+// NewDenormalizeTask returns the rebuild-denormalizations task. Register it
+// with AsTask.
+func NewDenormalizeTask(params DenormalizeTaskParams) Task {
+	return Task{
+		Name:        DenormalizeTaskName,
+		Description: "Recompute denormalized columns from their parent rows",
+		Run: func(ctx context.Context, args []string) error {
+			rows, err := RebuildDenormalizations(ctx, params.DB, params.Models)
+			if err != nil {
+				return err
+			}
+
+			params.Logger.Info("Rebuilt denormalizations", "rows", rows)
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// TokenDenylist records revoked tokens by ID until they expire.
+type TokenDenylist interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+// TokenConsumer is implemented by denylists that can revoke a token only if
+// it isn't revoked yet, in one atomic step, so single-use tokens can't be
+// used twice by concurrent requests. Consume reports whether this call
+// revoked the token. Both built-in denylists implement it.
+type TokenConsumer interface {
+	Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+}
+
+type memoryTokenDenylist struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryTokenDenylist keeps revoked tokens in process memory. Revocations
+// are lost on restart and are not shared between instances.
+func NewMemoryTokenDenylist() TokenDenylist {
+	return &memoryTokenDenylist{revoked: make(map[string]time.Time)}
+}
+
+func (d *memoryTokenDenylist) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.purgeExpired()
+	d.revoked[tokenID] = expiresAt
+
+	return nil
+}
+
+func (d *memoryTokenDenylist) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.purgeExpired()
+
+	if _, ok := d.revoked[tokenID]; ok {
+		return false, nil
+	}
+
+	d.revoked[tokenID] = expiresAt
+
+	return true, nil
+}
+
+// purgeExpired drops entries for expired tokens. Callers must hold mu.
+func (d *memoryTokenDenylist) purgeExpired() {
+	now := time.Now()
+	for id, exp := range d.revoked {
+		if exp.Before(now) {
+			delete(d.revoked, id)
+		}
+	}
+}
+
+func (d *memoryTokenDenylist) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, ok := d.revoked[tokenID]
+
+	return ok, nil
+}
+
+// RevokedToken is the model backing the database denylist. Add it to the
+// app's ModelList when using NewDBTokenDenylist.
+type RevokedToken struct {
+	TokenID   string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+type dbTokenDenylist struct {
+	db DBService
+}
+
+func NewDBTokenDenylist(db DBService) TokenDenylist {
+	return &dbTokenDenylist{db: db}
+}
+
+func (d *dbTokenDenylist) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	err := d.db.CreateOne(ctx, &RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// Consume inserts the token unless a row for it exists, relying on the
+// primary key to settle concurrent calls.
+func (d *dbTokenDenylist) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	if d.db.IsReadOnly() {
+		return false, ErrReadOnly
+	}
+
+	sesh, cancel := d.db.GetSession(ctx)
+	defer cancel()
+
+	result := sesh.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to consume token: %w", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (d *dbTokenDenylist) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var revoked RevokedToken
+
+	err := d.db.FindOne(ctx, &revoked, nil, nil, "token_id = ?", tokenID)
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to check token denylist: %w", err)
+	}
+
+	return true, nil
+}
+
+// PurgeExpired deletes denylist entries for tokens that have expired.
+func (d *dbTokenDenylist) PurgeExpired(ctx context.Context) error {
+	sesh, cancel := d.db.GetSession(ctx)
+	defer cancel()
+
+	result := sesh.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to purge expired tokens: %w", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package mochi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const DeprecatedRequestsMetric = "deprecated_route_requests_total"
+
+// Deprecation marks a route as deprecated. Since and Sunset are sent as the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers; Link points clients
+// at migration docs.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+	Link   string
+}
+
+// Deprecate adds deprecation headers to every response and logs and counts
+// each hit so remaining callers can be tracked down before the sunset.
+// metrics may be nil.
+func Deprecate(d Deprecation, logger LoggerService, metrics MetricsService) func(http.Handler) http.Handler {
+	if metrics == nil {
+		metrics = NewNoopMetricsService()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d.setHeaders(w.Header())
+
+			next.ServeHTTP(w, r)
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			logger.WithContext(r.Context()).Warn("Deprecated route called", "method", r.Method, "route", route, "user_agent", r.UserAgent(), "sunset", d.Sunset)
+			metrics.IncCounter(DeprecatedRequestsMetric, 1, MetricLabels{"method": r.Method, "route": route})
+		})
+	}
+}
+
+func (d Deprecation) setHeaders(header http.Header) {
+	if d.Since.IsZero() {
+		header.Set("Deprecation", "true")
+	} else {
+		header.Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+	}
+
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if d.Link != "" {
+		header.Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, d.Link))
+	}
+}
@@ -0,0 +1,105 @@
+package mochi
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Dialect identifies the SQL database behind DBService. PostgreSQL is the
+// default; MySQL and SQLite are supported by providing a gorm.Dialector,
+// with features that have no equivalent degraded as noted on each helper.
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+func dialectOf(db *gorm.DB) Dialect {
+	return Dialect(db.Dialector.Name())
+}
+
+// SupportsMaterializedViews reports whether materialized views can be
+// created. Elsewhere they are created as plain views and refreshing is a
+// no-op.
+func (d Dialect) SupportsMaterializedViews() bool {
+	return d == DialectPostgres
+}
+
+// supportsReturning reports whether writes can return the stored row.
+func (d Dialect) supportsReturning() bool {
+	return d != DialectMySQL
+}
+
+// likeCondition matches column case-insensitively against a pattern
+// escaped with backslashes, such as one from containsPattern. MySQL and
+// SQLite compare case-insensitively with LIKE under their default
+// collations. Only Postgres and MySQL escape with a backslash by default,
+// and MySQL needs it doubled inside the string literal.
+func (d Dialect) likeCondition(column string) string {
+	switch d {
+	case DialectPostgres:
+		return fmt.Sprintf("%s ILIKE ?", column)
+	case DialectMySQL:
+		return fmt.Sprintf(`%s LIKE ? ESCAPE '\\'`, column)
+	default:
+		return fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, column)
+	}
+}
+
+// excluded refers to column of the row proposed by an upsert in its update
+// assignments. MySQL has no excluded table and uses VALUES(column).
+func (d Dialect) excluded(column string) string {
+	if d == DialectMySQL {
+		return fmt.Sprintf("VALUES(%s)", column)
+	}
+
+	return "excluded." + column
+}
+
+// greatest returns the larger of two expressions. SQLite has no GREATEST,
+// but its scalar MAX takes several arguments.
+func (d Dialect) greatest(a, b string) string {
+	if d == DialectSQLite {
+		return fmt.Sprintf("MAX(%s, %s)", a, b)
+	}
+
+	return fmt.Sprintf("GREATEST(%s, %s)", a, b)
+}
+
+// nullsOrder appends the NULLS FIRST/LAST ordering to term. MySQL has no
+// NULLS clause, so an IS NULL sort key is prepended instead.
+func (d Dialect) nullsOrder(column, term string, nulls NullsOrder) string {
+	if nulls == NullsDefault {
+		return term
+	}
+
+	if d == DialectMySQL {
+		if nulls == NullsFirst {
+			return fmt.Sprintf("%s IS NULL DESC, %s", column, term)
+		}
+
+		return fmt.Sprintf("%s IS NULL ASC, %s", column, term)
+	}
+
+	if nulls == NullsFirst {
+		return term + " NULLS FIRST"
+	}
+
+	return term + " NULLS LAST"
+}
+
+func (d Dialect) collate(term, collation string) (string, error) {
+	if strings.ContainsAny(collation, "\"\\`") {
+		return "", fmt.Errorf("invalid collation %q", collation)
+	}
+
+	if d == DialectMySQL {
+		return fmt.Sprintf("%s COLLATE `%s`", term, collation), nil
+	}
+
+	return fmt.Sprintf("%s COLLATE \"%s\"", term, collation), nil
+}
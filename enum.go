@@ -0,0 +1,233 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// EnumValuer is implemented by string types with a fixed set of values,
+// usually by delegating to an Enum:
+//
+//	type TaskStatus string
+//
+//	var TaskStatuses = mochi.NewEnum[TaskStatus]("open", "done")
+//
+//	func (TaskStatus) EnumValues() []string { return TaskStatuses.Strings() }
+//
+//	func (s *TaskStatus) UnmarshalText(text []byte) error {
+//		return TaskStatuses.UnmarshalText(s, text)
+//	}
+//
+// Migrate adds a check constraint limiting columns of EnumValuer types to
+// their values, and ValidateEnums checks them in request binding.
+type EnumValuer interface {
+	EnumValues() []string
+}
+
+// Enum is the ordered set of values of a string type. Values serialize as
+// their strings, so renaming a Go constant doesn't change stored data.
+type Enum[T ~string] struct {
+	values []T
+	valid  map[T]bool
+}
+
+func NewEnum[T ~string](values ...T) Enum[T] {
+	valid := make(map[T]bool, len(values))
+	for _, value := range values {
+		valid[value] = true
+	}
+
+	return Enum[T]{values: values, valid: valid}
+}
+
+func (e Enum[T]) Values() []T {
+	return append([]T(nil), e.values...)
+}
+
+func (e Enum[T]) Strings() []string {
+	strs := make([]string, 0, len(e.values))
+	for _, value := range e.values {
+		strs = append(strs, string(value))
+	}
+
+	return strs
+}
+
+func (e Enum[T]) Valid(value T) bool {
+	return e.valid[value]
+}
+
+// Parse returns value as T, or an ErrValidation listing the allowed values.
+func (e Enum[T]) Parse(value string) (T, error) {
+	if !e.valid[T(value)] {
+		var zero T
+		return zero, fmt.Errorf("%w: %q is not one of %s", ErrValidation, value, strings.Join(e.Strings(), ", "))
+	}
+
+	return T(value), nil
+}
+
+// UnmarshalText parses text into dest, for use in the type's own
+// UnmarshalText so invalid values are rejected when JSON is decoded.
+func (e Enum[T]) UnmarshalText(dest *T, text []byte) error {
+	value, err := e.Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	*dest = value
+
+	return nil
+}
+
+// ValidateEnums checks the EnumValuer, Money, EmailAddress, and PhoneNumber
+// fields of v, a struct or pointer to one, returning an ErrValidation for the
+// first value that isn't allowed. Empty values are skipped so partial updates
+// can leave fields unset.
+func ValidateEnums(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+
+		if field.Anonymous {
+			if err := ValidateEnums(fieldValue.Interface()); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				continue
+			}
+
+			fieldValue = fieldValue.Elem()
+		}
+
+		switch value := fieldValue.Interface().(type) {
+		case Money, EmailAddress, PhoneNumber:
+			if !fieldValue.IsZero() {
+				if err := value.(interface{ Validate() error }).Validate(); err != nil {
+					return fmt.Errorf("%s: %w", field.Name, err)
+				}
+			}
+
+			continue
+		}
+
+		enum, ok := fieldValue.Interface().(EnumValuer)
+		if !ok || fieldValue.Kind() != reflect.String || fieldValue.String() == "" {
+			continue
+		}
+
+		if !containsString(enum.EnumValues(), fieldValue.String()) {
+			return fmt.Errorf(
+				"%w: %s %q is not one of %s",
+				ErrValidation, field.Name, fieldValue.String(), strings.Join(enum.EnumValues(), ", "),
+			)
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enumCheckName names the check constraint of an enum column.
+func enumCheckName(table, column string) string {
+	return fmt.Sprintf("chk_%s_%s", table, column)
+}
+
+// missingEnumCheckStatements returns the statements adding check
+// constraints for the model's enum columns that don't have one yet. SQLite
+// can't add constraints to existing tables, so it's skipped. The
+// constraint isn't updated when values change; replace it with a
+// versioned Migration.
+func missingEnumCheckStatements(db *gorm.DB, model interface{}) ([]string, error) {
+	dialect := dialectOf(db)
+	if dialect == DialectSQLite {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+
+	statements := []string{}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+
+		enum, ok := reflect.Zero(field.IndirectFieldType).Interface().(EnumValuer)
+		if !ok || field.IndirectFieldType.Kind() != reflect.String {
+			continue
+		}
+
+		name := enumCheckName(stmt.Schema.Table, field.DBName)
+		if db.Migrator().HasConstraint(model, name) {
+			continue
+		}
+
+		quoted := make([]string, 0, len(enum.EnumValues()))
+		for _, value := range enum.EnumValues() {
+			quoted = append(quoted, "'"+strings.ReplaceAll(value, "'", "''")+"'")
+		}
+
+		if len(quoted) == 0 {
+			return nil, fmt.Errorf("enum column %s.%s has no values", stmt.Schema.Table, field.DBName)
+		}
+
+		statements = append(statements, fmt.Sprintf(
+			"ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IN (%s))",
+			stmt.Schema.Table, name, field.DBName, strings.Join(quoted, ", "),
+		))
+	}
+
+	return statements, nil
+}
+
+func (srv *dbService) migrateEnumChecks(ctx context.Context, model interface{}) error {
+	sesh := srv.db.WithContext(ctx)
+
+	statements, err := missingEnumCheckStatements(sesh, model)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		srv.logger.Info("Creating enum check", "sql", statement)
+
+		if err := sesh.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create enum check: %w", err)
+		}
+	}
+
+	return nil
+}
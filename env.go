@@ -0,0 +1,114 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+type AppEnv string
+
+const (
+	AppEnvName = "APP_ENV"
+
+	EnvDevelopment AppEnv = "development"
+	EnvTest        AppEnv = "test"
+	EnvStaging     AppEnv = "staging"
+	EnvProduction  AppEnv = "production"
+)
+
+type appEnvContextKey int
+
+const (
+	appEnvKey appEnvContextKey = iota
+)
+
+// NewAppEnv reads the environment from APP_ENV, defaulting to production
+// so a deploy missing the variable doesn't expose development behavior.
+func NewAppEnv() (AppEnv, error) {
+	return ParseAppEnv(os.Getenv(AppEnvName))
+}
+
+func ParseAppEnv(value string) (AppEnv, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "dev", "development", "local":
+		return EnvDevelopment, nil
+	case "test":
+		return EnvTest, nil
+	case "staging", "stage":
+		return EnvStaging, nil
+	case "", "prod", "production":
+		return EnvProduction, nil
+	default:
+		return "", fmt.Errorf("unknown app env %q", value)
+	}
+}
+
+func (e AppEnv) IsDevelopment() bool {
+	return e == EnvDevelopment || e == EnvTest
+}
+
+func (e AppEnv) IsProduction() bool {
+	return e == EnvProduction
+}
+
+// AppEnvMiddleware stores the environment in the request context so
+// renderers can adapt their output.
+func AppEnvMiddleware(env AppEnv) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), appEnvKey, env)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AppEnvFromCtx returns the environment of ctx, or production outside of
+// AppEnvMiddleware.
+func AppEnvFromCtx(ctx context.Context) AppEnv {
+	env, ok := ctx.Value(appEnvKey).(AppEnv)
+	if !ok {
+		return EnvProduction
+	}
+
+	return env
+}
+
+// SecurityHeaders sets baseline security headers, adding HSTS and a strict
+// content security policy outside of development.
+func SecurityHeaders(env AppEnv) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers := w.Header()
+			headers.Set("X-Content-Type-Options", "nosniff")
+
+			if !env.IsDevelopment() {
+				headers.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+				headers.Set("X-Frame-Options", "DENY")
+				headers.Set("Referrer-Policy", "no-referrer")
+				headers.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NotInProduction hides the wrapped routes in production, for debug and
+// other unsafe endpoints.
+func NotInProduction(env AppEnv) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if env.IsProduction() {
+				render.Render(w, r, ErrNotFound)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package mochi
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/render"
@@ -9,6 +11,112 @@ import (
 
 var ErrRecordNotFound = errors.New("record not found")
 
+var ErrReadOnly = errors.New("database is in read-only mode")
+
+// Domain errors that ErrorStatus maps to HTTP status codes. Wrap them with
+// fmt.Errorf("%w: ...", ErrValidation) to keep the classification.
+var (
+	ErrValidation    = errors.New("validation failed")
+	ErrConflict      = errors.New("conflict")
+	ErrForbidden     = errors.New("forbidden")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+var sentinelErrorStatuses = []struct {
+	err    error
+	status int
+}{
+	{ErrRecordNotFound, http.StatusNotFound},
+	{ErrValidation, http.StatusUnprocessableEntity},
+	{ErrConflict, http.StatusConflict},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrQuotaExceeded, http.StatusTooManyRequests},
+	{ErrTenantRequired, http.StatusForbidden},
+	{ErrReadOnly, http.StatusServiceUnavailable},
+	{ErrViewNotWritable, http.StatusMethodNotAllowed},
+	{ErrTooManyResults, http.StatusUnprocessableEntity},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+}
+
+// StatusCoder is implemented by errors that know which HTTP status they
+// should be rendered with.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+type statusError struct {
+	status int
+	err    error
+}
+
+func NewStatusError(status int, err error) error {
+	return &statusError{status: status, err: err}
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func (e *statusError) StatusCode() int {
+	return e.status
+}
+
+// ErrorStatus classifies err into an HTTP status code, falling back to 500.
+func ErrorStatus(err error) int {
+	var coder StatusCoder
+	if errors.As(err, &coder) {
+		return coder.StatusCode()
+	}
+
+	for _, sentinel := range sentinelErrorStatuses {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.status
+		}
+	}
+
+	return http.StatusInternalServerError
+}
+
+// InvalidRequest marks err as a 400 unless it already carries a classification.
+func InvalidRequest(err error) error {
+	if ErrorStatus(err) != http.StatusInternalServerError {
+		return err
+	}
+
+	return NewStatusError(http.StatusBadRequest, err)
+}
+
+// ErrorResponse builds the renderer for err based on its classification.
+func ErrorResponse(err error) render.Renderer {
+	switch status := ErrorStatus(err); status {
+	case http.StatusBadRequest:
+		return ErrInvalidRequest(err)
+	case http.StatusUnauthorized:
+		return ErrUnauthorized(err)
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusInternalServerError:
+		return ErrUnknown(err)
+	default:
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: status,
+			StatusText:     http.StatusText(status),
+			ErrorText:      err.Error(),
+		}
+	}
+}
+
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	render.Render(w, r, ErrorResponse(err))
+}
+
 type ErrResponse struct {
 	Err            error `json:"-"` // low-level runtime error
 	HTTPStatusCode int   `json:"-"` // http response status code
@@ -21,6 +129,20 @@ type ErrResponse struct {
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	render.Status(r, e.HTTPStatusCode)
 
+	if e.HTTPStatusCode >= http.StatusInternalServerError {
+		err := e.Err
+		if err == nil {
+			err = errors.New(e.StatusText)
+		}
+
+		ReportError(r, err, e.HTTPStatusCode)
+
+		// internal error details are only exposed outside of production
+		if AppEnvFromCtx(r.Context()).IsProduction() {
+			e.ErrorText = ""
+		}
+	}
+
 	return nil
 }
 
@@ -53,6 +175,8 @@ func ErrRender(err error) render.Renderer {
 
 var ErrNotFound = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found."}
 
+var ErrOverloaded = &ErrResponse{HTTPStatusCode: 503, StatusText: "Service overloaded, retry later."}
+
 func ErrUnknown(err error) render.Renderer {
 	return &ErrResponse{
 		Err:            err,
@@ -61,3 +185,25 @@ func ErrUnknown(err error) render.Renderer {
 		ErrorText:      err.Error(),
 	}
 }
+
+// ErrPanic reports a recovered panic. Like other server errors, the panic
+// value is only included outside of production.
+func ErrPanic(recovered interface{}) render.Renderer {
+	return &ErrResponse{
+		Err:            fmt.Errorf("panic: %v", recovered),
+		HTTPStatusCode: 500,
+		StatusText:     "Internal server error.",
+		ErrorText:      fmt.Sprint(recovered),
+	}
+}
+
+func ErrInjectedFault(status int) render.Renderer {
+	err := fmt.Errorf("fault injected by chaos middleware")
+
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: status,
+		StatusText:     http.StatusText(status),
+		ErrorText:      err.Error(),
+	}
+}
@@ -0,0 +1,112 @@
+package mochi
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ErrorReport describes a server error rendered for a request.
+type ErrorReport struct {
+	// Err is the error as returned by the failing service or handler, so
+	// reporters can unwrap it with errors.Is and errors.As.
+	Err       error
+	Status    int
+	Method    string
+	Path      string
+	Route     string
+	RequestID string
+	// Stack is set for recovered panics.
+	Stack []byte
+}
+
+// ErrorReporter forwards server errors to a service such as Sentry or
+// Rollbar. It is called once for each request answered with a 5xx
+// ErrResponse, a controller error, or a recovered panic, with the request
+// context, so implementations must not block the request for long. Apps
+// register an adapter with ProvideErrorReporter.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, report ErrorReport)
+}
+
+type ErrorReporterFunc func(ctx context.Context, report ErrorReport)
+
+func (f ErrorReporterFunc) ReportError(ctx context.Context, report ErrorReport) {
+	f(ctx, report)
+}
+
+type errorReporterContextKey int
+
+const errorReporterKey errorReporterContextKey = 0
+
+// errorReporting tracks whether the request's error was reported, as the
+// paths rendering it, such as a controller using ErrResponse, overlap.
+// Handlers may report from several goroutines, so the flag is atomic.
+type errorReporting struct {
+	reporter ErrorReporter
+	reported atomic.Bool
+}
+
+// ContextWithErrorReporter makes ReportError use reporter for ctx.
+func ContextWithErrorReporter(ctx context.Context, reporter ErrorReporter) context.Context {
+	return context.WithValue(ctx, errorReporterKey, &errorReporting{reporter: reporter})
+}
+
+// ErrorReporterFromCtx returns the reporter of ctx, or a no-op reporter.
+func ErrorReporterFromCtx(ctx context.Context) ErrorReporter {
+	reporting, ok := ctx.Value(errorReporterKey).(*errorReporting)
+	if !ok {
+		return noopErrorReporter{}
+	}
+
+	return reporting.reporter
+}
+
+// ErrorReporting makes reporter available to the handlers of each request.
+func ErrorReporting(reporter ErrorReporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithErrorReporter(r.Context(), reporter)))
+		})
+	}
+}
+
+// ReportError sends err to the error reporter of the request, describing
+// the request it failed. Only the first error of a request is reported.
+// Handlers writing 5xx responses without ErrResponse can call it to be
+// reported like controller routes.
+func ReportError(r *http.Request, err error, status int) {
+	reportError(r, err, status, nil)
+}
+
+func reportError(r *http.Request, err error, status int, stack []byte) {
+	reporting, ok := r.Context().Value(errorReporterKey).(*errorReporting)
+	if !ok || !reporting.reported.CompareAndSwap(false, true) {
+		return
+	}
+
+	report := ErrorReport{
+		Err:       err,
+		Status:    status,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		RequestID: RequestIDFromCtx(r.Context()),
+		Stack:     stack,
+	}
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		report.Route = rctx.RoutePattern()
+	}
+
+	reporting.reporter.ReportError(r.Context(), report)
+}
+
+type noopErrorReporter struct{}
+
+func NewNoopErrorReporter() ErrorReporter {
+	return noopErrorReporter{}
+}
+
+func (noopErrorReporter) ReportError(ctx context.Context, report ErrorReport) {}
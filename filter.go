@@ -0,0 +1,407 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// FilterOperator compares a column with a filter value.
+type FilterOperator string
+
+const (
+	FilterEq FilterOperator = "eq"
+
+	// FilterIlike matches values containing the filter value, ignoring case.
+	FilterIlike FilterOperator = "ilike"
+
+	// FilterUnaccent matches values containing the filter value, ignoring
+	// case and accents. It requires the PostgreSQL unaccent extension.
+	FilterUnaccent FilterOperator = "unaccent"
+
+	// FilterGte, FilterLte, and FilterBetween compare numbers, dates, and
+	// timestamps. Between takes "low,high" and is inclusive. A date without
+	// a time as the upper bound covers that whole day.
+	FilterGte     FilterOperator = "gte"
+	FilterLte     FilterOperator = "lte"
+	FilterBetween FilterOperator = "between"
+)
+
+// Filter values with special meaning for FilterEq. FilterNullValue matches
+// NULL columns. FilterEmptyValue matches NULL or empty string columns and
+// associations without any rows, such as a task without tags.
+const (
+	FilterNullValue  = "null"
+	FilterEmptyValue = "empty"
+)
+
+const (
+	filterDateLayout = "2006-01-02"
+)
+
+// FilterField allows filtering a list by a column. A bare field=value
+// parameter uses the first operator; others are selected with
+// field[operator]=value. Operators defaults to FilterEq.
+type FilterField struct {
+	Name      string
+	Operators []FilterOperator
+}
+
+func (f FilterField) operators() []FilterOperator {
+	if len(f.Operators) == 0 {
+		return []FilterOperator{FilterEq}
+	}
+
+	return f.Operators
+}
+
+func (f FilterField) allows(op FilterOperator) bool {
+	for _, allowed := range f.operators() {
+		if allowed == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Filter struct {
+	Field string
+	Op    FilterOperator
+	Value string
+}
+
+// ParseFilters reads the filters for fields from query parameters.
+// Parameters for other fields are ignored.
+func ParseFilters(query url.Values, fields []FilterField) ([]Filter, error) {
+	filters := []Filter{}
+
+	for _, field := range fields {
+		if values, ok := query[field.Name]; ok {
+			filters = append(filters, Filter{Field: field.Name, Op: field.operators()[0], Value: values[0]})
+		}
+
+		prefix := field.Name + "["
+
+		for param, values := range query {
+			if !strings.HasPrefix(param, prefix) || !strings.HasSuffix(param, "]") {
+				continue
+			}
+
+			op := FilterOperator(param[len(prefix) : len(param)-1])
+			if !field.allows(op) {
+				return nil, fmt.Errorf("operator %q is not allowed for %s", op, field.Name)
+			}
+
+			filters = append(filters, Filter{Field: field.Name, Op: op, Value: values[0]})
+		}
+	}
+
+	return filters, nil
+}
+
+type filterContextKey[M any] struct{}
+
+// WithFilters narrows lists of M loaded with the returned context. Filters
+// are scoped to the model type like WithSort.
+func WithFilters[M any](ctx context.Context, filters ...Filter) context.Context {
+	return context.WithValue(ctx, filterContextKey[M]{}, filters)
+}
+
+func FiltersFromCtx[M any](ctx context.Context) []Filter {
+	filters, _ := ctx.Value(filterContextKey[M]{}).([]Filter)
+	return filters
+}
+
+// filterQuery builds the conditions for filters, qualifying each field with
+// column. Values for fields of modelSchema are coerced to the field's Go
+// type; modelSchema may be nil when the model can't be parsed.
+func filterQuery(
+	filters []Filter,
+	column func(string) string,
+	modelSchema *schema.Schema,
+	dialect Dialect,
+) (string, []interface{}, error) {
+	conditions := make([]string, 0, len(filters))
+	args := make([]interface{}, 0, len(filters))
+
+	for _, filter := range filters {
+		if !isValidColumnName(filter.Field) {
+			return "", nil, fmt.Errorf("invalid filter field %q", filter.Field)
+		}
+
+		col := column(filter.Field)
+		typ, typed := schemaFieldType(modelSchema, filter.Field)
+
+		switch filter.Op {
+		case FilterEq, "":
+			if filter.Value == FilterNullValue {
+				conditions = append(conditions, fmt.Sprintf("%s IS NULL", col))
+				continue
+			}
+
+			if filter.Value == FilterEmptyValue {
+				condition, err := emptyCondition(modelSchema, filter.Field, col)
+				if err != nil {
+					return "", nil, err
+				}
+
+				conditions = append(conditions, condition)
+				continue
+			}
+
+			value, _, err := coerceFilterValue(typ, typed, filter.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid value for %s: %w", filter.Field, err)
+			}
+
+			conditions = append(conditions, fmt.Sprintf("%s = ?", col))
+			args = append(args, value)
+		case FilterIlike:
+			conditions = append(conditions, dialect.likeCondition(col))
+			args = append(args, containsPattern(filter.Value))
+		case FilterUnaccent:
+			if dialect != DialectPostgres {
+				return "", nil, fmt.Errorf("the %s operator is not supported on %s", FilterUnaccent, dialect)
+			}
+
+			conditions = append(conditions, fmt.Sprintf("unaccent(%s) ILIKE unaccent(?)", col))
+			args = append(args, containsPattern(filter.Value))
+		case FilterGte:
+			value, _, err := coerceRangeValue(typ, typed, filter.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid value for %s: %w", filter.Field, err)
+			}
+
+			conditions = append(conditions, fmt.Sprintf("%s >= ?", col))
+			args = append(args, value)
+		case FilterLte:
+			condition, value, err := upperBound(col, typ, typed, filter.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid value for %s: %w", filter.Field, err)
+			}
+
+			conditions = append(conditions, condition)
+			args = append(args, value)
+		case FilterBetween:
+			low, high, ok := strings.Cut(filter.Value, ",")
+			if !ok {
+				return "", nil, fmt.Errorf("between filter for %s requires low,high", filter.Field)
+			}
+
+			lowValue, _, err := coerceRangeValue(typ, typed, low)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid value for %s: %w", filter.Field, err)
+			}
+
+			condition, highValue, err := upperBound(col, typ, typed, high)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid value for %s: %w", filter.Field, err)
+			}
+
+			conditions = append(conditions, fmt.Sprintf("%s >= ? AND %s", col, condition))
+			args = append(args, lowValue, highValue)
+		default:
+			return "", nil, fmt.Errorf("unknown filter operator %q", filter.Op)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+func schemaFieldType(modelSchema *schema.Schema, column string) (reflect.Type, bool) {
+	if modelSchema == nil {
+		return nil, false
+	}
+
+	field, ok := modelSchema.FieldsByDBName[column]
+	if !ok {
+		return nil, false
+	}
+
+	return field.IndirectFieldType, true
+}
+
+// emptyCondition matches an empty association with a NOT EXISTS subquery
+// and an empty column with IS NULL, treating empty strings as empty too.
+func emptyCondition(modelSchema *schema.Schema, field, col string) (string, error) {
+	if modelSchema == nil {
+		return fmt.Sprintf("%s IS NULL", col), nil
+	}
+
+	for _, rel := range modelSchema.Relationships.Relations {
+		if (schema.NamingStrategy{}).ColumnName("", rel.Name) != field {
+			continue
+		}
+
+		return emptyAssociationCondition(modelSchema, rel)
+	}
+
+	if typ, ok := schemaFieldType(modelSchema, field); ok && typ.Kind() == reflect.String {
+		return fmt.Sprintf("(%s IS NULL OR %s = '')", col, col), nil
+	}
+
+	return fmt.Sprintf("%s IS NULL", col), nil
+}
+
+func emptyAssociationCondition(modelSchema *schema.Schema, rel *schema.Relationship) (string, error) {
+	table := rel.FieldSchema.Table
+	if rel.Type == schema.Many2Many {
+		table = rel.JoinTable.Table
+	}
+
+	if rel.Type == schema.BelongsTo {
+		conditions := []string{}
+		for _, ref := range rel.References {
+			conditions = append(conditions, fmt.Sprintf("%s.%s IS NULL", modelSchema.Table, ref.ForeignKey.DBName))
+		}
+
+		return strings.Join(conditions, " AND "), nil
+	}
+
+	joins := []string{}
+	for _, ref := range rel.References {
+		if ref.OwnPrimaryKey {
+			joins = append(joins, fmt.Sprintf("%s.%s = %s.%s", table, ref.ForeignKey.DBName, modelSchema.Table, ref.PrimaryKey.DBName))
+		} else if ref.PrimaryValue != "" {
+			joins = append(joins, fmt.Sprintf("%s.%s = '%s'", table, ref.ForeignKey.DBName, strings.ReplaceAll(ref.PrimaryValue, "'", "''")))
+		}
+	}
+
+	if len(joins) == 0 {
+		return "", fmt.Errorf("cannot filter %s by empty association %s", modelSchema.Table, rel.Name)
+	}
+
+	return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s)", table, strings.Join(joins, " AND ")), nil
+}
+
+// upperBound builds an inclusive upper bound condition. Date-only bounds on
+// timestamp fields extend to the end of that day.
+func upperBound(col string, typ reflect.Type, typed bool, raw string) (string, interface{}, error) {
+	value, dateOnly, err := coerceRangeValue(typ, typed, raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if dateOnly {
+		return fmt.Sprintf("%s < ?", col), value.(time.Time).AddDate(0, 0, 1), nil
+	}
+
+	return fmt.Sprintf("%s <= ?", col), value, nil
+}
+
+// coerceRangeValue coerces a range bound, which only makes sense for
+// ordered types.
+func coerceRangeValue(typ reflect.Type, typed bool, raw string) (interface{}, bool, error) {
+	if typed && !isOrderedFilterType(typ) {
+		return nil, false, fmt.Errorf("range filters are not supported for %s", typ)
+	}
+
+	return coerceFilterValue(typ, typed, raw)
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	emailType = reflect.TypeOf(EmailAddress(""))
+	phoneType = reflect.TypeOf(PhoneNumber(""))
+)
+
+func isOrderedFilterType(typ reflect.Type) bool {
+	if typ == timeType {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// coerceFilterValue converts raw to the field's Go type. It also reports
+// whether a time value was given as a date without a time.
+func coerceFilterValue(typ reflect.Type, typed bool, raw string) (interface{}, bool, error) {
+	raw = strings.TrimSpace(raw)
+
+	if !typed {
+		return raw, false, nil
+	}
+
+	if typ == timeType {
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, false, nil
+		}
+
+		t, err := time.Parse(filterDateLayout, raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("expected an RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+
+		return t, true, nil
+	}
+
+	switch typ {
+	case emailType:
+		email, err := ParseEmailAddress(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("expected an email address")
+		}
+
+		return string(email), false, nil
+	case phoneType:
+		phone, err := ParsePhoneNumber(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("expected a phone number in international format")
+		}
+
+		return string(phone), false, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, typ.Bits())
+		if err != nil {
+			return nil, false, fmt.Errorf("expected an integer")
+		}
+
+		return n, false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, typ.Bits())
+		if err != nil {
+			return nil, false, fmt.Errorf("expected a non-negative integer")
+		}
+
+		return n, false, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, typ.Bits())
+		if err != nil {
+			return nil, false, fmt.Errorf("expected a number")
+		}
+
+		return f, false, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("expected a boolean")
+		}
+
+		return b, false, nil
+	default:
+		return raw, false, nil
+	}
+}
+
+// containsPattern escapes LIKE wildcards in value and matches it anywhere.
+func containsPattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,227 @@
+package mochi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes and verifies user passwords. NeedsRehash reports
+// hashes made with an outdated algorithm or cost, which LoginUser upgrades
+// after a successful login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+	NeedsRehash(hash string) bool
+}
+
+// PasswordCredentialStore is implemented by UserServices that store hashes
+// produced by a PasswordHasher. When available, LoginUser verifies
+// passwords itself instead of calling GetUserByCredentials.
+type PasswordCredentialStore interface {
+	GetUserPasswordHash(ctx context.Context, username string) (User, string, error)
+	SetUserPasswordHash(ctx context.Context, userID uint, hash string) error
+}
+
+// hashRecognizer is implemented by hashers that can tell whether a hash was
+// produced by their algorithm.
+type hashRecognizer interface {
+	recognizes(hash string) bool
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) PasswordHasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to verify password: %w", err)
+	}
+
+	return true, nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+func (h *bcryptHasher) recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Argon2Params tunes argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) PasswordHasher {
+	return &argon2idHasher{params: params}
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	b64 := base64.RawStdEncoding.EncodeToString
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism, b64(salt), b64(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(hash, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := decodeArgon2idHash(hash)
+
+	return err != nil ||
+		params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		params.KeyLength != h.params.KeyLength
+}
+
+func (h *argon2idHasher) recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func decodeArgon2idHash(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	var version int
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+type upgradingHasher struct {
+	preferred PasswordHasher
+	legacy    []PasswordHasher
+}
+
+// NewUpgradingHasher hashes new passwords with preferred and still verifies
+// hashes made by the legacy hashers, reporting them as needing a rehash.
+func NewUpgradingHasher(preferred PasswordHasher, legacy ...PasswordHasher) PasswordHasher {
+	return &upgradingHasher{preferred: preferred, legacy: legacy}
+}
+
+// DefaultPasswordHasher hashes with argon2id and accepts existing bcrypt
+// hashes, upgrading them on login.
+func DefaultPasswordHasher() PasswordHasher {
+	return NewUpgradingHasher(NewArgon2idHasher(DefaultArgon2Params), NewBcryptHasher(bcrypt.DefaultCost))
+}
+
+func (h *upgradingHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *upgradingHasher) Verify(hash, password string) (bool, error) {
+	for _, hasher := range append([]PasswordHasher{h.preferred}, h.legacy...) {
+		if recognizer, ok := hasher.(hashRecognizer); ok && !recognizer.recognizes(hash) {
+			continue
+		}
+
+		return hasher.Verify(hash, password)
+	}
+
+	return false, fmt.Errorf("unrecognized password hash format")
+}
+
+func (h *upgradingHasher) NeedsRehash(hash string) bool {
+	if recognizer, ok := h.preferred.(hashRecognizer); ok && !recognizer.recognizes(hash) {
+		return true
+	}
+
+	return h.preferred.NeedsRehash(hash)
+}
+
+func (h *upgradingHasher) recognizes(hash string) bool {
+	for _, hasher := range append([]PasswordHasher{h.preferred}, h.legacy...) {
+		if recognizer, ok := hasher.(hashRecognizer); !ok || recognizer.recognizes(hash) {
+			return true
+		}
+	}
+
+	return false
+}
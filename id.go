@@ -0,0 +1,74 @@
+package mochi
+
+import (
+	"encoding"
+	"fmt"
+	"strconv"
+)
+
+// IDParser converts a URL parameter into a model ID.
+type IDParser[K comparable] func(string) (K, error)
+
+// ParseID is the default IDParser. It handles integer and string keys as
+// well as any key type implementing encoding.TextUnmarshaler, such as UUIDs.
+func ParseID[K comparable](value string) (K, error) {
+	var id K
+
+	if unmarshaler, ok := any(&id).(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(value)); err != nil {
+			return id, fmt.Errorf("failed to parse ID: %w", err)
+		}
+
+		return id, nil
+	}
+
+	var parsed any
+	var err error
+
+	switch any(id).(type) {
+	case string:
+		parsed = value
+	case uint:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 0)
+		parsed = uint(n)
+	case uint32:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 32)
+		parsed = uint32(n)
+	case uint64:
+		parsed, err = strconv.ParseUint(value, 10, 64)
+	case int:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 0)
+		parsed = int(n)
+	case int32:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		parsed = int32(n)
+	case int64:
+		parsed, err = strconv.ParseInt(value, 10, 64)
+	default:
+		return id, fmt.Errorf("no default ID parser for %T", id)
+	}
+
+	if err != nil {
+		return id, fmt.Errorf("failed to parse ID: %w", err)
+	}
+
+	return parsed.(K), nil
+}
+
+// formatID is the inverse of ParseID.
+func formatID[K comparable](id K) (string, error) {
+	if marshaler, ok := any(id).(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("failed to format ID: %w", err)
+		}
+
+		return string(text), nil
+	}
+
+	return fmt.Sprint(id), nil
+}
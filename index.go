@@ -0,0 +1,161 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// IndexSpec declares an index that Migrate creates when missing. Columns
+// may be plain column names or expressions such as lower(email).
+type IndexSpec struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Using   string
+	Where   string
+}
+
+// IndexedModel is implemented by models that need indexes AutoMigrate does
+// not create, such as partial or GIN indexes.
+type IndexedModel interface {
+	Indexes() []IndexSpec
+}
+
+// UserScopedIndex declares the btree index backing the user_id scoped
+// queries every repository issues.
+func UserScopedIndex(table string, columns ...string) IndexSpec {
+	return IndexSpec{
+		Name:    fmt.Sprintf("idx_%s_user_id", table),
+		Columns: append([]string{"user_id"}, columns...),
+	}
+}
+
+// statement builds the CREATE INDEX statement for dialect. MySQL has no
+// partial indexes or IF NOT EXISTS, and SQLite ignores Using since it only
+// has btree indexes.
+func (spec IndexSpec) statement(table string, dialect Dialect) (string, error) {
+	if !isValidColumnName(spec.Name) {
+		return "", fmt.Errorf("invalid index name %q", spec.Name)
+	}
+
+	if len(spec.Columns) == 0 {
+		return "", fmt.Errorf("index %s has no columns", spec.Name)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("CREATE ")
+	if spec.Unique {
+		sb.WriteString("UNIQUE ")
+	}
+
+	switch dialect {
+	case DialectMySQL:
+		if spec.Where != "" {
+			return "", fmt.Errorf("index %s: partial indexes are not supported on %s", spec.Name, dialect)
+		}
+
+		fmt.Fprintf(&sb, "INDEX %s", spec.Name)
+
+		if spec.Using != "" {
+			fmt.Fprintf(&sb, " USING %s", spec.Using)
+		}
+
+		fmt.Fprintf(&sb, " ON %s", table)
+	case DialectSQLite:
+		fmt.Fprintf(&sb, "INDEX IF NOT EXISTS %s ON %s", spec.Name, table)
+	default:
+		fmt.Fprintf(&sb, "INDEX IF NOT EXISTS %s ON %s", spec.Name, table)
+
+		if spec.Using != "" {
+			fmt.Fprintf(&sb, " USING %s", spec.Using)
+		}
+	}
+
+	fmt.Fprintf(&sb, " (%s)", strings.Join(spec.Columns, ", "))
+
+	if spec.Where != "" {
+		fmt.Fprintf(&sb, " WHERE %s", spec.Where)
+	}
+
+	return sb.String(), nil
+}
+
+// missingIndexStatements returns the statements needed to create the
+// model's declared indexes that don't exist yet.
+func missingIndexStatements(db *gorm.DB, model interface{}) ([]string, error) {
+	indexed, ok := model.(IndexedModel)
+	if !ok {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+	}
+
+	statements := []string{}
+
+	for _, spec := range indexed.Indexes() {
+		if db.Migrator().HasIndex(model, spec.Name) {
+			continue
+		}
+
+		statement, err := spec.statement(stmt.Schema.Table, dialectOf(db))
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+func (srv *dbService) migrateIndexes(ctx context.Context, model interface{}) error {
+	sesh := srv.db.WithContext(ctx)
+
+	statements, err := missingIndexStatements(sesh, model)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		srv.logger.Info("Creating index", "sql", statement)
+
+		if err := sesh.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// VerifyIndexes reports the declared indexes that are missing from the
+// database.
+func (srv *dbService) VerifyIndexes(ctx context.Context) ([]string, error) {
+	missing := []string{}
+
+	for _, model := range srv.models {
+		indexed, ok := model.(IndexedModel)
+		if !ok {
+			continue
+		}
+
+		// only tables and materialized views carry indexes
+		if _, isView := model.(ViewModel); isView && !isMaterializedView(srv.db, model) {
+			continue
+		}
+
+		for _, spec := range indexed.Indexes() {
+			if !srv.db.WithContext(ctx).Migrator().HasIndex(model, spec.Name) {
+				missing = append(missing, spec.Name)
+			}
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,378 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	IntegrityChecksGroup      = `group:"integrity_checks"`
+	IntegrityCheckInterval    = 6 * time.Hour
+	IntegrityCheckTimeout     = time.Minute
+	IntegritySampleSize       = 20
+	IntegrityViolationsMetric = "mochi_integrity_violations"
+	IntegrityFailuresMetric   = "mochi_integrity_failures_total"
+)
+
+// IntegrityCheck is an invariant of the data, such as "every comment has a
+// post". Check returns the number of rows violating it and a few samples.
+// OrphanCheck, CounterCheck, and QueryCheck cover the common cases.
+type IntegrityCheck struct {
+	Name        string
+	Description string
+	Check       func(ctx context.Context, db DBService) (violations int64, samples []map[string]interface{}, err error)
+}
+
+// AsIntegrityCheck annotates a constructor returning an IntegrityCheck so
+// the IntegrityService evaluates it.
+func AsIntegrityCheck(constructor interface{}) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(IntegrityChecksGroup)))
+}
+
+// QueryCheck is violated by every row sql selects.
+func QueryCheck(name, description, sql string, args ...interface{}) IntegrityCheck {
+	return IntegrityCheck{
+		Name:        name,
+		Description: description,
+		Check: func(ctx context.Context, db DBService) (int64, []map[string]interface{}, error) {
+			var count int64
+			countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS violations", sql)
+
+			if err := db.Query(ctx, &count, countSQL, args...); err != nil {
+				return 0, nil, err
+			}
+
+			if count == 0 {
+				return 0, nil, nil
+			}
+
+			samples := []map[string]interface{}{}
+			sampleSQL := fmt.Sprintf("SELECT * FROM (%s) AS violations LIMIT %d", sql, IntegritySampleSize)
+
+			if err := db.Query(ctx, &samples, sampleSQL, args...); err != nil {
+				return count, nil, err
+			}
+
+			return count, samples, nil
+		},
+	}
+}
+
+// OrphanCheck finds rows of table whose foreignKey points at a missing row
+// of parentTable.
+func OrphanCheck(table, foreignKey, parentTable string) IntegrityCheck {
+	name := fmt.Sprintf("%s.%s orphans", table, foreignKey)
+
+	if err := checkIdentifiers(table, foreignKey, parentTable); err != nil {
+		return failingCheck(name, err)
+	}
+
+	return QueryCheck(
+		name,
+		fmt.Sprintf("%s rows whose %s has no %s row", table, foreignKey, parentTable),
+		fmt.Sprintf(
+			"SELECT child.id, child.%[2]s FROM %[1]s AS child LEFT JOIN %[3]s AS parent ON parent.id = child.%[2]s "+
+				"WHERE child.%[2]s IS NOT NULL AND parent.id IS NULL",
+			table, foreignKey, parentTable,
+		),
+	)
+}
+
+// CounterCheck finds rows of table whose counterColumn differs from the
+// number of childTable rows pointing at them with foreignKey.
+func CounterCheck(table, counterColumn, childTable, foreignKey string) IntegrityCheck {
+	name := fmt.Sprintf("%s.%s counter", table, counterColumn)
+
+	if err := checkIdentifiers(table, counterColumn, childTable, foreignKey); err != nil {
+		return failingCheck(name, err)
+	}
+
+	return QueryCheck(
+		name,
+		fmt.Sprintf("%s rows whose %s doesn't match their %s", table, counterColumn, childTable),
+		fmt.Sprintf(
+			"SELECT t.id, t.%[2]s, (SELECT COUNT(*) FROM %[3]s AS c WHERE c.%[4]s = t.id) AS actual FROM %[1]s AS t "+
+				"WHERE t.%[2]s <> (SELECT COUNT(*) FROM %[3]s AS c WHERE c.%[4]s = t.id)",
+			table, counterColumn, childTable, foreignKey,
+		),
+	)
+}
+
+func checkIdentifiers(names ...string) error {
+	for _, name := range names {
+		if !isValidColumnName(name) {
+			return fmt.Errorf("invalid identifier %q", name)
+		}
+	}
+
+	return nil
+}
+
+func failingCheck(name string, err error) IntegrityCheck {
+	return IntegrityCheck{
+		Name: name,
+		Check: func(ctx context.Context, db DBService) (int64, []map[string]interface{}, error) {
+			return 0, nil, err
+		},
+	}
+}
+
+// IntegrityFinding is the result of one check.
+type IntegrityFinding struct {
+	Check       string                   `json:"check"`
+	Description string                   `json:"description,omitempty"`
+	Violations  int64                    `json:"violations"`
+	Samples     []map[string]interface{} `json:"samples,omitempty"`
+	CheckedAt   time.Time                `json:"checked_at"`
+	Duration    string                   `json:"duration"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+func (f *IntegrityFinding) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// IntegrityAlertHook is told about checks that start failing or whose
+// violations grow, for example to page someone.
+type IntegrityAlertHook interface {
+	IntegrityAlert(ctx context.Context, findings []IntegrityFinding) error
+}
+
+type IntegrityAlertHookFunc func(ctx context.Context, findings []IntegrityFinding) error
+
+func (f IntegrityAlertHookFunc) IntegrityAlert(ctx context.Context, findings []IntegrityFinding) error {
+	return f(ctx, findings)
+}
+
+// IntegrityService evaluates the registered IntegrityChecks every
+// IntegrityCheckInterval, logging violations and alerting the optional
+// IntegrityAlertHook. GetRouter serves the latest findings to admins at
+// GET / and runs the checks at POST /run.
+type IntegrityService interface {
+	Run(ctx context.Context) []IntegrityFinding
+	Findings() []IntegrityFinding
+
+	GetRouter() *chi.Mux
+}
+
+type IntegrityServiceParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Auth      AuthService
+	DB        DBService
+	Logger    LoggerService
+	Metrics   MetricsService
+	Checks    []IntegrityCheck   `group:"integrity_checks"`
+	Hook      IntegrityAlertHook `optional:"true"`
+	Shutdown  *Shutdown          `optional:"true"`
+}
+
+type IntegrityServiceResult struct {
+	fx.Out
+
+	IntegrityService IntegrityService
+}
+
+type integrityService struct {
+	checks  []IntegrityCheck
+	db      DBService
+	hook    IntegrityAlertHook
+	logger  LoggerService
+	metrics MetricsService
+	Router  *chi.Mux
+
+	running sync.Mutex
+
+	mu       sync.RWMutex
+	findings []IntegrityFinding
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewIntegrityService(params IntegrityServiceParams) (IntegrityServiceResult, error) {
+	seen := make(map[string]bool, len(params.Checks))
+
+	for _, check := range params.Checks {
+		if check.Name == "" || check.Check == nil {
+			return IntegrityServiceResult{}, fmt.Errorf("integrity checks need a name and a check func")
+		}
+
+		if seen[check.Name] {
+			return IntegrityServiceResult{}, fmt.Errorf("duplicate integrity check %q", check.Name)
+		}
+
+		seen[check.Name] = true
+	}
+
+	srv := &integrityService{
+		checks:  params.Checks,
+		db:      params.DB,
+		hook:    params.Hook,
+		logger:  params.Logger,
+		metrics: params.Metrics,
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Get("/", srv.handleFindings)
+	srv.Router.Post("/run", srv.handleRun)
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			srv.stop = make(chan struct{})
+			srv.done = make(chan struct{})
+
+			go srv.run()
+
+			return nil
+		},
+	})
+
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "integrity checks",
+		Stage: StageWorkers,
+		Stop: func(ctx context.Context) error {
+			close(srv.stop)
+
+			select {
+			case <-srv.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+
+	return IntegrityServiceResult{IntegrityService: srv}, nil
+}
+
+// Run evaluates every check and records the findings. A check that errors
+// doesn't stop the others; its finding carries the error.
+func (srv *integrityService) Run(ctx context.Context) []IntegrityFinding {
+	srv.running.Lock()
+	defer srv.running.Unlock()
+
+	findings := make([]IntegrityFinding, 0, len(srv.checks))
+
+	for _, check := range srv.checks {
+		findings = append(findings, srv.evaluate(ctx, check))
+	}
+
+	srv.mu.Lock()
+	previous := make(map[string]int64, len(srv.findings))
+	for _, finding := range srv.findings {
+		previous[finding.Check] = finding.Violations
+	}
+
+	srv.findings = findings
+	srv.mu.Unlock()
+
+	worse := []IntegrityFinding{}
+	for _, finding := range findings {
+		if finding.Violations > previous[finding.Check] {
+			worse = append(worse, finding)
+		}
+	}
+
+	if len(worse) > 0 && srv.hook != nil {
+		if err := srv.hook.IntegrityAlert(ctx, worse); err != nil {
+			srv.logger.Error("Failed to deliver integrity alert", "error", err)
+		}
+	}
+
+	return findings
+}
+
+// Findings returns the findings of the latest run.
+func (srv *integrityService) Findings() []IntegrityFinding {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.findings
+}
+
+func (srv *integrityService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *integrityService) evaluate(ctx context.Context, check IntegrityCheck) IntegrityFinding {
+	started := time.Now()
+	labels := MetricLabels{"check": check.Name}
+
+	finding := IntegrityFinding{Check: check.Name, Description: check.Description, CheckedAt: started}
+
+	violations, samples, err := check.Check(WithQueryTimeout(ctx, IntegrityCheckTimeout), srv.db)
+	finding.Duration = time.Since(started).String()
+
+	if err != nil {
+		srv.metrics.IncCounter(IntegrityFailuresMetric, 1, labels)
+		srv.logger.Error("Integrity check failed", "check", check.Name, "error", err)
+
+		finding.Error = err.Error()
+
+		return finding
+	}
+
+	finding.Violations = violations
+	finding.Samples = samples
+
+	srv.metrics.SetGauge(IntegrityViolationsMetric, float64(violations), labels)
+
+	if violations > 0 {
+		srv.logger.Warn("Integrity check found violations", "check", check.Name, "violations", violations)
+	}
+
+	return finding
+}
+
+func (srv *integrityService) run() {
+	defer close(srv.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-srv.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(IntegrityCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-srv.stop:
+			return
+		case <-ticker.C:
+			srv.Run(ctx)
+		}
+	}
+}
+
+func (srv *integrityService) handleFindings(w http.ResponseWriter, r *http.Request) {
+	srv.renderFindings(w, r, srv.Findings())
+}
+
+func (srv *integrityService) handleRun(w http.ResponseWriter, r *http.Request) {
+	srv.renderFindings(w, r, srv.Run(r.Context()))
+}
+
+func (srv *integrityService) renderFindings(w http.ResponseWriter, r *http.Request, findings []IntegrityFinding) {
+	respList := make([]render.Renderer, 0, len(findings))
+	for i := range findings {
+		respList = append(respList, &findings[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
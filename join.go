@@ -0,0 +1,148 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keys identifies a row by one or more columns, for models with composite
+// primary keys such as many-to-many link rows.
+type Keys map[string]interface{}
+
+// JoinRepository manages models without a single ID column.
+type JoinRepository[M any] interface {
+	FindOneByKeys(ctx context.Context, keys Keys) (M, error)
+	FindManyByKeys(ctx context.Context, keys Keys) ([]M, error)
+	CreateOne(ctx context.Context, item M) error
+	DeleteByKeys(ctx context.Context, keys Keys) error
+}
+
+type joinRepository[M any] struct {
+	db     DBService
+	logger LoggerService
+
+	tableName string
+}
+
+type JoinRepositoryOption[M any] func(*joinRepository[M])
+
+func NewJoinRepository[M any](
+	db DBService,
+	logger LoggerService,
+	opts ...JoinRepositoryOption[M],
+) JoinRepository[M] {
+	repo := &joinRepository[M]{
+		db:     db,
+		logger: logger,
+	}
+
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	return repo
+}
+
+func (r *joinRepository[M]) FindOneByKeys(ctx context.Context, keys Keys) (M, error) {
+	var item M
+
+	query, args, err := r.keysQuery(keys)
+	if err != nil {
+		return item, err
+	}
+
+	err = r.db.FindOne(ctx, &item, nil, nil, query, args...)
+	if err != nil {
+		return item, fmt.Errorf("failed to find one item by keys: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Found one item by keys", "keys", keys, "table", r.tableName)
+
+	return item, nil
+}
+
+func (r *joinRepository[M]) FindManyByKeys(ctx context.Context, keys Keys) ([]M, error) {
+	var items []M
+
+	query, args, err := r.keysQuery(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.FindMany(ctx, &items, nil, nil, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find many items by keys: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Found many items by keys", "keys", keys, "table", r.tableName, "count", len(items))
+
+	return items, nil
+}
+
+func (r *joinRepository[M]) CreateOne(ctx context.Context, item M) error {
+	err := r.db.CreateOne(ctx, item)
+	if err != nil {
+		return fmt.Errorf("failed to create one item: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Created one join item", "table", r.tableName)
+
+	return nil
+}
+
+func (r *joinRepository[M]) DeleteByKeys(ctx context.Context, keys Keys) error {
+	query, args, err := r.keysQuery(keys)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.DeleteMany(ctx, new(M), query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete item by keys: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Deleted item by keys", "keys", keys, "table", r.tableName)
+
+	return nil
+}
+
+// keysQuery builds an AND of equality conditions in a stable column order.
+func (r *joinRepository[M]) keysQuery(keys Keys) (string, []interface{}, error) {
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("at least one key is required")
+	}
+
+	columns := make([]string, 0, len(keys))
+	for column := range keys {
+		if !isValidColumnName(column) {
+			return "", nil, fmt.Errorf("invalid key column %q", column)
+		}
+
+		columns = append(columns, column)
+	}
+
+	sort.Strings(columns)
+
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+
+	for _, column := range columns {
+		if r.tableName != "" {
+			conditions = append(conditions, fmt.Sprintf("%s.%s = ?", r.tableName, column))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("%s = ?", column))
+		}
+
+		args = append(args, keys[column])
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+func WithJoinTableName[M any](tableName string) JoinRepositoryOption[M] {
+	return func(r *joinRepository[M]) {
+		r.tableName = tableName
+	}
+}
@@ -0,0 +1,674 @@
+package mochi
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/go-chi/render"
+)
+
+// JSONNaming picks how struct fields without a json tag are named in
+// request and response bodies. Fields with a tagged name keep it.
+type JSONNaming int32
+
+const (
+	// JSONNamingDefault keeps encoding/json behavior: Go field names.
+	JSONNamingDefault JSONNaming = iota
+	// JSONNamingSnakeCase names UserID user_id.
+	JSONNamingSnakeCase
+	// JSONNamingCamelCase names UserID userId.
+	JSONNamingCamelCase
+)
+
+var (
+	jsonNaming     atomic.Int32
+	jsonNamingOnce sync.Once
+
+	// jsonFieldCache maps struct types and namings to their encoded fields.
+	jsonFieldCache sync.Map
+
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SetJSONNaming sets the naming of untagged fields for every DTO rendered
+// and request bound through go-chi/render. Call it once at startup, before
+// serving requests.
+func SetJSONNaming(naming JSONNaming) {
+	jsonNaming.Store(int32(naming))
+
+	jsonNamingOnce.Do(func() {
+		render.Respond = namingResponder
+		render.Decode = namingDecoder
+	})
+}
+
+func currentJSONNaming() JSONNaming {
+	return JSONNaming(jsonNaming.Load())
+}
+
+// name converts a Go field name.
+func (n JSONNaming) name(field string) string {
+	if n == JSONNamingDefault {
+		return field
+	}
+
+	words := splitFieldName(field)
+
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if n == JSONNamingCamelCase && i > 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+
+		words[i] = word
+	}
+
+	if n == JSONNamingSnakeCase {
+		return strings.Join(words, "_")
+	}
+
+	return strings.Join(words, "")
+}
+
+// splitFieldName splits a Go identifier into words, keeping acronyms
+// together: HTTPStatusCode is HTTP, Status, Code.
+func splitFieldName(name string) []string {
+	runes := []rune(name)
+	words := []string{}
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+
+			start = i + 1
+
+			continue
+		}
+
+		if i == start {
+			continue
+		}
+
+		prev := runes[i-1]
+		lowerToUpper := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(runes[i])
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(runes[i]) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+		if lowerToUpper || acronymEnd {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+type jsonField struct {
+	index     []int
+	name      string
+	bindName  string
+	omitEmpty bool
+	quoted    bool
+}
+
+// jsonFields lists the encoded fields of a struct type like encoding/json,
+// promoting the fields of embedded structs unless an outer field has the
+// same name.
+func jsonFields(typ reflect.Type, naming JSONNaming) []jsonField {
+	type cacheKey struct {
+		typ    reflect.Type
+		naming JSONNaming
+	}
+
+	if cached, ok := jsonFieldCache.Load(cacheKey{typ, naming}); ok {
+		return cached.([]jsonField)
+	}
+
+	type candidate struct {
+		field jsonField
+		depth int
+	}
+
+	candidates := []candidate{}
+
+	var collect func(typ reflect.Type, index []int, depth int)
+	collect = func(typ reflect.Type, index []int, depth int) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+
+			tagName, opts, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int{}, index...), i)
+
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			if field.Anonymous && tagName == "" && fieldType.Kind() == reflect.Struct {
+				if !field.IsExported() && field.Type.Kind() == reflect.Ptr {
+					continue
+				}
+
+				collect(fieldType, fieldIndex, depth+1)
+
+				continue
+			}
+
+			if !field.IsExported() {
+				continue
+			}
+
+			name, bindName := tagName, tagName
+			if name == "" {
+				name, bindName = naming.name(field.Name), field.Name
+			}
+
+			candidates = append(candidates, candidate{
+				field: jsonField{
+					index:     fieldIndex,
+					name:      name,
+					bindName:  bindName,
+					omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+					quoted:    strings.Contains(","+opts+",", ",string,"),
+				},
+				depth: depth,
+			})
+		}
+	}
+
+	collect(typ, nil, 0)
+
+	shallowest := make(map[string]int)
+	for _, c := range candidates {
+		if depth, ok := shallowest[c.field.name]; !ok || c.depth < depth {
+			shallowest[c.field.name] = c.depth
+		}
+	}
+
+	fields := []jsonField{}
+	seen := make(map[string]bool)
+
+	for _, c := range candidates {
+		if c.depth != shallowest[c.field.name] || seen[c.field.name] {
+			continue
+		}
+
+		seen[c.field.name] = true
+		fields = append(fields, c.field)
+	}
+
+	jsonFieldCache.Store(cacheKey{typ, naming}, fields)
+
+	return fields
+}
+
+// marshalNamedJSON encodes v like encoding/json, naming untagged fields
+// with naming. Types with their own MarshalJSON or MarshalText keep their
+// format.
+func marshalNamedJSON(v interface{}, naming JSONNaming) ([]byte, error) {
+	if naming == JSONNamingDefault {
+		return json.Marshal(v)
+	}
+
+	encoder := &namedEncoder{naming: naming, seen: make(map[namedEncoderRef]struct{})}
+
+	buf := &bytes.Buffer{}
+	if err := encoder.encode(buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// namedEncoder tracks the pointers, maps, and slices being encoded, to fail
+// on cycles like encoding/json instead of overflowing the stack.
+type namedEncoder struct {
+	naming JSONNaming
+	seen   map[namedEncoderRef]struct{}
+}
+
+type namedEncoderRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter marks v as being encoded until the returned func is called.
+func (e *namedEncoder) enter(v reflect.Value) (func(), error) {
+	ref := namedEncoderRef{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+
+	if _, ok := e.seen[ref]; ok {
+		return nil, fmt.Errorf("json: unsupported value: encountered a cycle via %s", v.Type())
+	}
+
+	e.seen[ref] = struct{}{}
+
+	return func() { delete(e.seen, ref) }, nil
+}
+
+func (e *namedEncoder) encode(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+
+	if v.CanInterface() && hasCustomMarshaler(v) {
+		encoded, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+
+		buf.Write(encoded)
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+
+		return e.encode(buf, v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+
+		leave, err := e.enter(v)
+		if err != nil {
+			return err
+		}
+		defer leave()
+
+		return e.encode(buf, v.Elem())
+	case reflect.Struct:
+		return e.encodeStruct(buf, v)
+	case reflect.Map:
+		return e.encodeMap(buf, v)
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return writeJSON(buf, v.Bytes())
+		}
+
+		leave, err := e.enter(v)
+		if err != nil {
+			return err
+		}
+		defer leave()
+
+		return e.encodeArray(buf, v)
+	case reflect.Array:
+		return e.encodeArray(buf, v)
+	case reflect.Bool:
+		return writeJSON(buf, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+		return nil
+	case reflect.Float32:
+		return writeJSON(buf, float32(v.Float()))
+	case reflect.Float64:
+		return writeJSON(buf, v.Float())
+	case reflect.String:
+		return writeJSON(buf, v.String())
+	default:
+		return fmt.Errorf("json: unsupported type %s", v.Type())
+	}
+}
+
+func (e *namedEncoder) encodeStruct(buf *bytes.Buffer, v reflect.Value) error {
+	buf.WriteByte('{')
+
+	first := true
+
+	for _, field := range jsonFields(v.Type(), e.naming) {
+		fieldValue, ok := fieldByIndex(v, field.index)
+		if !ok || (field.omitEmpty && isEmptyJSONValue(fieldValue)) {
+			continue
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+
+		first = false
+
+		if err := writeJSON(buf, field.name); err != nil {
+			return err
+		}
+
+		buf.WriteByte(':')
+
+		if !field.quoted || !isQuotableKind(fieldValue.Kind()) {
+			if err := e.encode(buf, fieldValue); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		inner := &bytes.Buffer{}
+		if err := e.encode(inner, fieldValue); err != nil {
+			return err
+		}
+
+		if err := writeJSON(buf, inner.String()); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte('}')
+
+	return nil
+}
+
+func (e *namedEncoder) encodeArray(buf *bytes.Buffer, v reflect.Value) error {
+	buf.WriteByte('[')
+
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := e.encode(buf, v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte(']')
+
+	return nil
+}
+
+func (e *namedEncoder) encodeMap(buf *bytes.Buffer, v reflect.Value) error {
+	if v.IsNil() {
+		buf.WriteString("null")
+		return nil
+	}
+
+	leave, err := e.enter(v)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	type entry struct {
+		name  string
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := namedMapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry{name: name, value: iter.Value()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	buf.WriteByte('{')
+
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := writeJSON(buf, entry.name); err != nil {
+			return err
+		}
+
+		buf.WriteByte(':')
+
+		if err := e.encode(buf, entry.value); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte('}')
+
+	return nil
+}
+
+// namedMapKey formats a map key as encoding/json does: strings as is, then
+// MarshalText, then integers.
+func namedMapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+
+	if key.Type().Implements(textMarshalerType) && key.CanInterface() {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return "", nil
+		}
+
+		text, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", err
+		}
+
+		return string(text), nil
+	}
+
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("json: unsupported map key type %s", key.Type())
+	}
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(encoded)
+
+	return nil
+}
+
+func hasCustomMarshaler(v reflect.Value) bool {
+	typ := v.Type()
+	if typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType) {
+		return true
+	}
+
+	ptr := reflect.PointerTo(typ)
+
+	return v.CanAddr() && (ptr.Implements(jsonMarshalerType) || ptr.Implements(textMarshalerType))
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, reporting false for fields
+// promoted through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, fieldIndex := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(fieldIndex)
+	}
+
+	return v, true
+}
+
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+
+	return false
+}
+
+func isQuotableKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// renameForBinding rewrites the object keys of decoded JSON from the
+// naming of typ's untagged fields back to the Go field names that
+// encoding/json matches.
+func renameForBinding(data interface{}, typ reflect.Type, naming JSONNaming) interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	ptr := reflect.PointerTo(typ)
+	if ptr.Implements(jsonUnmarshalerType) || ptr.Implements(textUnmarshalerType) {
+		return data
+	}
+
+	switch value := data.(type) {
+	case map[string]interface{}:
+		switch typ.Kind() {
+		case reflect.Struct:
+			renamed := make(map[string]interface{}, len(value))
+			fields := jsonFields(typ, naming)
+
+			for key, item := range value {
+				renamed[key] = item
+
+				for _, field := range fields {
+					if field.name == key {
+						delete(renamed, key)
+						renamed[field.bindName] = renameForBinding(item, typ.FieldByIndex(field.index).Type, naming)
+
+						break
+					}
+				}
+			}
+
+			return renamed
+		case reflect.Map:
+			for key, item := range value {
+				value[key] = renameForBinding(item, typ.Elem(), naming)
+			}
+		}
+	case []interface{}:
+		if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			for i, item := range value {
+				value[i] = renameForBinding(item, typ.Elem(), naming)
+			}
+		}
+	}
+
+	return data
+}
+
+// namingResponder renders JSON responses with the configured naming and
+// leaves other formats to render.DefaultResponder.
+func namingResponder(w http.ResponseWriter, r *http.Request, v interface{}) {
+	naming := currentJSONNaming()
+	if naming == JSONNamingDefault || render.GetAcceptedContentType(r) == render.ContentTypeXML ||
+		render.GetAcceptedContentType(r) == render.ContentTypeEventStream {
+		render.DefaultResponder(w, r, v)
+		return
+	}
+
+	encoded, err := marshalNamedJSON(v, naming)
+	if err != nil {
+		ReportError(r, fmt.Errorf("failed to encode response: %w", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	w.Write(append(encoded, '\n'))
+}
+
+// namingDecoder binds JSON request bodies named with the configured
+// naming.
+func namingDecoder(r *http.Request, v interface{}) error {
+	naming := currentJSONNaming()
+	if naming == JSONNamingDefault || render.GetRequestContentType(r) != render.ContentTypeJSON {
+		return render.DefaultDecoder(r, v)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(io.Discard, r.Body); err != nil {
+		return err
+	}
+
+	renamed, err := json.Marshal(renameForBinding(data, reflect.TypeOf(v), naming))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(renamed, v)
+}
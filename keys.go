@@ -0,0 +1,380 @@
+package mochi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/render"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	JWKSPath = "/.well-known/jwks.json"
+
+	// MinHMACSecretLength is the shortest HMAC secret accepted, matching the
+	// 256-bit output of HS256.
+	MinHMACSecretLength = 32
+	MinRSAKeyBits       = 2048
+)
+
+// SigningKey is a key used to sign and verify tokens. HMAC keys carry the
+// shared secret in Secret; asymmetric keys carry Private and Public.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	Secret  []byte
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+func NewHMACSigningKey(id string, secret []byte) SigningKey {
+	return SigningKey{ID: id, Method: jwt.SigningMethodHS256, Secret: secret}
+}
+
+// ParsePrivateKeyPEM loads an RSA, ECDSA, or Ed25519 private key and picks
+// the matching signing method (RS256, ES256/384/512, or EdDSA).
+func ParsePrivateKeyPEM(id string, pemBytes []byte) (SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return SigningKey{}, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+
+	key := SigningKey{ID: id, Private: signer, Public: signer.Public()}
+
+	switch k := signer.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return SigningKey{}, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported private key type %T", signer)
+	}
+
+	return key, nil
+}
+
+// Validate rejects keys that would produce forgeable tokens, such as empty
+// or short HMAC secrets and small RSA keys.
+func (k SigningKey) Validate() error {
+	if k.Method == nil {
+		return fmt.Errorf("signing key %q has no signing method", k.ID)
+	}
+
+	if k.Secret != nil || k.Private == nil {
+		if len(k.Secret) < MinHMACSecretLength {
+			return fmt.Errorf("signing key %q secret must be at least %d bytes, got %d", k.ID, MinHMACSecretLength, len(k.Secret))
+		}
+
+		return nil
+	}
+
+	if rsaKey, ok := k.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < MinRSAKeyBits {
+		return fmt.Errorf("signing key %q RSA modulus must be at least %d bits", k.ID, MinRSAKeyBits)
+	}
+
+	return nil
+}
+
+func (k SigningKey) signingKey() interface{} {
+	if k.Secret != nil {
+		return k.Secret
+	}
+
+	return k.Private
+}
+
+func (k SigningKey) verificationKey() interface{} {
+	if k.Secret != nil {
+		return k.Secret
+	}
+
+	return k.Public
+}
+
+// KeySet holds the active signing key plus older keys that are still
+// accepted for verification, so keys can be rotated without invalidating
+// tokens already issued.
+type KeySet struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string]SigningKey
+}
+
+func NewKeySet(active SigningKey, previous ...SigningKey) *KeySet {
+	ks := &KeySet{keys: make(map[string]SigningKey)}
+
+	for _, key := range previous {
+		ks.keys[key.ID] = key
+	}
+
+	ks.Rotate(active)
+
+	return ks
+}
+
+// Rotate makes key the active signing key. The previous key stays available
+// for verification until it is retired.
+func (ks *KeySet) Rotate(key SigningKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys[key.ID] = key
+	ks.active = key.ID
+}
+
+// Retire stops accepting tokens signed with the given key.
+func (ks *KeySet) Retire(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if id == ks.active {
+		return fmt.Errorf("cannot retire the active key %q", id)
+	}
+
+	delete(ks.keys, id)
+
+	return nil
+}
+
+func (ks *KeySet) Active() SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.keys[ks.active]
+}
+
+func (ks *KeySet) Lookup(id string) (SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[id]
+
+	return key, ok
+}
+
+func (ks *KeySet) Methods() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	methods := []string{}
+
+	for _, key := range ks.keys {
+		if name := key.Method.Alg(); !seen[name] {
+			seen[name] = true
+			methods = append(methods, name)
+		}
+	}
+
+	return methods
+}
+
+// Keyfunc resolves the verification key for a token from its kid header,
+// falling back to the active key for tokens issued without one.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.Active(), true
+	if kid != "" {
+		key, ok = ks.Lookup(kid)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	return key.verificationKey(), nil
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := ks.Active()
+
+	if err := key.Validate(); err != nil {
+		return "", fmt.Errorf("refusing to sign with weak key: %w", err)
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	return token.SignedString(key.signingKey())
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (j *JWKS) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// JWKS returns the public keys in the set. HMAC keys are never published.
+func (ks *KeySet) JWKS() *JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	jwks := &JWKS{Keys: []JWK{}}
+
+	for _, key := range ks.keys {
+		jwk, ok := publicJWK(key)
+		if ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+
+	return jwks
+}
+
+// JWKSHandler serves the key set, typically mounted at JWKSPath.
+func (ks *KeySet) JWKSHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		render.Render(w, r, ks.JWKS())
+	}
+}
+
+// PublicKey decodes the JWK into an RSA, ECDSA, or Ed25519 public key.
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+
+	switch j.Kty {
+	case "RSA":
+		n, err := b64(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+
+		e, err := b64(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+
+		y, err := b64(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OKP key: %w", err)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
+
+func publicJWK(key SigningKey) (JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
@@ -0,0 +1,192 @@
+package mochi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"go.uber.org/fx"
+)
+
+// APIGatewayV2Request is the API Gateway HTTP API (payload format 2.0)
+// event. It matches events.APIGatewayV2HTTPRequest from aws-lambda-go, so
+// the adapter can be passed straight to lambda.Start.
+type APIGatewayV2Request struct {
+	RawPath         string            `json:"rawPath"`
+	RawQueryString  string            `json:"rawQueryString"`
+	Cookies         []string          `json:"cookies,omitempty"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body,omitempty"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	RequestContext  struct {
+		HTTP struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+type APIGatewayV2Response struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Cookies         []string          `json:"cookies,omitempty"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// APIGatewayProxyRequest is the API Gateway REST API proxy (payload format
+// 1.0) event, matching events.APIGatewayProxyRequest.
+type APIGatewayProxyRequest struct {
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+	RequestContext                  struct {
+		Identity struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+}
+
+type APIGatewayProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// LambdaAdapter serves API Gateway events with the mochi router. The app is
+// built once per execution environment with the same fx wiring as a server,
+// minus the listener, and reused across invocations.
+type LambdaAdapter struct {
+	handler http.Handler
+	stop    func(context.Context) error
+}
+
+func NewLambdaAdapter(ctx context.Context, opts ...fx.Option) (*LambdaAdapter, error) {
+	handler, stop, err := Handler(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LambdaAdapter{handler: handler, stop: stop}, nil
+}
+
+// Stop shuts the app down, for runtimes that signal termination.
+func (a *LambdaAdapter) Stop(ctx context.Context) error {
+	return a.stop(ctx)
+}
+
+// HandleHTTPAPI serves an HTTP API (payload format 2.0) event.
+func (a *LambdaAdapter) HandleHTTPAPI(ctx context.Context, event APIGatewayV2Request) (APIGatewayV2Response, error) {
+	req, err := newLambdaRequest(ctx, event.RequestContext.HTTP.Method, event.RawPath, event.RawQueryString, event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return APIGatewayV2Response{}, err
+	}
+
+	for name, value := range event.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if len(event.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+
+	req.RemoteAddr = event.RequestContext.HTTP.SourceIP
+
+	rec := a.serve(req)
+
+	resp := APIGatewayV2Response{StatusCode: rec.Code, Headers: make(map[string]string), Cookies: rec.Header().Values("Set-Cookie")}
+	resp.Body, resp.IsBase64Encoded = encodeLambdaBody(rec)
+
+	for name, values := range rec.Header() {
+		if name != "Set-Cookie" {
+			resp.Headers[name] = strings.Join(values, ",")
+		}
+	}
+
+	return resp, nil
+}
+
+// HandleRESTAPI serves a REST API proxy (payload format 1.0) event.
+func (a *LambdaAdapter) HandleRESTAPI(ctx context.Context, event APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	query := url.Values(event.MultiValueQueryStringParameters).Encode()
+
+	req, err := newLambdaRequest(ctx, event.HTTPMethod, event.Path, query, event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	for name, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	req.RemoteAddr = event.RequestContext.Identity.SourceIP
+
+	rec := a.serve(req)
+
+	resp := APIGatewayProxyResponse{StatusCode: rec.Code, MultiValueHeaders: rec.Header()}
+	resp.Body, resp.IsBase64Encoded = encodeLambdaBody(rec)
+
+	return resp, nil
+}
+
+func (a *LambdaAdapter) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func newLambdaRequest(ctx context.Context, method, path, rawQuery, body string, isBase64 bool) (*http.Request, error) {
+	payload := []byte(body)
+
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode request body: %w", err)
+		}
+
+		payload = decoded
+	}
+
+	target := path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.RequestURI = target
+
+	return req, nil
+}
+
+// encodeLambdaBody base64 encodes bodies that aren't text so binary
+// responses survive API Gateway.
+func encodeLambdaBody(rec *httptest.ResponseRecorder) (string, bool) {
+	contentType := rec.Header().Get("Content-Type")
+
+	isText := contentType == "" ||
+		strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml")
+
+	if isText {
+		return rec.Body.String(), false
+	}
+
+	return base64.StdEncoding.EncodeToString(rec.Body.Bytes()), true
+}
@@ -0,0 +1,126 @@
+package mochi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+// maxPooledBufferSize keeps unusually large responses from pinning memory
+// in the buffer pool.
+const maxPooledBufferSize = 8 << 20
+
+var (
+	jsonBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+	rendererType = reflect.TypeOf((*render.Renderer)(nil)).Elem()
+
+	// rendererFieldCache maps struct types to the indexes of their fields
+	// implementing render.Renderer.
+	rendererFieldCache sync.Map
+)
+
+// renderList behaves like render.RenderList but looks up nested renderer
+// fields once per type and encodes JSON through a pooled buffer. XML
+// responses still go through render.Respond.
+func renderList(w http.ResponseWriter, r *http.Request, list []render.Renderer) error {
+	for _, v := range list {
+		if err := renderHooks(w, r, v); err != nil {
+			return err
+		}
+	}
+
+	if render.GetAcceptedContentType(r) == render.ContentTypeXML {
+		render.Respond(w, r, list)
+		return nil
+	}
+
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			jsonBufferPool.Put(buf)
+		}
+	}()
+
+	if naming := currentJSONNaming(); naming != JSONNamingDefault {
+		encoded, err := marshalNamedJSON(list, naming)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(append(encoded, '\n'))
+	} else {
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(true)
+
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// renderHooks calls Render on v and then on its non-nil renderer fields,
+// top-down like go-chi/render.
+func renderHooks(w http.ResponseWriter, r *http.Request, v render.Renderer) error {
+	if err := v.Render(w, r); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, i := range rendererFields(rv.Type()) {
+		field := rv.Field(i)
+
+		switch field.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			if field.IsNil() {
+				continue
+			}
+		}
+
+		if err := renderHooks(w, r, field.Interface().(render.Renderer)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func rendererFields(typ reflect.Type) []int {
+	if cached, ok := rendererFieldCache.Load(typ); ok {
+		return cached.([]int)
+	}
+
+	fields := []int{}
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.IsExported() && field.Type.Implements(rendererType) {
+			fields = append(fields, i)
+		}
+	}
+
+	rendererFieldCache.Store(typ, fields)
+
+	return fields
+}
@@ -0,0 +1,77 @@
+package mochi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/render"
+)
+
+type benchTask struct {
+	ID    uint
+	Title string
+	Done  bool
+}
+
+type benchTaskDTO struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+}
+
+func (dto *benchTaskDTO) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (t *benchTask) ToDTO() render.Renderer {
+	return &benchTaskDTO{ID: t.ID, Title: t.Title, Done: t.Done}
+}
+
+// discardResponseWriter keeps the recorder's body growth out of the
+// measurements.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) WriteHeader(status int)      {}
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func BenchmarkListResponse10k(b *testing.B) {
+	items := make([]*benchTask, 10_000)
+	for i := range items {
+		items[i] = &benchTask{ID: uint(i), Title: "Write the quarterly report"}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	w := &discardResponseWriter{header: make(http.Header)}
+
+	b.Run("append+RenderList", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			respList := []render.Renderer{}
+			for _, item := range items {
+				respList = append(respList, item.ToDTO())
+			}
+
+			render.RenderList(w, req, respList)
+		}
+	})
+
+	b.Run("presized+renderList", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			respList := make([]render.Renderer, len(items))
+			for i, item := range items {
+				respList[i] = item.ToDTO()
+			}
+
+			if err := renderList(w, req, respList); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
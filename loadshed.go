@@ -0,0 +1,154 @@
+package mochi
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	DefaultRetryAfter = time.Second
+)
+
+type RoutePriority int
+
+const (
+	PriorityNormal RoutePriority = iota
+	PriorityHigh
+)
+
+// RouteClassifier decides which lane a request uses when load shedding.
+type RouteClassifier func(r *http.Request) RoutePriority
+
+// PrefixRouteClassifier marks requests whose path starts with any of the
+// prefixes as high priority.
+func PrefixRouteClassifier(prefixes ...string) RouteClassifier {
+	return func(r *http.Request) RoutePriority {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return PriorityHigh
+			}
+		}
+
+		return PriorityNormal
+	}
+}
+
+// ConcurrencyLimiter caps the number of in-flight requests passing through
+// its middleware and sheds the excess with a 503 and a Retry-After header.
+// Use one limiter per route or controller, or register a global one with
+// NewLoadShedMiddleware.
+type ConcurrencyLimiter struct {
+	logger     LoggerService
+	retryAfter time.Duration
+	slots      chan struct{}
+
+	classifier    RouteClassifier
+	prioritySlots chan struct{}
+}
+
+func NewConcurrencyLimiter(logger LoggerService, maxInFlight int, retryAfter time.Duration) *ConcurrencyLimiter {
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+
+	return &ConcurrencyLimiter{
+		logger:     logger,
+		retryAfter: retryAfter,
+		slots:      make(chan struct{}, maxInFlight),
+	}
+}
+
+// WithPriorityLane reserves extra slots for requests the classifier marks as
+// high priority, so health checks and admin traffic are served while normal
+// traffic is being shed.
+func (l *ConcurrencyLimiter) WithPriorityLane(classifier RouteClassifier, reserved int) *ConcurrencyLimiter {
+	l.classifier = classifier
+	l.prioritySlots = make(chan struct{}, reserved)
+
+	return l
+}
+
+func (l *ConcurrencyLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() { <-l.slots }()
+			next.ServeHTTP(w, r)
+
+			return
+		default:
+		}
+
+		if l.classifier != nil && l.classifier(r) == PriorityHigh {
+			select {
+			case l.prioritySlots <- struct{}{}:
+				defer func() { <-l.prioritySlots }()
+				next.ServeHTTP(w, r)
+
+				return
+			default:
+			}
+		}
+
+		l.shed(w, r)
+	})
+}
+
+// InFlight returns the number of requests currently holding a slot.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots) + len(l.prioritySlots)
+}
+
+func (l *ConcurrencyLimiter) shed(w http.ResponseWriter, r *http.Request) {
+	l.logger.WithContext(r.Context()).Warn("Shedding request", "method", r.Method, "path", r.URL.Path, "in_flight", l.InFlight())
+
+	retryAfterSecs := int(math.Ceil(l.retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSecs))
+
+	render.Render(w, r, ErrOverloaded)
+}
+
+type LoadShedConfig struct {
+	MaxInFlight int
+	RetryAfter  time.Duration
+
+	// PriorityPrefixes are path prefixes served from the priority lane in
+	// addition to the health check. PriorityReserve defaults to a tenth of
+	// MaxInFlight.
+	PriorityPrefixes []string
+	PriorityReserve  int
+}
+
+type LoadShedMiddlewareParams struct {
+	fx.In
+
+	Config LoadShedConfig `optional:"true"`
+	Logger LoggerService
+}
+
+// NewLoadShedMiddleware builds a global concurrency limit for the root
+// router. Register it with AsRouterMiddleware; it is a no-op when
+// MaxInFlight is not configured.
+func NewLoadShedMiddleware(params LoadShedMiddlewareParams) func(http.Handler) http.Handler {
+	if params.Config.MaxInFlight <= 0 {
+		return passthroughMiddleware
+	}
+
+	limiter := NewConcurrencyLimiter(params.Logger, params.Config.MaxInFlight, params.Config.RetryAfter)
+
+	reserve := params.Config.PriorityReserve
+	if reserve <= 0 {
+		reserve = max(1, params.Config.MaxInFlight/10)
+	}
+
+	prefixes := append([]string{HealthcheckPath}, params.Config.PriorityPrefixes...)
+	limiter.WithPriorityLane(PrefixRouteClassifier(prefixes...), reserve)
+
+	return limiter.Handler
+}
@@ -1,8 +1,10 @@
 package mochi
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"sync/atomic"
 
 	"go.uber.org/fx"
 )
@@ -13,10 +15,19 @@ type LoggerService interface {
 	Warn(msg string, args ...any)
 	Error(msg string, args ...any)
 	Logger() *slog.Logger
+	// WithContext returns a logger that adds the request ID of ctx to every
+	// line, or the logger itself outside of a request.
+	WithContext(ctx context.Context) LoggerService
 }
 
+const (
+	ProductionLogSampleRate = 10
+)
+
 type LoggerServiceParams struct {
 	fx.In
+
+	Env AppEnv
 }
 
 type LoggerServiceResult struct {
@@ -30,11 +41,28 @@ type loggerService struct {
 }
 
 func NewLoggerService(params LoggerServiceParams) (LoggerServiceResult, error) {
-	handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	})
+	var handler slog.Handler
 
-	logger := slog.New(handler)
+	switch {
+	case params.Env.IsDevelopment():
+		handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})
+	case params.Env.IsProduction():
+		handler = &samplingHandler{
+			Handler: slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+				Level: slog.LevelInfo,
+			}),
+			rate:    ProductionLogSampleRate,
+			counter: &atomic.Uint64{},
+		}
+	default:
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelInfo,
+		})
+	}
+
+	logger := slog.New(handler).With("env", string(params.Env))
 
 	srv := &loggerService{logger: logger}
 	return LoggerServiceResult{LoggerService: srv}, nil
@@ -59,3 +87,37 @@ func (srv *loggerService) Error(msg string, args ...any) {
 func (srv *loggerService) Logger() *slog.Logger {
 	return srv.logger
 }
+
+func (srv *loggerService) WithContext(ctx context.Context) LoggerService {
+	requestID := RequestIDFromCtx(ctx)
+	if requestID == "" {
+		return srv
+	}
+
+	return &loggerService{logger: srv.logger.With("request_id", requestID)}
+}
+
+// samplingHandler passes every warning and error but only one in rate
+// records below warning level.
+type samplingHandler struct {
+	slog.Handler
+
+	rate    uint64
+	counter *atomic.Uint64
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < slog.LevelWarn && h.counter.Add(1)%h.rate != 0 {
+		return nil
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), rate: h.rate, counter: h.counter}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), rate: h.rate, counter: h.counter}
+}
@@ -0,0 +1,31 @@
+package mochi
+
+import (
+	"context"
+)
+
+type Email struct {
+	To       []string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// MailerService delivers transactional email.
+type MailerService interface {
+	Send(ctx context.Context, email Email) error
+}
+
+type noopMailerService struct {
+	logger LoggerService
+}
+
+func NewNoopMailerService(logger LoggerService) MailerService {
+	return &noopMailerService{logger: logger}
+}
+
+func (srv *noopMailerService) Send(ctx context.Context, email Email) error {
+	srv.logger.WithContext(ctx).Warn("Dropping email, no mailer configured", "to", email.To, "subject", email.Subject)
+
+	return nil
+}
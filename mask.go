@@ -0,0 +1,90 @@
+package mochi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MaskTag masks a string DTO field with a registered Masker, for example
+// `mask:"card"`. Masks apply to every viewer, admins included.
+const MaskTag = "mask"
+
+// Masker obfuscates a sensitive value while leaving enough to recognize it.
+type Masker func(value string) string
+
+const maskChar = "*"
+
+var (
+	maskersMu sync.RWMutex
+	maskers   = map[string]Masker{
+		"card":  MaskCard,
+		"last4": MaskLast4,
+		"email": MaskEmail,
+		"full":  MaskFull,
+	}
+)
+
+// RegisterMasker makes masker available to MaskTag under name, replacing
+// any masker already registered with it.
+func RegisterMasker(name string, masker Masker) {
+	maskersMu.Lock()
+	defer maskersMu.Unlock()
+
+	maskers[name] = masker
+}
+
+func lookupMasker(name string) (Masker, error) {
+	maskersMu.RLock()
+	defer maskersMu.RUnlock()
+
+	masker, ok := maskers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown masker %q", name)
+	}
+
+	return masker, nil
+}
+
+// MaskLast4 keeps the last four characters, "secret-token" becoming
+// "********oken".
+func MaskLast4(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat(maskChar, len(runes))
+	}
+
+	return strings.Repeat(maskChar, len(runes)-4) + string(runes[len(runes)-4:])
+}
+
+// MaskCard keeps the last four digits of a card number, dropping separators:
+// "4242 4242 4242 4242" becomes "************4242".
+func MaskCard(value string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+
+		return -1
+	}, value)
+
+	return MaskLast4(digits)
+}
+
+// MaskEmail keeps the first character of the local part and the domain,
+// "jane.doe@example.com" becoming "j*******@example.com".
+func MaskEmail(value string) string {
+	at := strings.LastIndex(value, "@")
+	if at < 1 {
+		return MaskFull(value)
+	}
+
+	local := []rune(value[:at])
+
+	return string(local[0]) + strings.Repeat(maskChar, len(local)-1) + value[at:]
+}
+
+// MaskFull hides the value entirely, keeping its length.
+func MaskFull(value string) string {
+	return strings.Repeat(maskChar, len([]rune(value)))
+}
@@ -0,0 +1,367 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	ViewRefreshTimeout       = 5 * time.Minute
+	ViewRefreshCheckInterval = 10 * time.Second
+)
+
+// MaterializedViewModel is a ViewModel stored as a materialized view. The
+// view is created once by Migrate; changing its definition requires a
+// migration that drops it. Indexes declared through IndexedModel are
+// created on the view, and a unique index is required for concurrent
+// refreshes.
+type MaterializedViewModel interface {
+	ViewModel
+	RefreshPolicy() ViewRefreshPolicy
+}
+
+// ViewRefreshPolicy controls how a materialized view is refreshed. A zero
+// Interval disables scheduled refreshes, leaving only manual ones.
+type ViewRefreshPolicy struct {
+	Interval     time.Duration
+	Concurrently bool
+}
+
+type ViewRefreshStatus struct {
+	View          string     `json:"view"`
+	Interval      string     `json:"interval,omitempty"`
+	Concurrently  bool       `json:"concurrently"`
+	LastRefreshed *time.Time `json:"last_refreshed,omitempty"`
+	LastDuration  string     `json:"last_duration,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Refreshing    bool       `json:"refreshing"`
+}
+
+func (s *ViewRefreshStatus) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// ViewRefreshService refreshes materialized views on their schedule and on
+// demand, and reports staleness through MetricsService. Staleness is
+// measured from the last refresh performed by this process.
+type ViewRefreshService interface {
+	Refresh(ctx context.Context, view string) error
+	RefreshAll(ctx context.Context) error
+	Status() []ViewRefreshStatus
+
+	GetRouter() *chi.Mux
+}
+
+type ViewRefreshServiceParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Auth      AuthService
+	DB        DBService
+	Logger    LoggerService
+	Metrics   MetricsService
+	Models    ModelList
+	Shutdown  *Shutdown `optional:"true"`
+}
+
+type ViewRefreshServiceResult struct {
+	fx.Out
+
+	ViewRefreshService ViewRefreshService
+}
+
+type materializedView struct {
+	name   string
+	policy ViewRefreshPolicy
+
+	refreshing    sync.Mutex
+	lastRefreshed time.Time
+	lastDuration  time.Duration
+	lastError     error
+}
+
+type viewRefreshService struct {
+	db      DBService
+	logger  LoggerService
+	metrics MetricsService
+	Router  *chi.Mux
+
+	mu        sync.RWMutex
+	views     map[string]*materializedView
+	startedAt time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewViewRefreshService(params ViewRefreshServiceParams) (ViewRefreshServiceResult, error) {
+	srv := &viewRefreshService{
+		db:      params.DB,
+		logger:  params.Logger,
+		metrics: params.Metrics,
+		views:   make(map[string]*materializedView),
+	}
+
+	// the database connects on start, so views are named from the schema
+	var schemaCache sync.Map
+
+	for _, model := range params.Models {
+		matView, ok := model.(MaterializedViewModel)
+		if !ok {
+			continue
+		}
+
+		modelSchema, err := schema.Parse(model, &schemaCache, schema.NamingStrategy{})
+		if err != nil {
+			return ViewRefreshServiceResult{}, fmt.Errorf("failed to parse view model %T: %w", model, err)
+		}
+
+		srv.views[modelSchema.Table] = &materializedView{
+			name:   modelSchema.Table,
+			policy: matView.RefreshPolicy(),
+		}
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Get("/", srv.handleStatus)
+	srv.Router.Post("/{view}/refresh", srv.handleRefresh)
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			srv.start()
+			return nil
+		},
+	})
+
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "view refresh",
+		Stage: StageWorkers,
+		Stop:  srv.shutdown,
+	})
+
+	return ViewRefreshServiceResult{ViewRefreshService: srv}, nil
+}
+
+// Refresh refreshes the named materialized view. Refreshes of the same view
+// never overlap; a refresh already in progress is waited on and repeated.
+func (srv *viewRefreshService) Refresh(ctx context.Context, name string) error {
+	srv.mu.RLock()
+	view, ok := srv.views[name]
+	srv.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: materialized view %q", ErrRecordNotFound, name)
+	}
+
+	return srv.refresh(ctx, view)
+}
+
+func (srv *viewRefreshService) RefreshAll(ctx context.Context) error {
+	srv.mu.RLock()
+	views := make([]*materializedView, 0, len(srv.views))
+	for _, view := range srv.views {
+		views = append(views, view)
+	}
+	srv.mu.RUnlock()
+
+	for _, view := range views {
+		if err := srv.refresh(ctx, view); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (srv *viewRefreshService) Status() []ViewRefreshStatus {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	statuses := make([]ViewRefreshStatus, 0, len(srv.views))
+
+	for _, view := range srv.views {
+		status := ViewRefreshStatus{
+			View:         view.name,
+			Concurrently: view.policy.Concurrently,
+		}
+
+		if view.policy.Interval > 0 {
+			status.Interval = view.policy.Interval.String()
+		}
+
+		if !view.lastRefreshed.IsZero() {
+			lastRefreshed := view.lastRefreshed
+			status.LastRefreshed = &lastRefreshed
+			status.LastDuration = view.lastDuration.String()
+		}
+
+		if view.lastError != nil {
+			status.LastError = view.lastError.Error()
+		}
+
+		if view.refreshing.TryLock() {
+			view.refreshing.Unlock()
+		} else {
+			status.Refreshing = true
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+func (srv *viewRefreshService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *viewRefreshService) refresh(ctx context.Context, view *materializedView) error {
+	if srv.db.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	// plain views stand in for materialized views elsewhere and are always
+	// current
+	if !srv.db.Dialect().SupportsMaterializedViews() {
+		return nil
+	}
+
+	view.refreshing.Lock()
+	defer view.refreshing.Unlock()
+
+	statement := fmt.Sprintf("REFRESH MATERIALIZED VIEW %s", view.name)
+	if view.policy.Concurrently {
+		statement = fmt.Sprintf("REFRESH MATERIALIZED VIEW CONCURRENTLY %s", view.name)
+	}
+
+	sesh, cancel := srv.db.GetSession(WithQueryTimeout(ctx, ViewRefreshTimeout))
+	defer cancel()
+
+	started := time.Now()
+	err := sesh.Exec(statement).Error
+	duration := time.Since(started)
+
+	labels := MetricLabels{"view": view.name}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	view.lastError = err
+
+	if err != nil {
+		srv.logger.Error("Failed to refresh materialized view", "view", view.name, "error", err)
+		srv.metrics.IncCounter("mochi_view_refresh_failures_total", 1, labels)
+
+		return fmt.Errorf("failed to refresh view %s: %w", view.name, err)
+	}
+
+	view.lastRefreshed = started
+	view.lastDuration = duration
+
+	srv.logger.Info("Refreshed materialized view", "view", view.name, "duration", duration)
+	srv.metrics.ObserveHistogram("mochi_view_refresh_duration_seconds", duration.Seconds(), labels)
+	srv.metrics.SetGauge("mochi_view_staleness_seconds", 0, labels)
+
+	return nil
+}
+
+func (srv *viewRefreshService) start() {
+	srv.startedAt = time.Now()
+	srv.stop = make(chan struct{})
+	srv.done = make(chan struct{})
+
+	go srv.run()
+}
+
+func (srv *viewRefreshService) shutdown(ctx context.Context) error {
+	close(srv.stop)
+
+	select {
+	case <-srv.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run refreshes views whose interval has elapsed and publishes staleness
+// gauges until the service stops.
+func (srv *viewRefreshService) run() {
+	defer close(srv.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-srv.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(ViewRefreshCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-srv.stop:
+			return
+		case now := <-ticker.C:
+			for _, view := range srv.dueViews(now) {
+				srv.refresh(ctx, view)
+			}
+		}
+	}
+}
+
+func (srv *viewRefreshService) dueViews(now time.Time) []*materializedView {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	due := []*materializedView{}
+
+	for _, view := range srv.views {
+		since := srv.startedAt
+		if !view.lastRefreshed.IsZero() {
+			since = view.lastRefreshed
+		}
+
+		staleness := now.Sub(since)
+		srv.metrics.SetGauge("mochi_view_staleness_seconds", staleness.Seconds(), MetricLabels{"view": view.name})
+
+		if view.policy.Interval > 0 && staleness >= view.policy.Interval {
+			due = append(due, view)
+		}
+	}
+
+	return due
+}
+
+func (srv *viewRefreshService) handleStatus(w http.ResponseWriter, r *http.Request) {
+	statuses := srv.Status()
+
+	respList := make([]render.Renderer, 0, len(statuses))
+	for i := range statuses {
+		respList = append(respList, &statuses[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
+
+func (srv *viewRefreshService) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if err := srv.Refresh(r.Context(), chi.URLParam(r, "view")); err != nil {
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	render.NoContent(w, r)
+}
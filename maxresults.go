@@ -0,0 +1,62 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// DefaultMaxResults caps list queries that don't set their own limit.
+	DefaultMaxResults = 10_000
+
+	TruncatedHeader = "X-Results-Truncated"
+)
+
+var ErrTooManyResults = errors.New("too many results")
+
+// ResultInfo reports what happened to a list query, such as the result
+// being cut off at the repository's max results.
+type ResultInfo struct {
+	Truncated bool
+	Limit     int
+}
+
+type resultContextKey int
+
+const (
+	resultLimitKey resultContextKey = iota
+	resultInfoKey
+)
+
+// WithResultInfo returns a context whose list queries record into info.
+func WithResultInfo(ctx context.Context) (context.Context, *ResultInfo) {
+	info := &ResultInfo{}
+	return context.WithValue(ctx, resultInfoKey, info), info
+}
+
+func ResultInfoFromCtx(ctx context.Context) (*ResultInfo, bool) {
+	info, ok := ctx.Value(resultInfoKey).(*ResultInfo)
+	return info, ok
+}
+
+func withResultLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, resultLimitKey, limit)
+}
+
+func resultLimitFromCtx(ctx context.Context) int {
+	limit, _ := ctx.Value(resultLimitKey).(int)
+	return limit
+}
+
+// setTruncationHeaders tells clients the list was cut off, with a Warning
+// for humans and TruncatedHeader carrying the limit for code.
+func setTruncationHeaders(w http.ResponseWriter, info *ResultInfo) {
+	if !info.Truncated {
+		return
+	}
+
+	w.Header().Set(TruncatedHeader, fmt.Sprint(info.Limit))
+	w.Header().Set("Warning", fmt.Sprintf(`199 - "results truncated to %d items, narrow the query with filters"`, info.Limit))
+}
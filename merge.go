@@ -0,0 +1,187 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+const MergeHooksGroup = `group:"merge_hooks"`
+
+// MergeHook moves data the merge service can't find on its own, such as
+// shares or user settings, from one user to another. Hooks run inside the
+// merge transaction; returning an error rolls the whole merge back.
+type MergeHook func(ctx context.Context, tx *gorm.DB, fromUserID, toUserID uint) error
+
+// AsMergeHook annotates a constructor returning a MergeHook so it runs on
+// every merge.
+func AsMergeHook(constructor interface{}) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(MergeHooksGroup)))
+}
+
+// MergeResult counts the rows moved per table.
+type MergeResult struct {
+	FromUserID uint             `json:"from_user_id"`
+	ToUserID   uint             `json:"to_user_id"`
+	Resources  map[string]int64 `json:"resources"`
+}
+
+func (res *MergeResult) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type MergeRequest struct {
+	FromUserID uint `json:"from_user_id"`
+	ToUserID   uint `json:"to_user_id"`
+}
+
+func (req *MergeRequest) Bind(r *http.Request) error {
+	if req.FromUserID == 0 || req.ToUserID == 0 {
+		return fmt.Errorf("from_user_id and to_user_id are required")
+	}
+
+	return nil
+}
+
+// AccountMergeService merges one user account into another, reassigning
+// every model in the ModelList implementing OwnedModel, such as API keys,
+// and running the registered MergeHooks, all in one transaction. Removing
+// the merged account is left to the app.
+//
+// GetRouter serves the admin operation, POST / with a MergeRequest.
+type AccountMergeService interface {
+	Merge(ctx context.Context, fromUserID, toUserID uint) (*MergeResult, error)
+
+	GetRouter() *chi.Mux
+}
+
+type AccountMergeServiceParams struct {
+	fx.In
+
+	Auth   AuthService
+	DB     DBService
+	Logger LoggerService
+	Models ModelList
+	Hooks  []MergeHook `group:"merge_hooks"`
+}
+
+type AccountMergeServiceResult struct {
+	fx.Out
+
+	AccountMergeService AccountMergeService
+}
+
+type accountMergeService struct {
+	auth   AuthService
+	db     DBService
+	logger LoggerService
+	owned  []interface{}
+	hooks  []MergeHook
+	Router *chi.Mux
+}
+
+func NewAccountMergeService(params AccountMergeServiceParams) (AccountMergeServiceResult, error) {
+	srv := &accountMergeService{
+		auth:   params.Auth,
+		db:     params.DB,
+		logger: params.Logger,
+		hooks:  params.Hooks,
+	}
+
+	for _, model := range params.Models {
+		if _, ok := model.(OwnedModel); ok {
+			srv.owned = append(srv.owned, model)
+		}
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Post("/", srv.handleMerge)
+
+	return AccountMergeServiceResult{AccountMergeService: srv}, nil
+}
+
+func (srv *accountMergeService) Merge(ctx context.Context, fromUserID, toUserID uint) (*MergeResult, error) {
+	if srv.db.IsReadOnly() {
+		return nil, ErrReadOnly
+	}
+
+	if fromUserID == toUserID {
+		return nil, InvalidRequest(fmt.Errorf("cannot merge a user into itself"))
+	}
+
+	result := &MergeResult{
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Resources:  make(map[string]int64),
+	}
+
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		for _, model := range srv.owned {
+			stmt := &gorm.Statement{DB: tx}
+			if err := stmt.Parse(model); err != nil {
+				return fmt.Errorf("failed to parse %T: %w", model, err)
+			}
+
+			instance := reflect.New(stmt.Schema.ModelType).Interface()
+
+			updateResult := tx.Model(instance).Where("user_id = ?", fromUserID).Update("user_id", toUserID)
+			if updateResult.Error != nil {
+				return fmt.Errorf("failed to reassign %s: %w", stmt.Schema.Table, updateResult.Error)
+			}
+
+			result.Resources[stmt.Schema.Table] = updateResult.RowsAffected
+		}
+
+		for _, hook := range srv.hooks {
+			if err := hook(ctx, tx, fromUserID, toUserID); err != nil {
+				return fmt.Errorf("merge hook failed: %w", err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge user %d into %d: %w", fromUserID, toUserID, err)
+	}
+
+	srv.auth.InvalidateUser(fromUserID)
+	srv.auth.InvalidateUser(toUserID)
+
+	srv.logger.Info("Merged users", "from_user", fromUserID, "to_user", toUserID, "resources", result.Resources)
+
+	return result, nil
+}
+
+func (srv *accountMergeService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *accountMergeService) handleMerge(w http.ResponseWriter, r *http.Request) {
+	req := &MergeRequest{}
+	if err := render.Bind(r, req); err != nil {
+		DefaultErrorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	result, err := srv.Merge(r.Context(), req.FromUserID, req.ToUserID)
+	if err != nil {
+		srv.logger.Error("Failed to merge users", "from_user", req.FromUserID, "to_user", req.ToUserID, "error", err)
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	render.Render(w, r, result)
+}
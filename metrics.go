@@ -0,0 +1,22 @@
+package mochi
+
+type MetricLabels map[string]string
+
+// MetricsService records application metrics.
+type MetricsService interface {
+	IncCounter(name string, value float64, labels MetricLabels)
+	SetGauge(name string, value float64, labels MetricLabels)
+	ObserveHistogram(name string, value float64, labels MetricLabels)
+}
+
+type noopMetricsService struct{}
+
+func NewNoopMetricsService() MetricsService {
+	return noopMetricsService{}
+}
+
+func (noopMetricsService) IncCounter(name string, value float64, labels MetricLabels) {}
+
+func (noopMetricsService) SetGauge(name string, value float64, labels MetricLabels) {}
+
+func (noopMetricsService) ObserveHistogram(name string, value float64, labels MetricLabels) {}
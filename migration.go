@@ -0,0 +1,360 @@
+package mochi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AutoMigrateEnvName = "DB_AUTO_MIGRATE"
+
+	// migrationLockKey identifies the advisory lock that keeps instances
+	// starting together from running migrations concurrently.
+	migrationLockKey = 7_301_624_112
+)
+
+// Migration is one versioned schema change. Versions are applied in
+// ascending order, conventionally as timestamps such as 20241016120000.
+// Down is optional; migrations without it can't be rolled back.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, tx *gorm.DB) error
+	Down    func(ctx context.Context, tx *gorm.DB) error
+}
+
+// Migrations are the app's versioned migrations. When provided, DBService
+// applies pending migrations on start and only runs AutoMigrate in
+// development or when DB_AUTO_MIGRATE is true.
+type Migrations []Migration
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Migrator applies and rolls back versioned migrations.
+type Migrator interface {
+	Up(ctx context.Context) error
+	Down(ctx context.Context) error
+	Status(ctx context.Context) ([]MigrationStatus, error)
+	Plan(ctx context.Context) ([]string, error)
+}
+
+// SQLMigration builds a migration from SQL. An empty down makes the
+// migration irreversible.
+func SQLMigration(version int64, name, up, down string) Migration {
+	migration := Migration{
+		Version: version,
+		Name:    name,
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec(up).Error
+		},
+	}
+
+	if down != "" {
+		migration.Down = func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec(down).Error
+		}
+	}
+
+	return migration
+}
+
+// LoadMigrations reads SQL migrations from fsys, typically an embed.FS,
+// named <version>_<name>.up.sql with an optional matching .down.sql.
+func LoadMigrations(fsys fs.FS) (Migrations, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	migrations := Migrations{}
+
+	for _, entry := range entries {
+		base, ok := strings.CutSuffix(entry.Name(), ".up.sql")
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		versionStr, name, _ := strings.Cut(base, "_")
+
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has an invalid version: %w", entry.Name(), err)
+		}
+
+		up, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		down, err := fs.ReadFile(fsys, base+".down.sql")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read migration %s: %w", base+".down.sql", err)
+		}
+
+		migrations = append(migrations, SQLMigration(version, name, string(up), string(down)))
+	}
+
+	return migrations, nil
+}
+
+type migrator struct {
+	srv        *dbService
+	migrations Migrations
+}
+
+func newMigrator(srv *dbService, migrations Migrations) (*migrator, error) {
+	sorted := append(Migrations(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, migration := range sorted {
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up", migration.Version)
+		}
+
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migration.Version)
+		}
+	}
+
+	return &migrator{srv: srv, migrations: sorted}, nil
+}
+
+// Up applies every pending migration in order, each in its own
+// transaction.
+func (m *migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(sesh *gorm.DB) error {
+		applied, err := m.applied(sesh)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			m.srv.logger.Info("Applying migration", "version", migration.Version, "name", migration.Name)
+
+			err := sesh.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(ctx, tx); err != nil {
+					return err
+				}
+
+				return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+			})
+
+			if err != nil {
+				return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migration.
+func (m *migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(sesh *gorm.DB) error {
+		var last SchemaMigration
+
+		result := sesh.Order("version DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return fmt.Errorf("failed to load applied migrations: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no migrations to roll back")
+		}
+
+		migration, ok := m.find(last.Version)
+		if !ok {
+			return fmt.Errorf("applied migration %d is not registered", last.Version)
+		}
+
+		if migration.Down == nil {
+			return fmt.Errorf("migration %d %s is irreversible", migration.Version, migration.Name)
+		}
+
+		m.srv.logger.Warn("Rolling back migration", "version", migration.Version, "name", migration.Name)
+
+		err := sesh.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(ctx, tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+		})
+
+		if err != nil {
+			return fmt.Errorf("rollback of migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		return nil
+	})
+}
+
+// Status lists registered migrations and whether each is applied.
+func (m *migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	sesh := m.srv.db.WithContext(ctx)
+
+	if err := sesh.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := m.applied(sesh)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Plan returns the statements pending migrations would execute, each
+// preceded by a comment naming the migration. Migrations are run against a
+// dry-run session, so Go migrations must issue their changes through tx.
+func (m *migrator) Plan(ctx context.Context) ([]string, error) {
+	if len(m.migrations) == 0 {
+		return []string{}, nil
+	}
+
+	applied := map[int64]SchemaMigration{}
+
+	sesh := m.srv.db.WithContext(ctx)
+	if sesh.Migrator().HasTable(&SchemaMigration{}) {
+		var err error
+		if applied, err = m.applied(sesh); err != nil {
+			return nil, err
+		}
+	}
+
+	dryRun, recorder := m.srv.dryRunSession(ctx)
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		recorder.statements = append(recorder.statements, fmt.Sprintf("-- migration %d %s", migration.Version, migration.Name))
+
+		if err := migration.Up(ctx, dryRun); err != nil {
+			return nil, fmt.Errorf("plan of migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return recorder.statements, nil
+}
+
+func (m *migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+func (m *migrator) applied(sesh *gorm.DB) (map[int64]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := sesh.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+// locked runs fn with the schema_migrations table in place, holding an
+// advisory lock on PostgreSQL so only one instance migrates at a time.
+// Migrations are not bound by the default query timeout.
+func (m *migrator) locked(ctx context.Context, fn func(sesh *gorm.DB) error) error {
+	if err := m.srv.checkWritable(); err != nil {
+		return err
+	}
+
+	sesh := m.srv.db.WithContext(ctx)
+
+	if m.srv.Dialect() == DialectPostgres {
+		unlock, err := m.advisoryLock(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	if err := sesh.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(sesh)
+}
+
+func (m *migrator) advisoryLock(ctx context.Context) (func(), error) {
+	sqlDB, err := m.srv.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// advisory locks belong to a session, so the lock is taken and released
+	// on one pinned connection
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	return func() {
+		releaseAdvisoryLock(conn)
+	}, nil
+}
+
+func releaseAdvisoryLock(conn *sql.Conn) {
+	conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	conn.Close()
+}
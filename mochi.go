@@ -15,39 +15,89 @@ import (
 	"os"
 )
 
-func NewRouter() *chi.Mux {
+const (
+	HealthcheckPath       = "/healthcheck"
+	RouterMiddlewareGroup = `group:"router_middlewares"`
+	FormContentPathsGroup = `group:"form_content_paths"`
+)
+
+type RouterParams struct {
+	fx.In
+
+	Env           AppEnv
+	Logger        LoggerService
+	DB            DBService                         `optional:"true"`
+	Tracer        Tracer                            `optional:"true"`
+	ErrorReporter ErrorReporter                     `optional:"true"`
+	Middlewares   []func(http.Handler) http.Handler `group:"router_middlewares"`
+	FormPaths     []string                          `group:"form_content_paths"`
+}
+
+func NewRouter(params RouterParams) *chi.Mux {
 	router := chi.NewRouter()
+	router.Use(RequestID)
+
+	if params.Tracer != nil {
+		router.Use(Tracing(params.Tracer))
+	}
+
+	if params.ErrorReporter != nil {
+		router.Use(ErrorReporting(params.ErrorReporter))
+	}
+
 	router.Use(middleware.DefaultLogger)
-	router.Use(middleware.AllowContentType("application/json"))
+	router.Use(allowContentType(params.FormPaths))
 	router.Use(render.SetContentType(render.ContentTypeJSON))
+	router.Use(AppEnvMiddleware(params.Env))
+	router.Use(Recoverer(params.Logger))
+	router.Use(SecurityHeaders(params.Env))
+	router.Use(params.Middlewares...)
 
-	router.Get("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
+	router.Get(HealthcheckPath, func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("okay xD"))
 	})
 
+	if params.DB != nil {
+		router.Get(ReadinessPath, ReadinessHandler(params.DB))
+	}
+
 	return router
 }
 
-func NewServer(lc fx.Lifecycle, router *chi.Mux, logger LoggerService) *http.Server {
+type ServerParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Logger    LoggerService
+	Router    *chi.Mux
+	Shutdown  *Shutdown `optional:"true"`
+}
+
+func NewServer(params ServerParams) *http.Server {
 	portStr := os.Getenv("PORT")
 	port := fmt.Sprintf(":%s", portStr)
 
-	srv := &http.Server{Addr: port, Handler: router}
+	srv := &http.Server{Addr: port, Handler: params.Router}
 
-	lc.Append(fx.Hook{
+	params.Lifecycle.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			ln, err := net.Listen("tcp", srv.Addr)
 			if err != nil {
 				return err
 			}
 
-			logger.Info("Starting HTTP server", "port", srv.Addr)
+			params.Logger.Info("Starting HTTP server", "port", srv.Addr)
 			go srv.Serve(ln)
 
 			return nil
 		},
-		OnStop: func(ctx context.Context) error {
-			logger.Info("Shutting down HTTP server")
+	})
+
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "http server",
+		Stage: StageHTTP,
+		Stop: func(ctx context.Context) error {
+			params.Logger.Info("Shutting down HTTP server")
 
 			return srv.Shutdown(ctx)
 		},
@@ -65,18 +115,88 @@ func NewFxLogger(logger LoggerService) fxevent.Logger {
 	return &fxLogger
 }
 
+// AsRouterMiddleware annotates a constructor returning a
+// func(http.Handler) http.Handler so its result is applied to the root router.
+func AsRouterMiddleware(constructor interface{}) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(RouterMiddlewareGroup)))
+}
+
+// AsFormContentPath accepts form-encoded bodies on path, such as the
+// OAuth token endpoint served by ServiceAccountService.TokenHandler. Other
+// routes only accept JSON.
+func AsFormContentPath(path string) fx.Option {
+	return fx.Provide(fx.Annotate(func() string { return path }, fx.ResultTags(FormContentPathsGroup)))
+}
+
+// allowContentType accepts JSON bodies, and form-encoded bodies on
+// formPaths.
+func allowContentType(formPaths []string) func(http.Handler) http.Handler {
+	allowJSON := middleware.AllowContentType("application/json")
+	allowForm := middleware.AllowContentType("application/json", "application/x-www-form-urlencoded")
+
+	forms := make(map[string]struct{}, len(formPaths))
+	for _, path := range formPaths {
+		forms[path] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		jsonOnly, jsonOrForm := allowJSON(next), allowForm(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := forms[r.URL.Path]; ok {
+				jsonOrForm.ServeHTTP(w, r)
+				return
+			}
+
+			jsonOnly.ServeHTTP(w, r)
+		})
+	}
+}
+
 func BuildServerOpts() []fx.Option {
-	return []fx.Option{
-		fx.Provide(NewRouter),
+	return append(BuildHandlerOpts(),
 		fx.Provide(NewServer),
 		fx.Invoke(func(*http.Server) {}),
+	)
+}
+
+// BuildHandlerOpts provides the router and auth without an HTTP server, for
+// apps served by something else.
+func BuildHandlerOpts() []fx.Option {
+	return []fx.Option{
+		fx.Provide(NewRouter),
 		fx.Provide(NewAuthService),
 	}
 }
 
+// Handler builds and starts an app from the app and handler opts plus opts,
+// which mount the controllers, and returns its router so it can be embedded
+// in an existing server or a serverless adapter. stop shuts the app down.
+func Handler(ctx context.Context, opts ...fx.Option) (handler http.Handler, stop func(context.Context) error, err error) {
+	var router *chi.Mux
+
+	allOpts := append(BuildAppOpts(), BuildHandlerOpts()...)
+	allOpts = append(allOpts, opts...)
+	allOpts = append(allOpts, fx.Populate(&router))
+
+	app := fx.New(allOpts...)
+	if err := app.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to build app: %w", err)
+	}
+
+	if err := app.Start(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to start app: %w", err)
+	}
+
+	return router, app.Stop, nil
+}
+
 func BuildAppOpts() []fx.Option {
 	return []fx.Option{
 		fx.WithLogger(NewFxLogger),
+		fx.Provide(NewAppEnv),
 		fx.Provide(NewLoggerService),
+		fx.Provide(NewOptionalServices),
+		fx.Provide(NewShutdown),
 	}
 }
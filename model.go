@@ -1,5 +1,7 @@
 package mochi
 
-type Model interface {
-	GetID() uint
+// Model is a persisted record keyed by an ID of type K, such as uint, string,
+// or a UUID type.
+type Model[K comparable] interface {
+	GetID() K
 }
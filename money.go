@@ -0,0 +1,193 @@
+package mochi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currencyExponents lists the ISO 4217 currencies without two decimal
+// places.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyExponent returns the number of decimal places of an ISO 4217
+// currency code.
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+
+	return 2
+}
+
+// Money is an amount in a currency's minor units, such as cents, so sums
+// never suffer float rounding. Store it as two columns with
+//
+//	Price mochi.Money `gorm:"embedded;embeddedPrefix:price_"`
+//
+// which creates price_amount and price_currency; lists sort and filter on
+// price_amount in minor units. It renders as
+// {"amount": 1234, "currency": "USD", "formatted": "12.34"} and accepts
+// amount either as integer minor units or as a decimal string like "12.34".
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency" gorm:"size:3"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "12.34" or "-5" in currency.
+// Amounts with more decimal places than the currency allows are rejected
+// rather than rounded.
+func ParseMoney(amount, currency string) (Money, error) {
+	money := Money{Currency: currency}
+	if err := money.Validate(); err != nil {
+		return money, err
+	}
+
+	exponent := CurrencyExponent(currency)
+	amount = strings.TrimSpace(amount)
+
+	negative := strings.HasPrefix(amount, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(amount, "-"), ".")
+
+	if whole == "" || len(fraction) > exponent || strings.ContainsAny(whole+fraction, "+-") {
+		return money, fmt.Errorf("%w: invalid %s amount %q", ErrValidation, currency, amount)
+	}
+
+	digits := whole + fraction + strings.Repeat("0", exponent-len(fraction))
+
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return money, fmt.Errorf("%w: invalid %s amount %q", ErrValidation, currency, amount)
+	}
+
+	if negative {
+		minor = -minor
+	}
+
+	money.Amount = minor
+
+	return money, nil
+}
+
+// Validate checks that Currency is a three letter uppercase code.
+func (m Money) Validate() error {
+	if len(m.Currency) != 3 || strings.ToUpper(m.Currency) != m.Currency || strings.ToLower(m.Currency) == m.Currency {
+		return fmt.Errorf("%w: invalid currency %q", ErrValidation, m.Currency)
+	}
+
+	return nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m + other, which must be in the same currency.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return m, fmt.Errorf("%w: cannot add %s to %s", ErrValidation, other.Currency, m.Currency)
+	}
+
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return m, fmt.Errorf("%w: %s amount overflows", ErrValidation, m.Currency)
+	}
+
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other, which must be in the same currency.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return m, fmt.Errorf("%w: %s amount overflows", ErrValidation, m.Currency)
+	}
+
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Decimal formats the amount in major units, such as "12.34".
+func (m Money) Decimal() string {
+	exponent := CurrencyExponent(m.Currency)
+
+	sign := ""
+	amount := strconv.FormatInt(m.Amount, 10)
+
+	if strings.HasPrefix(amount, "-") {
+		sign = "-"
+		amount = amount[1:]
+	}
+
+	if exponent == 0 {
+		return sign + amount
+	}
+
+	if len(amount) <= exponent {
+		amount = strings.Repeat("0", exponent-len(amount)+1) + amount
+	}
+
+	return fmt.Sprintf("%s%s.%s", sign, amount[:len(amount)-exponent], amount[len(amount)-exponent:])
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Decimal(), m.Currency)
+}
+
+type moneyJSON struct {
+	Amount    json.RawMessage `json:"amount"`
+	Currency  string          `json:"currency"`
+	Formatted string          `json:"formatted,omitempty"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{
+		Amount:    json.RawMessage(strconv.FormatInt(m.Amount, 10)),
+		Currency:  m.Currency,
+		Formatted: m.Decimal(),
+	})
+}
+
+// UnmarshalJSON accepts integer minor units or a decimal string amount.
+// Fractional JSON numbers are rejected since they may already be rounded.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	money := Money{Currency: raw.Currency}
+	if err := money.Validate(); err != nil {
+		return err
+	}
+
+	var decimal string
+	if err := json.Unmarshal(raw.Amount, &decimal); err == nil {
+		parsed, err := ParseMoney(decimal, raw.Currency)
+		if err != nil {
+			return err
+		}
+
+		*m = parsed
+
+		return nil
+	}
+
+	amount, err := strconv.ParseInt(string(raw.Amount), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: amount must be integer minor units or a decimal string", ErrValidation)
+	}
+
+	money.Amount = amount
+	*m = money
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package mochi
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	OIDCDiscoveryPath          = "/.well-known/openid-configuration"
+	OIDCKeyRefreshMinInterval  = time.Minute
+	OIDCDefaultRefreshInterval = time.Hour
+)
+
+// OIDCConfig points the auth service at an external identity provider such
+// as Auth0, Keycloak, or Google.
+type OIDCConfig struct {
+	// IssuerURL must match the issuer of the discovery document exactly.
+	IssuerURL string
+	// Audience is the client ID tokens must be issued for. It is required,
+	// as the provider issues tokens for other apps too.
+	Audience string
+	Leeway   time.Duration
+
+	HTTPClient      *http.Client
+	RefreshInterval time.Duration
+}
+
+// OIDCClaims are the claims read from provider-issued ID and access tokens.
+type OIDCClaims struct {
+	jwt.RegisteredClaims
+
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+// OIDCUserResolver maps a verified provider identity to a local user, for
+// example by looking up or provisioning a user by the sub claim.
+type OIDCUserResolver interface {
+	ResolveUser(ctx context.Context, claims *OIDCClaims) (User, error)
+}
+
+type OIDCUserResolverFunc func(ctx context.Context, claims *OIDCClaims) (User, error)
+
+func (f OIDCUserResolverFunc) ResolveUser(ctx context.Context, claims *OIDCClaims) (User, error) {
+	return f(ctx, claims)
+}
+
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// OIDCProvider verifies tokens issued by an external provider using its
+// discovery document and JWKS. Keys are refreshed periodically and when a
+// token references an unknown key ID.
+type OIDCProvider struct {
+	config    OIDCConfig
+	discovery oidcDiscovery
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	if config.IssuerURL == "" {
+		return nil, fmt.Errorf("oidc issuer URL is required")
+	}
+
+	if config.Audience == "" {
+		return nil, fmt.Errorf("oidc audience is required")
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = OIDCDefaultRefreshInterval
+	}
+
+	provider := &OIDCProvider{config: config, keys: make(map[string]crypto.PublicKey)}
+
+	discoveryURL := strings.TrimSuffix(config.IssuerURL, "/") + OIDCDiscoveryPath
+	if err := provider.getJSON(ctx, discoveryURL, &provider.discovery); err != nil {
+		return nil, fmt.Errorf("failed to load discovery document: %w", err)
+	}
+
+	if provider.discovery.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", provider.discovery.Issuer, config.IssuerURL)
+	}
+
+	if provider.discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document has no jwks_uri")
+	}
+
+	if err := provider.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+func (p *OIDCProvider) Issuer() string {
+	return p.discovery.Issuer
+}
+
+// Verify checks the token signature, issuer, audience, and expiry.
+func (p *OIDCProvider) Verify(ctx context.Context, tokenString string) (*OIDCClaims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithIssuer(p.discovery.Issuer),
+		jwt.WithAudience(p.config.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(p.config.Leeway),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &OIDCClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}, opts...)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify oidc token: %w", err)
+	}
+
+	claims, ok := token.Claims.(*OIDCClaims)
+	if !ok || !token.Valid || claims.Subject == "" {
+		return nil, fmt.Errorf("invalid oidc token")
+	}
+
+	return claims, nil
+}
+
+// IssuedBy reports whether the unverified token claims this provider as its
+// issuer, to route it away from local token validation.
+func (p *OIDCProvider) IssuedBy(tokenString string) bool {
+	claims := &jwt.RegisteredClaims{}
+
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return false
+	}
+
+	return claims.Issuer == p.discovery.Issuer
+}
+
+func (p *OIDCProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.lastRefresh) > p.config.RefreshInterval
+	canRefresh := time.Since(p.lastRefresh) > OIDCKeyRefreshMinInterval
+	p.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+
+	if !ok && !canRefresh && !stale {
+		return nil, fmt.Errorf("unknown oidc key %q", kid)
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		if ok {
+			return key, nil
+		}
+
+		return nil, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown oidc key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	var jwks JWKS
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("failed to load jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = keys
+	p.lastRefresh = time.Now()
+
+	return nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
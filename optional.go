@@ -0,0 +1,113 @@
+package mochi
+
+import (
+	"go.uber.org/fx"
+)
+
+// OptionalServicesParams collects the implementations apps registered with
+// ProvideCache, ProvideMailer, ProvideSearch, ProvideMetrics, ProvideTracer, and
+// ProvideErrorReporter.
+type OptionalServicesParams struct {
+	fx.In
+
+	Logger LoggerService
+
+	Cache   CacheService   `name:"cache_impl" optional:"true"`
+	Mailer  MailerService  `name:"mailer_impl" optional:"true"`
+	Search  SearchService  `name:"search_impl" optional:"true"`
+	Metrics MetricsService `name:"metrics_impl" optional:"true"`
+	Tracer  Tracer         `name:"tracer_impl" optional:"true"`
+
+	ErrorReporter ErrorReporter `name:"error_reporter_impl" optional:"true"`
+}
+
+type OptionalServicesResult struct {
+	fx.Out
+
+	Cache   CacheService
+	Mailer  MailerService
+	Search  SearchService
+	Metrics MetricsService
+	Tracer  Tracer
+
+	ErrorReporter ErrorReporter
+}
+
+// NewOptionalServices resolves each optional service to the registered
+// implementation, falling back to a no-op so apps can adopt them one at a
+// time.
+func NewOptionalServices(params OptionalServicesParams) OptionalServicesResult {
+	result := OptionalServicesResult{
+		Cache:   params.Cache,
+		Mailer:  params.Mailer,
+		Search:  params.Search,
+		Metrics: params.Metrics,
+		Tracer:  params.Tracer,
+
+		ErrorReporter: params.ErrorReporter,
+	}
+
+	if result.Cache == nil {
+		params.Logger.Warn("No cache configured, using no-op cache")
+		result.Cache = NewNoopCacheService()
+	}
+
+	if result.Mailer == nil {
+		params.Logger.Warn("No mailer configured, using no-op mailer")
+		result.Mailer = NewNoopMailerService(params.Logger)
+	}
+
+	if result.Search == nil {
+		params.Logger.Warn("No search configured, using no-op search")
+		result.Search = NewNoopSearchService()
+	}
+
+	if result.Metrics == nil {
+		params.Logger.Warn("No metrics configured, using no-op metrics")
+		result.Metrics = NewNoopMetricsService()
+	}
+
+	if result.Tracer == nil {
+		params.Logger.Warn("No tracer configured, using no-op tracer")
+		result.Tracer = NewNoopTracer()
+	}
+
+	if result.ErrorReporter == nil {
+		params.Logger.Warn("No error reporter configured, using no-op error reporter")
+		result.ErrorReporter = NewNoopErrorReporter()
+	}
+
+	return result
+}
+
+func ProvideCache(constructor interface{}) fx.Option {
+	return provideNamed(constructor, new(CacheService), "cache_impl")
+}
+
+func ProvideMailer(constructor interface{}) fx.Option {
+	return provideNamed(constructor, new(MailerService), "mailer_impl")
+}
+
+func ProvideSearch(constructor interface{}) fx.Option {
+	return provideNamed(constructor, new(SearchService), "search_impl")
+}
+
+func ProvideMetrics(constructor interface{}) fx.Option {
+	return provideNamed(constructor, new(MetricsService), "metrics_impl")
+}
+
+func ProvideTracer(constructor interface{}) fx.Option {
+	return provideNamed(constructor, new(Tracer), "tracer_impl")
+}
+
+func ProvideErrorReporter(constructor interface{}) fx.Option {
+	return provideNamed(constructor, new(ErrorReporter), "error_reporter_impl")
+}
+
+func provideNamed(constructor interface{}, iface interface{}, name string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		constructor,
+		fx.As(iface),
+		fx.ResultTags(`name:"`+name+`"`),
+	))
+}
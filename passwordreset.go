@@ -0,0 +1,345 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/fx"
+)
+
+const (
+	PasswordResetSecretEnvName = "PASSWORD_RESET_SECRET"
+	PasswordResetURLEnvName    = "PASSWORD_RESET_URL"
+
+	PasswordResetTokenTTL = 30 * time.Minute
+
+	passwordResetPurpose = "password_reset"
+)
+
+// UserEmailLookup is implemented by UserServices that support password
+// resets. GetUserByEmail should return an error wrapping ErrRecordNotFound
+// for unknown emails.
+type UserEmailLookup interface {
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+}
+
+// PasswordResetDelivery sends a reset token to the user, typically as a
+// link by email.
+type PasswordResetDelivery interface {
+	DeliverPasswordReset(ctx context.Context, email, token string) error
+}
+
+// PasswordResetConfig defaults to PASSWORD_RESET_SECRET and
+// PASSWORD_RESET_URL. The reset URL receives the token as a query
+// parameter.
+type PasswordResetConfig struct {
+	Secret   []byte
+	ResetURL string
+	TTL      time.Duration
+}
+
+type passwordResetClaims struct {
+	jwt.RegisteredClaims
+
+	Purpose string `json:"purpose"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func (req *PasswordResetRequest) Bind(r *http.Request) error {
+	if req.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+
+	return nil
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (req *PasswordResetConfirmRequest) Bind(r *http.Request) error {
+	if req.Token == "" || req.Password == "" {
+		return fmt.Errorf("token and password are required")
+	}
+
+	return nil
+}
+
+// PasswordResetService issues single-use, expiring reset tokens and applies
+// new passwords through UserService.UpdateUserPassword. Its router can be
+// mounted for the optional HTTP routes.
+type PasswordResetService interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+
+	GetRouter() *chi.Mux
+}
+
+type PasswordResetServiceParams struct {
+	fx.In
+
+	Auth     AuthService           `optional:"true"`
+	Config   *PasswordResetConfig  `optional:"true"`
+	Delivery PasswordResetDelivery `optional:"true"`
+	Denylist TokenDenylist         `optional:"true"`
+	Hasher   PasswordHasher        `optional:"true"`
+	Logger   LoggerService
+	Mailer   MailerService
+
+	UserService UserService
+}
+
+type PasswordResetServiceResult struct {
+	fx.Out
+
+	PasswordResetService PasswordResetService
+}
+
+type passwordResetService struct {
+	auth        AuthService
+	config      PasswordResetConfig
+	delivery    PasswordResetDelivery
+	tokens      TokenConsumer
+	hasher      PasswordHasher
+	logger      LoggerService
+	users       UserEmailLookup
+	userService UserService
+	Router      *chi.Mux
+}
+
+func NewPasswordResetService(params PasswordResetServiceParams) (PasswordResetServiceResult, error) {
+	var result PasswordResetServiceResult
+
+	config := PasswordResetConfig{
+		Secret:   []byte(os.Getenv(PasswordResetSecretEnvName)),
+		ResetURL: os.Getenv(PasswordResetURLEnvName),
+	}
+
+	if params.Config != nil {
+		config = *params.Config
+	}
+
+	if len(config.Secret) == 0 {
+		return result, fmt.Errorf("password reset secret is not configured")
+	}
+
+	if config.TTL <= 0 {
+		config.TTL = PasswordResetTokenTTL
+	}
+
+	users, ok := params.UserService.(UserEmailLookup)
+	if !ok {
+		return result, fmt.Errorf("password resets require the UserService to implement UserEmailLookup")
+	}
+
+	delivery := params.Delivery
+	if delivery == nil {
+		delivery = &mailerPasswordResetDelivery{mailer: params.Mailer, resetURL: config.ResetURL}
+	}
+
+	denylist := params.Denylist
+	if denylist == nil {
+		params.Logger.Warn("No token denylist configured, used reset tokens are kept in memory")
+		denylist = NewMemoryTokenDenylist()
+	}
+
+	tokens, ok := denylist.(TokenConsumer)
+	if !ok {
+		return result, fmt.Errorf("password resets require the token denylist to implement TokenConsumer")
+	}
+
+	hasher := params.Hasher
+	if hasher == nil {
+		hasher = DefaultPasswordHasher()
+	}
+
+	srv := &passwordResetService{
+		auth:        params.Auth,
+		config:      config,
+		delivery:    delivery,
+		tokens:      tokens,
+		hasher:      hasher,
+		logger:      params.Logger,
+		users:       users,
+		userService: params.UserService,
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Post("/", srv.handleRequest)
+	srv.Router.Post("/confirm", srv.handleConfirm)
+
+	result.PasswordResetService = srv
+
+	return result, nil
+}
+
+// RequestPasswordReset sends a reset token to the user with the given
+// email. Unknown emails are not reported so the endpoint can't be used to
+// discover accounts.
+func (srv *passwordResetService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := srv.users.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrRecordNotFound) {
+		srv.logger.WithContext(ctx).Info("Password reset requested for unknown email")
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+
+	now := time.Now()
+	claims := &passwordResetClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.GetID()), 10),
+			ExpiresAt: jwt.NewNumericDate(now.Add(srv.config.TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        newTokenID(),
+		},
+		Purpose: passwordResetPurpose,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(srv.config.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to sign reset token: %w", err)
+	}
+
+	if err := srv.delivery.DeliverPasswordReset(ctx, email, token); err != nil {
+		return fmt.Errorf("failed to deliver reset token: %w", err)
+	}
+
+	srv.logger.WithContext(ctx).Info("Sent password reset", "user", user.GetID())
+
+	return nil
+}
+
+// ResetPassword sets a new password for the user the token was issued to.
+// Each token can only be used once.
+func (srv *passwordResetService) ResetPassword(ctx context.Context, tokenString, newPassword string) error {
+	if newPassword == "" {
+		return fmt.Errorf("%w: password is required", ErrValidation)
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &passwordResetClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return srv.config.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+
+	if err != nil {
+		return NewStatusError(http.StatusBadRequest, fmt.Errorf("invalid reset token: %w", err))
+	}
+
+	claims, ok := token.Claims.(*passwordResetClaims)
+	if !ok || !token.Valid || claims.Purpose != passwordResetPurpose || claims.ID == "" {
+		return NewStatusError(http.StatusBadRequest, fmt.Errorf("invalid reset token"))
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 0)
+	if err != nil {
+		return NewStatusError(http.StatusBadRequest, fmt.Errorf("invalid reset token subject: %w", err))
+	}
+
+	consumed, err := srv.tokens.Consume(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("failed to mark reset token used: %w", err)
+	}
+
+	if !consumed {
+		return NewStatusError(http.StatusBadRequest, fmt.Errorf("reset token has already been used"))
+	}
+
+	if err := srv.setPassword(ctx, uint(userID), newPassword); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	// drop the cached user so requests stop seeing the old password hash
+	if srv.auth != nil {
+		srv.auth.InvalidateUser(uint(userID))
+	}
+
+	srv.logger.WithContext(ctx).Info("Reset user password", "user", userID)
+
+	return nil
+}
+
+// setPassword stores a hash for UserServices implementing
+// PasswordCredentialStore and passes the password to UpdateUserPassword
+// otherwise.
+func (srv *passwordResetService) setPassword(ctx context.Context, userID uint, password string) error {
+	store, ok := srv.userService.(PasswordCredentialStore)
+	if !ok {
+		return srv.userService.UpdateUserPassword(ctx, userID, password)
+	}
+
+	hash, err := srv.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return store.SetUserPasswordHash(ctx, userID, hash)
+}
+
+func (srv *passwordResetService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *passwordResetService) handleRequest(w http.ResponseWriter, r *http.Request) {
+	req := &PasswordResetRequest{}
+	if err := render.Bind(r, req); err != nil {
+		DefaultErrorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	if err := srv.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		srv.logger.WithContext(r.Context()).Error("Failed to request password reset", "error", err)
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (srv *passwordResetService) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	req := &PasswordResetConfirmRequest{}
+	if err := render.Bind(r, req); err != nil {
+		DefaultErrorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	if err := srv.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	render.NoContent(w, r)
+}
+
+// mailerPasswordResetDelivery emails the reset link through MailerService.
+type mailerPasswordResetDelivery struct {
+	mailer   MailerService
+	resetURL string
+}
+
+func (d *mailerPasswordResetDelivery) DeliverPasswordReset(ctx context.Context, email, token string) error {
+	link := token
+	if d.resetURL != "" {
+		link = fmt.Sprintf("%s?%s", d.resetURL, url.Values{"token": {token}}.Encode())
+	}
+
+	return d.mailer.Send(ctx, Email{
+		To:       []string{email},
+		Subject:  "Reset your password",
+		TextBody: fmt.Sprintf("Use the link below to reset your password. It expires soon and can only be used once.\n\n%s\n", link),
+	})
+}
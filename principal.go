@@ -0,0 +1,37 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+)
+
+// PrincipalResolver turns validated token claims into the principal stored
+// in the request context. Provide one to authenticate other principal types,
+// such as devices, or to enrich users with roles or tenant data in one
+// place. Custom resolvers run for every request; only the user lookups of
+// the default resolver are cached by AuthRequired.
+type PrincipalResolver interface {
+	ResolvePrincipal(ctx context.Context, claims *Claims) (User, error)
+}
+
+type PrincipalResolverFunc func(ctx context.Context, claims *Claims) (User, error)
+
+func (f PrincipalResolverFunc) ResolvePrincipal(ctx context.Context, claims *Claims) (User, error) {
+	return f(ctx, claims)
+}
+
+// NewDefaultPrincipalResolver resolves user tokens with
+// UserService.GetUserByID and service account tokens from their claims.
+// Custom resolvers can delegate to it for the types they don't handle.
+func NewDefaultPrincipalResolver(userService UserService) PrincipalResolver {
+	return PrincipalResolverFunc(func(ctx context.Context, claims *Claims) (User, error) {
+		switch claims.Pty {
+		case "":
+			return userService.GetUserByID(ctx, claims.Sub)
+		case PrincipalTypeServiceAccount:
+			return &ServiceAccountPrincipal{AccountID: claims.Sub, Scopes: claims.Scopes()}, nil
+		default:
+			return nil, fmt.Errorf("unknown principal type %q", claims.Pty)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package mochi
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/fx"
+)
+
+const (
+	MetricsPath         = "/metrics"
+	MetricsTokenEnvName = "METRICS_TOKEN"
+
+	HTTPRequestsMetric        = "mochi_http_requests_total"
+	HTTPRequestDurationMetric = "mochi_http_request_duration_seconds"
+	AuthFailuresMetric        = "mochi_auth_failures_total"
+)
+
+// DefaultHistogramBuckets are the upper bounds, in seconds, of histogram
+// buckets, matching the Prometheus client defaults.
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricFamily struct {
+	kind   string
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// PrometheusMetrics is a MetricsService kept in memory and served in the
+// Prometheus text format by ServeHTTP. A name keeps the kind it was first
+// recorded as; mismatched calls are ignored.
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		buckets:  DefaultHistogramBuckets,
+		families: make(map[string]*metricFamily),
+	}
+}
+
+func (m *PrometheusMetrics) IncCounter(name string, value float64, labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if series := m.series(name, "counter", labels); series != nil {
+		series.value += value
+	}
+}
+
+func (m *PrometheusMetrics) SetGauge(name string, value float64, labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if series := m.series(name, "gauge", labels); series != nil {
+		series.value = value
+	}
+}
+
+func (m *PrometheusMetrics) ObserveHistogram(name string, value float64, labels MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series := m.series(name, "histogram", labels)
+	if series == nil {
+		return
+	}
+
+	if series.buckets == nil {
+		series.buckets = make([]uint64, len(m.buckets))
+	}
+
+	for i, bound := range m.buckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+
+	series.sum += value
+	series.count++
+}
+
+// series returns the series of name with labels, or nil when name was
+// recorded as another kind. The caller holds mu.
+func (m *PrometheusMetrics) series(name, kind string, labels MetricLabels) *metricSeries {
+	family, ok := m.families[name]
+	if !ok {
+		family = &metricFamily{kind: kind, series: make(map[string]*metricSeries)}
+		m.families[name] = family
+	}
+
+	if family.kind != kind {
+		return nil
+	}
+
+	key := formatMetricLabels(labels)
+
+	series, ok := family.series[key]
+	if !ok {
+		series = &metricSeries{}
+		family.series[key] = series
+	}
+
+	return series
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out strings.Builder
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := m.families[name]
+		fmt.Fprintf(&out, "# TYPE %s %s\n", name, family.kind)
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			series := family.series[key]
+
+			if family.kind != "histogram" {
+				fmt.Fprintf(&out, "%s%s %s\n", name, wrapMetricLabels(key), formatMetricValue(series.value))
+				continue
+			}
+
+			for i, bound := range m.buckets {
+				le := fmt.Sprintf("le=%q", formatMetricValue(bound))
+				fmt.Fprintf(&out, "%s_bucket%s %d\n", name, wrapMetricLabels(joinMetricLabels(key, le)), series.buckets[i])
+			}
+
+			fmt.Fprintf(&out, "%s_bucket%s %d\n", name, wrapMetricLabels(joinMetricLabels(key, `le="+Inf"`)), series.count)
+			fmt.Fprintf(&out, "%s_sum%s %s\n", name, wrapMetricLabels(key), formatMetricValue(series.sum))
+			fmt.Fprintf(&out, "%s_count%s %d\n", name, wrapMetricLabels(key), series.count)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(out.String()))
+}
+
+func formatMetricLabels(labels MetricLabels) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", key, escapeMetricLabel(labels[key])))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func escapeMetricLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func joinMetricLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+
+	return labels + "," + extra
+}
+
+func wrapMetricLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// HTTPMetrics records the count and duration of requests by method, route
+// pattern, and status. Routes are labeled by their pattern, such as
+// /tasks/{id}/, so IDs don't create new series.
+func HTTPMetrics(metrics MetricsService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			metrics.IncCounter(HTTPRequestsMetric, 1, MetricLabels{
+				"method": r.Method,
+				"route":  route,
+				"status": strconv.Itoa(status),
+			})
+
+			metrics.ObserveHistogram(HTTPRequestDurationMetric, time.Since(started).Seconds(), MetricLabels{
+				"method": r.Method,
+				"route":  route,
+			})
+		})
+	}
+}
+
+// metricsEndpoint serves metrics, requiring METRICS_TOKEN as a bearer token
+// when it is set.
+func metricsEndpoint(metrics *PrometheusMetrics) http.Handler {
+	token := os.Getenv(MetricsTokenEnvName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+// PrometheusOpts makes PrometheusMetrics the app's MetricsService, records
+// HTTP request metrics, and serves them at /metrics. Database query and
+// auth failure metrics are recorded through the same MetricsService.
+func PrometheusOpts() []fx.Option {
+	return []fx.Option{
+		fx.Provide(NewPrometheusMetrics),
+		ProvideMetrics(func(metrics *PrometheusMetrics) *PrometheusMetrics { return metrics }),
+		AsRouterMiddleware(HTTPMetrics),
+		fx.Invoke(func(router *chi.Mux, metrics *PrometheusMetrics) {
+			router.Handle(MetricsPath, metricsEndpoint(metrics))
+		}),
+	}
+}
@@ -0,0 +1,269 @@
+package mochi
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	PublicIDColumn       = "public_id"
+	PublicIDLength       = 20
+	PublicIDTaskName     = "assign-public-ids"
+	publicIDAlphabet     = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	publicIDBatchSize    = 500
+	maxPublicIDPrefixLen = 16
+)
+
+// PublicIDModel is implemented by models exposing a prefixed public ID,
+// such as "task_3ZbqW8cG0a7TmkVx1LpE", next to their numeric primary key.
+// The model needs a public_id column, ideally with a unique index:
+//
+//	PublicID string `gorm:"size:40;uniqueIndex" json:"id"`
+//
+// It is generated on create and can't be changed afterwards.
+type PublicIDModel interface {
+	PublicIDPrefix() string
+}
+
+// NewPublicID returns prefix, an underscore, and PublicIDLength random
+// base62 characters.
+func NewPublicID(prefix string) string {
+	alphabetSize := big.NewInt(int64(len(publicIDAlphabet)))
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteByte('_')
+
+	for i := 0; i < PublicIDLength; i++ {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			panic(fmt.Sprintf("failed to read random bytes: %v", err))
+		}
+
+		b.WriteByte(publicIDAlphabet[n.Int64()])
+	}
+
+	return b.String()
+}
+
+// PublicIDParser is an IDParser for detail routes addressed by public ID.
+// IDs with another prefix or malformed ones are reported as not found
+// without a query. WithPublicID uses it.
+func PublicIDParser(prefix string) IDParser[string] {
+	return func(value string) (string, error) {
+		random, ok := strings.CutPrefix(value, prefix+"_")
+		if !ok || len(random) != PublicIDLength {
+			return "", fmt.Errorf("%w: invalid %s ID %q", ErrRecordNotFound, prefix, value)
+		}
+
+		for _, r := range random {
+			if !strings.ContainsRune(publicIDAlphabet, r) {
+				return "", fmt.Errorf("%w: invalid %s ID %q", ErrRecordNotFound, prefix, value)
+			}
+		}
+
+		return value, nil
+	}
+}
+
+func checkPublicIDPrefix(prefix string) error {
+	if prefix == "" || len(prefix) > maxPublicIDPrefixLen {
+		return fmt.Errorf("invalid public ID prefix %q", prefix)
+	}
+
+	for _, r := range prefix {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return fmt.Errorf("invalid public ID prefix %q, use lowercase letters and digits", prefix)
+		}
+	}
+
+	return nil
+}
+
+type publicIDs struct {
+	prefixes map[reflect.Type]string
+}
+
+// registerPublicIDs installs the callbacks generating and protecting the
+// public IDs of models.
+func registerPublicIDs(db *gorm.DB, models ModelList) error {
+	ids := &publicIDs{prefixes: make(map[reflect.Type]string)}
+
+	for _, model := range models {
+		public, ok := model.(PublicIDModel)
+		if !ok {
+			continue
+		}
+
+		if err := checkPublicIDPrefix(public.PublicIDPrefix()); err != nil {
+			return fmt.Errorf("invalid public ID on %T: %w", model, err)
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse public ID model %T: %w", model, err)
+		}
+
+		if stmt.Schema.LookUpField(PublicIDColumn) == nil {
+			return fmt.Errorf("%T needs a %s column for its public ID", model, PublicIDColumn)
+		}
+
+		ids.prefixes[stmt.Schema.ModelType] = public.PublicIDPrefix()
+	}
+
+	if len(ids.prefixes) == 0 {
+		return nil
+	}
+
+	err := db.Callback().Create().Before("gorm:create").Register("mochi:public_id_create", ids.beforeCreate)
+	if err != nil {
+		return err
+	}
+
+	return db.Callback().Update().Before("gorm:update").Register("mochi:public_id_update", ids.beforeUpdate)
+}
+
+func (ids *publicIDs) beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+
+	prefix, ok := ids.prefixes[stmt.Schema.ModelType]
+	if !ok {
+		return
+	}
+
+	field := stmt.Schema.LookUpField(PublicIDColumn)
+
+	eachRow(stmt.ReflectValue, func(row reflect.Value) {
+		if _, isZero := field.ValueOf(stmt.Context, row); !isZero {
+			return
+		}
+
+		if err := field.Set(stmt.Context, row, NewPublicID(prefix)); err != nil {
+			db.AddError(fmt.Errorf("failed to set public ID: %w", err))
+		}
+	})
+}
+
+// beforeUpdate drops writes to the public ID, which links depend on.
+func (ids *publicIDs) beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	if _, ok := ids.prefixes[db.Statement.Schema.ModelType]; !ok {
+		return
+	}
+
+	if updatesColumn(db.Statement, PublicIDColumn) {
+		db.Statement.Omits = append(db.Statement.Omits, PublicIDColumn)
+	}
+}
+
+// AssignPublicIDs generates public IDs for rows created before their
+// model had one, returning the number of rows updated.
+func AssignPublicIDs(ctx context.Context, db DBService, models ModelList) (int64, error) {
+	var assigned int64
+
+	for _, model := range models {
+		public, ok := model.(PublicIDModel)
+		if !ok {
+			continue
+		}
+
+		modelSchema, err := parsePublicIDModel(ctx, db, model)
+		if err != nil {
+			return assigned, err
+		}
+
+		table, pk := modelSchema.Table, modelSchema.PrioritizedPrimaryField.DBName
+		missing := fmt.Sprintf("(%[1]s IS NULL OR %[1]s = '')", PublicIDColumn)
+
+		for {
+			var rowIDs []string
+
+			err := db.Raw(ctx, &rowIDs, fmt.Sprintf(
+				"SELECT %[2]s FROM %[1]s WHERE %[3]s ORDER BY %[2]s LIMIT %[4]d",
+				table, pk, missing, publicIDBatchSize,
+			))
+			if err != nil {
+				return assigned, fmt.Errorf("failed to find %s rows without public IDs: %w", table, err)
+			}
+
+			for _, rowID := range rowIDs {
+				written, err := db.Exec(ctx, fmt.Sprintf(
+					"UPDATE %s SET %s = ? WHERE %s = ? AND %s",
+					table, PublicIDColumn, pk, missing,
+				), NewPublicID(public.PublicIDPrefix()), rowID)
+				if err != nil {
+					return assigned, fmt.Errorf("failed to assign %s public ID: %w", table, err)
+				}
+
+				assigned += written
+			}
+
+			if len(rowIDs) < publicIDBatchSize {
+				break
+			}
+		}
+	}
+
+	return assigned, nil
+}
+
+// parsePublicIDModel parses model with the naming strategy of db, so table
+// prefixes and custom names are honored.
+func parsePublicIDModel(ctx context.Context, db DBService, model interface{}) (*schema.Schema, error) {
+	sesh, cancel := db.GetSession(ctx)
+	defer cancel()
+
+	stmt := &gorm.Statement{DB: sesh}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse public ID model %T: %w", model, err)
+	}
+
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("%T needs a single primary key to backfill public IDs", model)
+	}
+
+	return stmt.Schema, nil
+}
+
+type PublicIDTaskParams struct {
+	fx.In
+
+	DB     DBService
+	Logger LoggerService
+	Models ModelList
+}
+
+// NewPublicIDTask returns the assign-public-ids task, which backfills
+// public IDs after adding PublicIDModel to an existing model. Register it
+// with AsTask.
+func NewPublicIDTask(params PublicIDTaskParams) Task {
+	return Task{
+		Name:        PublicIDTaskName,
+		Description: "Generate public IDs for rows that don't have one",
+		Run: func(ctx context.Context, args []string) error {
+			assigned, err := AssignPublicIDs(ctx, params.DB, params.Models)
+			if err != nil {
+				return err
+			}
+
+			params.Logger.Info("Assigned public IDs", "rows", assigned)
+
+			return nil
+		},
+	}
+}
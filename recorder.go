@@ -0,0 +1,402 @@
+package mochi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	RecorderBufferSize   = 100
+	RecorderMaxBodyBytes = 64 * 1024
+	RecorderRedactedText = "[REDACTED]"
+	RecorderOmittedText  = "[OMITTED]"
+)
+
+var recorderSensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+var recorderSensitiveFields = []string{"password", "token", "secret", "api_key", "apikey"}
+
+// RecorderFilter selects which requests the recorder captures. An empty
+// filter matches every request.
+type RecorderFilter struct {
+	UserID *uint  `json:"user_id,omitempty"`
+	Route  string `json:"route,omitempty"`
+}
+
+func (f *RecorderFilter) Bind(r *http.Request) error {
+	return nil
+}
+
+type RecordedExchange struct {
+	ID              uint64        `json:"id"`
+	RecordedAt      time.Time     `json:"recorded_at"`
+	Method          string        `json:"method"`
+	Path            string        `json:"path"`
+	RoutePattern    string        `json:"route_pattern"`
+	UserID          *uint         `json:"user_id,omitempty"`
+	Status          int           `json:"status"`
+	Duration        time.Duration `json:"duration"`
+	RequestHeaders  http.Header   `json:"request_headers"`
+	RequestBody     string        `json:"request_body,omitempty"`
+	ResponseHeaders http.Header   `json:"response_headers"`
+	ResponseBody    string        `json:"response_body,omitempty"`
+}
+
+func (e *RecordedExchange) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type recorderStatus struct {
+	Enabled bool            `json:"enabled"`
+	Filter  *RecorderFilter `json:"filter,omitempty"`
+	Count   int             `json:"count"`
+}
+
+func (s *recorderStatus) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// RecorderService is a flight recorder that captures sanitized
+// request/response pairs into a ring buffer while enabled. Its middleware
+// must run after AuthRequired for user filters to match.
+type RecorderService interface {
+	Middleware() func(http.Handler) http.Handler
+	Enable(filter RecorderFilter)
+	Disable()
+	Clear()
+	Entries() []RecordedExchange
+
+	GetRouter() *chi.Mux
+}
+
+type RecorderServiceParams struct {
+	fx.In
+
+	Auth   AuthService
+	Logger LoggerService
+}
+
+type RecorderServiceResult struct {
+	fx.Out
+
+	RecorderService RecorderService
+}
+
+type recorderService struct {
+	auth   AuthService
+	logger LoggerService
+	Router *chi.Mux
+
+	mu      sync.RWMutex
+	enabled bool
+	filter  RecorderFilter
+	entries []RecordedExchange
+	next    int
+	count   uint64
+}
+
+func NewRecorderService(params RecorderServiceParams) (RecorderServiceResult, error) {
+	srv := &recorderService{
+		auth:    params.Auth,
+		logger:  params.Logger,
+		entries: make([]RecordedExchange, 0, RecorderBufferSize),
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Get("/", srv.handleStatus)
+	srv.Router.Get("/entries", srv.handleEntries)
+	srv.Router.Delete("/entries", srv.handleClear)
+	srv.Router.Post("/enable", srv.handleEnable)
+	srv.Router.Post("/disable", srv.handleDisable)
+
+	return RecorderServiceResult{RecorderService: srv}, nil
+}
+
+func (srv *recorderService) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			srv.mu.RLock()
+			enabled, filter := srv.enabled, srv.filter
+			srv.mu.RUnlock()
+
+			if !enabled || !srv.matches(r, filter) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var reqBody []byte
+			if r.Body != nil {
+				reqBody, _ = io.ReadAll(io.LimitReader(r.Body, RecorderMaxBodyBytes))
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(reqBody), r.Body))
+			}
+
+			respBody := &bytes.Buffer{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&limitedBuffer{buf: respBody, limit: RecorderMaxBodyBytes})
+
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+
+			exchange := RecordedExchange{
+				RecordedAt:      start,
+				Method:          r.Method,
+				Path:            r.URL.Path,
+				Status:          ww.Status(),
+				Duration:        time.Since(start),
+				RequestHeaders:  sanitizeHeaders(r.Header),
+				RequestBody:     sanitizeBody(r.Header.Get("Content-Type"), reqBody),
+				ResponseHeaders: sanitizeHeaders(ww.Header()),
+				ResponseBody:    sanitizeBody(ww.Header().Get("Content-Type"), respBody.Bytes()),
+			}
+
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				exchange.RoutePattern = rctx.RoutePattern()
+			}
+
+			if user, err := srv.auth.GetUserFromCtx(r.Context()); err == nil {
+				userID := user.GetID()
+				exchange.UserID = &userID
+			}
+
+			srv.record(exchange)
+		})
+	}
+}
+
+func (srv *recorderService) Enable(filter RecorderFilter) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.enabled = true
+	srv.filter = filter
+
+	srv.logger.Warn("Request recorder enabled", "user_id", filter.UserID, "route", filter.Route)
+}
+
+func (srv *recorderService) Disable() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.enabled = false
+	srv.filter = RecorderFilter{}
+
+	srv.logger.Info("Request recorder disabled")
+}
+
+func (srv *recorderService) Clear() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.entries = srv.entries[:0]
+	srv.next = 0
+}
+
+// Entries returns the recorded exchanges, oldest first.
+func (srv *recorderService) Entries() []RecordedExchange {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	entries := make([]RecordedExchange, 0, len(srv.entries))
+	if len(srv.entries) < RecorderBufferSize {
+		return append(entries, srv.entries...)
+	}
+
+	entries = append(entries, srv.entries[srv.next:]...)
+	return append(entries, srv.entries[:srv.next]...)
+}
+
+func (srv *recorderService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *recorderService) matches(r *http.Request, filter RecorderFilter) bool {
+	if filter.Route != "" && !strings.HasPrefix(r.URL.Path, filter.Route) {
+		return false
+	}
+
+	if filter.UserID != nil {
+		user, err := srv.auth.GetUserFromCtx(r.Context())
+		if err != nil || user.GetID() != *filter.UserID {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (srv *recorderService) record(exchange RecordedExchange) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.count++
+	exchange.ID = srv.count
+
+	if len(srv.entries) < RecorderBufferSize {
+		srv.entries = append(srv.entries, exchange)
+		return
+	}
+
+	srv.entries[srv.next] = exchange
+	srv.next = (srv.next + 1) % RecorderBufferSize
+}
+
+func (srv *recorderService) status() *recorderStatus {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	status := &recorderStatus{Enabled: srv.enabled, Count: len(srv.entries)}
+	if srv.enabled {
+		filter := srv.filter
+		status.Filter = &filter
+	}
+
+	return status
+}
+
+func (srv *recorderService) handleStatus(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, srv.status())
+}
+
+func (srv *recorderService) handleEntries(w http.ResponseWriter, r *http.Request) {
+	entries := srv.Entries()
+
+	respList := make([]render.Renderer, 0, len(entries))
+	for i := range entries {
+		respList = append(respList, &entries[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
+
+func (srv *recorderService) handleClear(w http.ResponseWriter, r *http.Request) {
+	srv.Clear()
+	render.NoContent(w, r)
+}
+
+func (srv *recorderService) handleEnable(w http.ResponseWriter, r *http.Request) {
+	filter := &RecorderFilter{}
+	if err := render.Bind(r, filter); err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("failed to bind recorder filter: %w", err)))
+		return
+	}
+
+	srv.Enable(*filter)
+	render.Render(w, r, srv.status())
+}
+
+func (srv *recorderService) handleDisable(w http.ResponseWriter, r *http.Request) {
+	srv.Disable()
+	render.Render(w, r, srv.status())
+}
+
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+func sanitizeHeaders(headers http.Header) http.Header {
+	sanitized := headers.Clone()
+
+	for _, name := range recorderSensitiveHeaders {
+		if sanitized.Get(name) != "" {
+			sanitized.Set(name, RecorderRedactedText)
+		}
+	}
+
+	return sanitized
+}
+
+// sanitizeBody redacts sensitive fields of JSON and form bodies. Bodies it
+// can't parse, including ones truncated at RecorderMaxBodyBytes, are
+// replaced with RecorderOmittedText, as they may hold secrets too.
+func sanitizeBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return RecorderOmittedText
+		}
+
+		for key := range values {
+			if isSensitiveField(key) {
+				values[key] = []string{RecorderRedactedText}
+			}
+		}
+
+		return values.Encode()
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return RecorderOmittedText
+	}
+
+	sanitized, err := json.Marshal(redactFields(decoded))
+	if err != nil {
+		return RecorderOmittedText
+	}
+
+	return string(sanitized)
+}
+
+func redactFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitiveField(key) {
+				v[key] = RecorderRedactedText
+			} else {
+				v[key] = redactFields(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactFields(item)
+		}
+	}
+
+	return value
+}
+
+func isSensitiveField(key string) bool {
+	lowerKey := strings.ToLower(key)
+
+	for _, field := range recorderSensitiveFields {
+		if strings.Contains(lowerKey, field) {
+			return true
+		}
+	}
+
+	return false
+}
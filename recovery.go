@@ -0,0 +1,61 @@
+package mochi
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Recoverer turns a panic in a handler into a JSON 500 response, logging
+// the panic value and stack and passing them to the ErrorReporter of the
+// request. The body is left alone if the handler had
+// already started writing one. http.ErrAbortHandler is re-raised so the
+// server aborts the response as intended.
+func Recoverer(logger LoggerService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				stack := debug.Stack()
+
+				logger.WithContext(r.Context()).Error(
+					"Recovered from panic",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", fmt.Sprint(recovered),
+					"stack", string(stack),
+				)
+
+				reportError(r, panicError(recovered), http.StatusInternalServerError, stack)
+
+				if ww.Status() == 0 {
+					render.Render(ww, r, ErrPanic(recovered))
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// panicError wraps a recovered value, keeping errors unwrappable.
+func panicError(recovered interface{}) error {
+	if err, ok := recovered.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+
+	return fmt.Errorf("panic: %v", recovered)
+}
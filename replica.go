@@ -0,0 +1,104 @@
+package mochi
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const ReplicaURLsEnvName = "DATABASE_REPLICA_URLS"
+
+// DBReplicas are read replicas of the primary database. FindOne, FindMany,
+// and FindInBatches are spread across them; writes, migrations, and
+// GetSession always use the primary.
+type DBReplicas []gorm.Dialector
+
+type replicaContextKey int
+
+const (
+	forcePrimaryKey replicaContextKey = iota
+)
+
+// ForcePrimary sends reads made with the returned context to the primary,
+// for read-after-write consistency while replicas may lag.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey, true)
+}
+
+func isPrimaryForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forcePrimaryKey).(bool)
+	return forced
+}
+
+// replicaDialectorsFromEnv reads comma separated PostgreSQL DSNs from
+// DATABASE_REPLICA_URLS.
+func replicaDialectorsFromEnv() DBReplicas {
+	replicas := DBReplicas{}
+
+	for _, dsn := range strings.Split(os.Getenv(ReplicaURLsEnvName), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicas = append(replicas, newPostgresDialector(dsn))
+		}
+	}
+
+	return replicas
+}
+
+// connectReplicas connects to each replica. Replicas that can't be reached
+// are skipped so reads fall back to the primary instead of failing startup.
+func (srv *dbService) connectReplicas(ctx context.Context) {
+	dialectors := srv.replicaDialectors
+	if dialectors == nil {
+		dialectors = replicaDialectorsFromEnv()
+	}
+
+	for i, dialector := range dialectors {
+		replica, err := srv.open(dialector)
+		if err != nil {
+			srv.logger.Error("Failed to connect to read replica, skipping it", "replica", i, "error", err)
+			continue
+		}
+
+		srv.replicas = append(srv.replicas, replica)
+	}
+
+	if len(srv.replicas) > 0 {
+		srv.logger.Info("Routing reads to replicas", "replicas", len(srv.replicas))
+	}
+}
+
+// readDB picks the connection for a read, round-robin across replicas
+// unless ctx forces the primary.
+func (srv *dbService) readDB(ctx context.Context) *gorm.DB {
+	if len(srv.replicas) == 0 || isPrimaryForced(ctx) {
+		return srv.db
+	}
+
+	next := srv.nextReplica.Add(1)
+
+	return srv.replicas[next%uint64(len(srv.replicas))]
+}
+
+// getReadSession is GetSession for reads that may be served by a replica.
+func (srv *dbService) getReadSession(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	sessionCtx, cancel := queryContext(ctx)
+
+	return srv.readDB(ctx).Session(&gorm.Session{
+		Context: sessionCtx,
+	}), cancel
+}
+
+// PrimaryForWrites forces the primary for every read made while handling
+// unsafe requests, so writes never act on rows read from a lagging replica.
+func PrimaryForWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isSafeMethod(r.Method) {
+			r = r.WithContext(ForcePrimary(r.Context()))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -3,37 +3,80 @@ package mochi
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm/schema"
 )
 
-type Repository[M Model] interface {
+type Repository[M Model[K], K comparable] interface {
 	FindOne(ctx context.Context, query string, args ...interface{}) (M, error)
-	FindOneByID(ctx context.Context, itemID uint, query string, args ...interface{}) (M, error)
+	FindOneByID(ctx context.Context, itemID K, query string, args ...interface{}) (M, error)
+	FindOneByField(ctx context.Context, field string, value interface{}, query string, args ...interface{}) (M, error)
 	FindOneByUser(ctx context.Context, userID uint, query string, args ...interface{}) (M, error)
+	FindMany(ctx context.Context, query string, args ...interface{}) ([]M, error)
 	FindManyByUser(ctx context.Context, userID uint, query string, args ...interface{}) ([]M, error)
+	Count(ctx context.Context, query string, args ...interface{}) (int64, error)
+	CountByUser(ctx context.Context, userID uint, query string, args ...interface{}) (int64, error)
+	ExistsByID(ctx context.Context, itemID K) (bool, error)
+	Aggregate(ctx context.Context, spec AggregateSpec, dest interface{}) error
+	Stream(ctx context.Context, query string, fn func(item M) error, args ...interface{}) error
 	CreateOne(ctx context.Context, item M) error
-	UpdateOne(ctx context.Context, itemID uint, item M) error
-	DeleteOne(ctx context.Context, itemID uint) error
+	CreateOrUpdate(ctx context.Context, item M, conflictColumns ...string) error
+	CreateMany(ctx context.Context, items []M) error
+	UpdateOne(ctx context.Context, itemID K, item M) (M, error)
+	UpdateOneFields(ctx context.Context, itemID K, fields map[string]interface{}) (M, error)
+	SaveAggregate(ctx context.Context, item M, associations ...string) error
+	AppendAssociation(ctx context.Context, item M, name string, values ...interface{}) error
+	ReplaceAssociation(ctx context.Context, item M, name string, values ...interface{}) error
+	RemoveAssociation(ctx context.Context, item M, name string, values ...interface{}) error
+	FindAssociationTargets(ctx context.Context, name string, ids []interface{}) (interface{}, error)
+	TransferOwnership(ctx context.Context, transfer Transfer[K]) (int64, error)
+	DeleteOne(ctx context.Context, itemID K) error
 }
 
-type repository[M Model] struct {
+type repository[M Model[K], K comparable] struct {
 	db     DBService
 	logger LoggerService
 
-	joinTables    []string
-	preloadTables []string
-	tableName     string
+	cascadeDeletes   []string
+	cascadeNullifies []string
+	defaultSort      []SortKey
+	joinTables       []string
+	maxResults       int
+	preloadTables    []string
+	strictMax        bool
+	modelName        string
+	tableName        string
+	tenantScoped     bool
+	viewBacked       bool
+
+	schemaCache sync.Map
 }
 
-type RepositoryOption[M Model] func(*repository[M])
+const (
+	StreamBatchSize = 500
+	CreateBatchSize = 500
+)
+
+type RepositoryOption[M Model[K], K comparable] func(*repository[M, K])
 
-func NewRepository[M Model](
+func NewRepository[M Model[K], K comparable](
 	db DBService,
 	logger LoggerService,
-	opts ...RepositoryOption[M],
-) Repository[M] {
-	repo := &repository[M]{
-		db:     db,
-		logger: logger,
+	opts ...RepositoryOption[M, K],
+) Repository[M, K] {
+	var model M
+	_, tenantScoped := any(model).(TenantModel)
+
+	repo := &repository[M, K]{
+		db:           db,
+		logger:       logger,
+		maxResults:   DefaultMaxResults,
+		modelName:    modelTypeName[M](),
+		tenantScoped: tenantScoped,
+		viewBacked:   isViewModel[M](),
 	}
 
 	for _, opt := range opts {
@@ -43,119 +86,676 @@ func NewRepository[M Model](
 	return repo
 }
 
-func (r *repository[M]) FindOne(ctx context.Context, query string, args ...interface{}) (M, error) {
+func (r *repository[M, K]) FindOne(ctx context.Context, query string, args ...interface{}) (M, error) {
+	ctx, span := r.startSpan(ctx, "FindOne")
+	defer span.End()
+
 	var item M
 
-	err := r.db.FindOne(ctx, &item, r.joinTables, []string{}, query, args...)
+	query, args, err := r.scopeQuery(ctx, query, args)
+	if err != nil {
+		return item, err
+	}
+
+	err = r.db.FindOne(ctx, &item, r.joinTables, r.preloads(ctx), query, args...)
 	if err != nil {
 		return item, fmt.Errorf("failed to find one item: %w", err)
 	}
 
-	r.logger.Debug("Found one item", "item", item.GetID(), "table", r.tableName)
+	r.logger.WithContext(ctx).Debug("Found one item", "item", item.GetID(), "table", r.tableName)
 
 	return item, nil
 }
 
-func (r *repository[M]) FindOneByID(ctx context.Context, itemID uint, query string, args ...interface{}) (M, error) {
-	fullQuery := fmt.Sprintf("%s.id = ?", r.tableName)
-	if query != "" {
-		fullQuery = fmt.Sprintf("%s AND %s", fullQuery, query)
+func (r *repository[M, K]) FindOneByID(ctx context.Context, itemID K, query string, args ...interface{}) (M, error) {
+	var item M
+
+	fullQuery, fullArgs, err := And(Eq(r.column("id"), itemID), Raw(query, args...)).Build()
+	if err != nil {
+		return item, err
 	}
 
-	fullArgs := append([]interface{}{itemID}, args...)
+	return r.FindOne(ctx, fullQuery, fullArgs...)
+}
+
+// FindOneByField looks up an item by an alternate unique column, such as a
+// slug. The field must be a plain column name.
+func (r *repository[M, K]) FindOneByField(
+	ctx context.Context,
+	field string,
+	value interface{},
+	query string,
+	args ...interface{},
+) (M, error) {
+	var item M
+
+	if !isValidColumnName(field) {
+		return item, fmt.Errorf("invalid lookup field %q", field)
+	}
+
+	fullQuery, fullArgs, err := And(Eq(r.column(field), value), Raw(query, args...)).Build()
+	if err != nil {
+		return item, err
+	}
 
 	return r.FindOne(ctx, fullQuery, fullArgs...)
 }
 
-func (r *repository[M]) FindOneByUser(ctx context.Context, userID uint, query string, args ...interface{}) (M, error) {
+func (r *repository[M, K]) FindOneByUser(ctx context.Context, userID uint, query string, args ...interface{}) (M, error) {
+	ctx, span := r.startSpan(ctx, "FindOneByUser")
+	defer span.End()
+
 	var item M
 
-	fullQuery := fmt.Sprintf("%s.user_id = ?", r.tableName)
-	if query != "" {
-		fullQuery = fmt.Sprintf("%s AND %s", fullQuery, query)
+	fullQuery, fullArgs, err := And(Eq(r.column("user_id"), userID), Raw(query, args...)).Build()
+	if err != nil {
+		return item, err
 	}
 
-	fullArgs := append([]interface{}{userID}, args...)
+	fullQuery, fullArgs, err = r.scopeQuery(ctx, fullQuery, fullArgs)
+	if err != nil {
+		return item, err
+	}
 
-	err := r.db.FindOne(ctx, &item, r.joinTables, []string{}, fullQuery, fullArgs...)
+	err = r.db.FindOne(ctx, &item, r.joinTables, r.preloads(ctx), fullQuery, fullArgs...)
 	if err != nil {
 		return item, fmt.Errorf("failed to find one item: %w", err)
 	}
 
-	r.logger.Debug("Found one item by user", "item", item.GetID(), "table", r.tableName)
+	r.logger.WithContext(ctx).Debug("Found one item by user", "item", item.GetID(), "table", r.tableName)
 
 	return item, nil
 }
 
-func (r *repository[M]) FindManyByUser(ctx context.Context, userID uint, query string, args ...interface{}) ([]M, error) {
+func (r *repository[M, K]) FindManyByUser(ctx context.Context, userID uint, query string, args ...interface{}) ([]M, error) {
+	ctx, span := r.startSpan(ctx, "FindManyByUser")
+	defer span.End()
+
+	fullQuery, fullArgs, err := And(Eq(r.column("user_id"), userID), Raw(query, args...)).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.findMany(ctx, fullQuery, fullArgs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find many items by user: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Found many items by user", "table", r.tableName, "count", len(items))
+
+	return items, nil
+}
+
+// FindMany finds items across all users. It is meant for public and admin
+// listings; user facing lists should use FindManyByUser.
+func (r *repository[M, K]) FindMany(ctx context.Context, query string, args ...interface{}) ([]M, error) {
+	ctx, span := r.startSpan(ctx, "FindMany")
+	defer span.End()
+
+	items, err := r.findMany(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find many items: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Found many items", "table", r.tableName, "count", len(items))
+
+	return items, nil
+}
+
+// findMany applies filters, tenant scoping, and ordering from ctx.
+func (r *repository[M, K]) findMany(ctx context.Context, query string, args []interface{}) ([]M, error) {
 	var items []M
 
-	fullQuery := fmt.Sprintf("%s.user_id = ?", r.tableName)
+	query, args, err := r.filterQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err = r.scopeQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = r.orderedContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var where interface{}
 	if query != "" {
-		fullQuery = fmt.Sprintf("%s AND %s", fullQuery, query)
+		where = query
 	}
 
-	fullArgs := append([]interface{}{userID}, args...)
+	// one extra row tells a full page apart from a truncated one
+	if r.maxResults > 0 {
+		ctx = withResultLimit(ctx, r.maxResults+1)
+	}
 
-	err := r.db.FindMany(ctx, &items, r.joinTables, r.preloadTables, fullQuery, fullArgs...)
+	err = r.db.FindMany(ctx, &items, r.joinTables, r.preloads(ctx), where, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find many items by user: %w", err)
+		return nil, err
 	}
 
-	r.logger.Debug("Found many items by user", "table", r.tableName, "count", len(items))
+	if r.maxResults > 0 && len(items) > r.maxResults {
+		if r.strictMax {
+			return nil, fmt.Errorf("%w: query matches more than %d items", ErrTooManyResults, r.maxResults)
+		}
+
+		r.logger.WithContext(ctx).Warn("Truncated list query", "table", r.tableName, "max_results", r.maxResults)
+
+		items = items[:r.maxResults]
+
+		if info, ok := ResultInfoFromCtx(ctx); ok {
+			info.Truncated = true
+			info.Limit = r.maxResults
+		}
+	}
 
 	return items, nil
 }
 
-func (r *repository[M]) CreateOne(ctx context.Context, item M) error {
+// Count counts items matching query, such as for quotas, without loading
+// them.
+func (r *repository[M, K]) Count(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	ctx, span := r.startSpan(ctx, "Count")
+	defer span.End()
+
+	query, args, err := r.scopeQuery(ctx, query, args)
+	if err != nil {
+		return 0, err
+	}
+
+	var where interface{}
+	if query != "" {
+		where = query
+	}
+
+	count, err := r.db.Count(ctx, new(M), r.joinTables, where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count items: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *repository[M, K]) CountByUser(ctx context.Context, userID uint, query string, args ...interface{}) (int64, error) {
+	fullQuery, fullArgs, err := And(Eq(r.column("user_id"), userID), Raw(query, args...)).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	return r.Count(ctx, fullQuery, fullArgs...)
+}
+
+func (r *repository[M, K]) ExistsByID(ctx context.Context, itemID K) (bool, error) {
+	query, args, err := Eq(r.column("id"), itemID).Build()
+	if err != nil {
+		return false, err
+	}
+
+	count, err := r.Count(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Stream calls fn for every item matching query without loading the whole
+// result set, fetching StreamBatchSize rows at a time. Returning an error
+// from fn stops the stream.
+func (r *repository[M, K]) Stream(ctx context.Context, query string, fn func(item M) error, args ...interface{}) error {
+	ctx, span := r.startSpan(ctx, "Stream")
+	defer span.End()
+
+	query, args, err := r.scopeQuery(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	var batch []M
+	count := 0
+
+	var where interface{}
+	if query != "" {
+		where = query
+	}
+
+	err = r.db.FindInBatches(ctx, &batch, StreamBatchSize, r.joinTables, r.preloads(ctx), func() error {
+		for _, item := range batch {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+
+		count += len(batch)
+
+		return nil
+	}, where, args...)
+
+	if err != nil {
+		return fmt.Errorf("failed to stream items: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Streamed items", "table", r.tableName, "count", count)
+
+	return nil
+}
+
+func (r *repository[M, K]) CreateOne(ctx context.Context, item M) error {
+	ctx, span := r.startSpan(ctx, "CreateOne")
+	defer span.End()
+
+	if r.viewBacked {
+		return ErrViewNotWritable
+	}
+
+	if err := r.assignTenant(ctx, item); err != nil {
+		return err
+	}
+
 	err := r.db.CreateOne(ctx, item)
 	if err != nil {
 		return fmt.Errorf("failed to create one item: %w", err)
 	}
 
-	r.logger.Debug("Created one item", "item", item.GetID(), "table", r.tableName)
+	r.logger.WithContext(ctx).Debug("Created one item", "item", item.GetID(), "table", r.tableName)
 
 	return nil
 }
 
-func (r *repository[M]) UpdateOne(ctx context.Context, itemID uint, item M) error {
+// CreateMany creates items CreateBatchSize rows per INSERT. Either every
+// item is created or none are.
+func (r *repository[M, K]) CreateMany(ctx context.Context, items []M) error {
+	ctx, span := r.startSpan(ctx, "CreateMany")
+	defer span.End()
+
+	if r.viewBacked {
+		return ErrViewNotWritable
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	for _, item := range items {
+		if err := r.assignTenant(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	err := r.db.CreateMany(ctx, &items, CreateBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to create items: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Created many items", "count", len(items), "table", r.tableName)
+
+	return nil
+}
+
+// CreateOrUpdate creates item or updates the row it conflicts with on
+// conflictColumns, such as an external ID, in a single statement.
+func (r *repository[M, K]) CreateOrUpdate(ctx context.Context, item M, conflictColumns ...string) error {
+	ctx, span := r.startSpan(ctx, "CreateOrUpdate")
+	defer span.End()
+
+	if r.viewBacked {
+		return ErrViewNotWritable
+	}
+
+	if err := r.assignTenant(ctx, item); err != nil {
+		return err
+	}
+
+	err := r.db.CreateOrUpdate(ctx, item, conflictColumns...)
+	if err != nil {
+		return fmt.Errorf("failed to create or update item: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Created or updated one item", "item", item.GetID(), "table", r.tableName)
+
+	return nil
+}
+
+// UpdateOne updates the item and returns it as stored, including columns
+// set by the database.
+func (r *repository[M, K]) UpdateOne(ctx context.Context, itemID K, item M) (M, error) {
+	ctx, span := r.startSpan(ctx, "UpdateOne")
+	defer span.End()
+
+	if r.viewBacked {
+		return item, ErrViewNotWritable
+	}
+
+	if err := r.checkTenantAccess(ctx, itemID); err != nil {
+		return item, err
+	}
+
+	if err := r.assignTenant(ctx, item); err != nil {
+		return item, err
+	}
+
 	err := r.db.UpdateOne(ctx, itemID, item)
 	if err != nil {
-		return fmt.Errorf("failed to update one item: %w", err)
+		return item, fmt.Errorf("failed to update one item: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Updated one item", "item", item.GetID(), "table", r.tableName)
+
+	return item, nil
+}
+
+// UpdateOneFields sets the given columns, including zero values such as
+// false or "", and returns the item as stored. The ID and tenant columns
+// can't be changed.
+func (r *repository[M, K]) UpdateOneFields(ctx context.Context, itemID K, fields map[string]interface{}) (M, error) {
+	ctx, span := r.startSpan(ctx, "UpdateOneFields")
+	defer span.End()
+
+	var item M
+
+	if r.viewBacked {
+		return item, ErrViewNotWritable
+	}
+
+	for field := range fields {
+		if !isValidColumnName(field) || field == "id" || (r.tenantScoped && field == "tenant_id") {
+			return item, InvalidRequest(fmt.Errorf("field %q can't be updated", field))
+		}
+	}
+
+	if err := r.checkTenantAccess(ctx, itemID); err != nil {
+		return item, err
+	}
+
+	if err := r.db.UpdateFields(ctx, itemID, new(M), fields); err != nil {
+		return item, fmt.Errorf("failed to update item fields: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Debug("Updated item fields", "item", itemID, "table", r.tableName)
+
+	return r.FindOneByID(ForcePrimary(ctx), itemID, "")
+}
+
+// SaveAggregate saves item and the named child associations in one
+// transaction, inserting, updating, and deleting children to match item.
+func (r *repository[M, K]) SaveAggregate(ctx context.Context, item M, associations ...string) error {
+	ctx, span := r.startSpan(ctx, "SaveAggregate")
+	defer span.End()
+
+	if r.viewBacked {
+		return ErrViewNotWritable
+	}
+
+	var zeroID K
+	if item.GetID() != zeroID {
+		if err := r.checkTenantAccess(ctx, item.GetID()); err != nil {
+			return err
+		}
+	}
+
+	if err := r.assignTenant(ctx, item); err != nil {
+		return err
+	}
+
+	err := r.db.SaveAggregate(ctx, item, associations...)
+	if err != nil {
+		return fmt.Errorf("failed to save aggregate: %w", err)
 	}
 
-	r.logger.Debug("Updated one item", "item", item.GetID(), "table", r.tableName)
+	r.logger.WithContext(ctx).Debug("Saved aggregate", "item", item.GetID(), "table", r.tableName, "associations", associations)
 
 	return nil
 }
 
-func (r *repository[M]) DeleteOne(ctx context.Context, itemID uint) error {
-	item := new(M)
+func (r *repository[M, K]) DeleteOne(ctx context.Context, itemID K) error {
+	ctx, span := r.startSpan(ctx, "DeleteOne")
+	defer span.End()
+
+	if r.viewBacked {
+		return ErrViewNotWritable
+	}
+
+	if err := r.checkTenantAccess(ctx, itemID); err != nil {
+		return err
+	}
+
+	var err error
+
+	if len(r.cascadeDeletes) > 0 || len(r.cascadeNullifies) > 0 {
+		err = r.db.DeleteCascade(ctx, itemID, newModelPtr[M](), r.cascadeDeletes, r.cascadeNullifies)
+	} else {
+		err = r.db.DeleteOne(ctx, itemID, new(M))
+	}
 
-	err := r.db.DeleteOne(ctx, itemID, item)
 	if err != nil {
 		return fmt.Errorf("failed to delete one item: %w", err)
 	}
 
-	r.logger.Debug("Deleted one item", "item", itemID, "table", r.tableName)
+	r.logger.WithContext(ctx).Debug("Deleted one item", "item", itemID, "table", r.tableName)
 
 	return nil
 }
 
-func WithTableName[M Model](tableName string) RepositoryOption[M] {
-	return func(r *repository[M]) {
+func (r *repository[M, K]) startSpan(ctx context.Context, method string) (context.Context, Span) {
+	return StartSpan(ctx, "Repository."+method, Attr("mochi.model", r.modelName))
+}
+
+// scopeQuery prepends the tenant condition for tenant scoped models.
+func (r *repository[M, K]) scopeQuery(ctx context.Context, query string, args []interface{}) (string, []interface{}, error) {
+	if !r.tenantScoped {
+		return query, args, nil
+	}
+
+	tenantQuery, tenantArgs, err := tenantScope(ctx, r.column("tenant_id"))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to scope query to tenant: %w", err)
+	}
+
+	if tenantQuery == "" {
+		return query, args, nil
+	}
+
+	return And(Raw(tenantQuery, tenantArgs...), Raw(query, args...)).Build()
+}
+
+// assignTenant stamps the context tenant onto tenant scoped items so writes
+// can't target another tenant.
+func (r *repository[M, K]) assignTenant(ctx context.Context, item M) error {
+	if !r.tenantScoped || isCrossTenantAllowed(ctx) {
+		return nil
+	}
+
+	tenantID, ok := TenantFromCtx(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+
+	any(item).(TenantModel).SetTenantID(tenantID)
+
+	return nil
+}
+
+// checkTenantAccess makes sure an item written by ID belongs to the context
+// tenant.
+func (r *repository[M, K]) checkTenantAccess(ctx context.Context, itemID K) error {
+	if !r.tenantScoped || isCrossTenantAllowed(ctx) {
+		return nil
+	}
+
+	_, err := r.FindOneByID(ctx, itemID, "")
+
+	return err
+}
+
+// filterQuery appends the filters set for M with WithFilters.
+func (r *repository[M, K]) filterQuery(ctx context.Context, query string, args []interface{}) (string, []interface{}, error) {
+	filters := FiltersFromCtx[M](ctx)
+	if len(filters) == 0 {
+		return query, args, nil
+	}
+
+	filterCondition, filterArgs, err := filterQuery(filters, r.column, r.schema(), r.db.Dialect())
+	if err != nil {
+		return "", nil, NewStatusError(http.StatusBadRequest, err)
+	}
+
+	return And(Raw(query, args...), Raw(filterCondition, filterArgs...)).Build()
+}
+
+// schema parses M with the default naming strategy, returning nil when the
+// model can't be parsed.
+func (r *repository[M, K]) schema() *schema.Schema {
+	var model M
+
+	modelSchema, err := schema.Parse(model, &r.schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil
+	}
+
+	return modelSchema
+}
+
+// orderedContext resolves the sort for M, falling back to the repository
+// default, into the order clause FindMany applies.
+func (r *repository[M, K]) orderedContext(ctx context.Context) (context.Context, error) {
+	keys := SortFromCtx[M](ctx)
+	if len(keys) == 0 {
+		keys = r.defaultSort
+	}
+
+	if len(keys) == 0 {
+		return ctx, nil
+	}
+
+	clause, err := orderClause(keys, r.column, r.db.Dialect())
+	if err != nil {
+		return nil, NewStatusError(http.StatusBadRequest, err)
+	}
+
+	return withOrderClause(ctx, clause), nil
+}
+
+// preloads returns the associations set with WithPreloads, falling back to
+// the repository's preload tables.
+func (r *repository[M, K]) preloads(ctx context.Context) []string {
+	if preloads, ok := ctx.Value(preloadsKey).([]string); ok {
+		return preloads
+	}
+
+	return r.preloadTables
+}
+
+// column qualifies a column name with the table name when one is set.
+func (r *repository[M, K]) column(name string) string {
+	if r.tableName == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s.%s", r.tableName, name)
+}
+
+// newModelPtr returns a pointer to an empty M, whether M is a struct or a
+// pointer to one, for use as a query destination.
+func newModelPtr[M any]() interface{} {
+	modelType := reflect.TypeOf((*M)(nil)).Elem()
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+
+	return reflect.New(modelType).Interface()
+}
+
+func isValidColumnName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, ch := range name {
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+		isDigit := ch >= '0' && ch <= '9'
+
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithMaxResults caps the rows list queries return, truncating larger
+// results. A negative limit disables the cap.
+func WithMaxResults[M Model[K], K comparable](limit int) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
+		r.maxResults = limit
+	}
+}
+
+// WithStrictMaxResults fails list queries over the cap with
+// ErrTooManyResults instead of truncating them.
+func WithStrictMaxResults[M Model[K], K comparable]() RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
+		r.strictMax = true
+	}
+}
+
+func WithTableName[M Model[K], K comparable](tableName string) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
 		r.tableName = tableName
 	}
 }
 
-func WithJoinTables[M Model](joinTables ...string) RepositoryOption[M] {
-	return func(r *repository[M]) {
+func WithJoinTables[M Model[K], K comparable](joinTables ...string) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
 		r.joinTables = joinTables
 	}
 }
 
-func WithPreloadTables[M Model](preloadTables ...string) RepositoryOption[M] {
-	return func(r *repository[M]) {
+func WithPreloadTables[M Model[K], K comparable](preloadTables ...string) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
 		r.preloadTables = preloadTables
 	}
 }
+
+type preloadsContextKey int
+
+const (
+	preloadsKey preloadsContextKey = iota
+)
+
+// WithPreloads overrides the preload tables of repository reads made with
+// the returned context, such as loading more associations for a detail
+// view. No preloads disables them.
+func WithPreloads(ctx context.Context, preloads ...string) context.Context {
+	if preloads == nil {
+		preloads = []string{}
+	}
+
+	return context.WithValue(ctx, preloadsKey, preloads)
+}
+
+// WithCascadeDelete deletes the named child associations, such as "Tasks"
+// or "Comments", together with the item. AutoMigrate doesn't create
+// ON DELETE constraints, so without it children are left orphaned.
+func WithCascadeDelete[M Model[K], K comparable](associations ...string) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
+		r.cascadeDeletes = append(r.cascadeDeletes, associations...)
+	}
+}
+
+// WithCascadeNullify detaches the named child associations when the item is
+// deleted, keeping the children.
+func WithCascadeNullify[M Model[K], K comparable](associations ...string) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
+		r.cascadeNullifies = append(r.cascadeNullifies, associations...)
+	}
+}
+
+// WithDefaultSort orders lists when the caller doesn't set a sort with
+// WithSort.
+func WithDefaultSort[M Model[K], K comparable](keys ...SortKey) RepositoryOption[M, K] {
+	return func(r *repository[M, K]) {
+		r.defaultSort = keys
+	}
+}
@@ -1,10 +1,28 @@
 package mochi
 
 import (
+	"context"
+
 	"github.com/go-chi/render"
 )
 
-type Resource interface {
-	Model
+type Resource[K comparable] interface {
+	Model[K]
 	ToDTO() render.Renderer
 }
+
+// ContextResource is implemented by resources whose DTO depends on the
+// request, such as the viewing user, locale, or feature flags. Controllers
+// prefer ToDTOCtx over ToDTO when it is available.
+type ContextResource interface {
+	ToDTOCtx(ctx context.Context) render.Renderer
+}
+
+// toDTO renders item with ToDTOCtx when it implements ContextResource.
+func toDTO[K comparable](ctx context.Context, item Resource[K]) render.Renderer {
+	if ctxItem, ok := item.(ContextResource); ok {
+		return ctxItem.ToDTOCtx(ctx)
+	}
+
+	return item.ToDTO()
+}
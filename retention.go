@@ -0,0 +1,391 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	RetentionCheckInterval  = time.Hour
+	DefaultRetentionBatch   = 1000
+	DefaultRetentionColumn  = "created_at"
+	RetentionDeletedMetric  = "mochi_retention_deleted_total"
+	RetentionFailuresMetric = "mochi_retention_failures_total"
+	RetentionDurationMetric = "mochi_retention_duration_seconds"
+	RetentionBatchTimeout   = 30 * time.Second
+)
+
+// RetentionModel is implemented by models whose old rows are purged, such
+// as logs kept for 90 days.
+type RetentionModel interface {
+	RetentionPolicy() RetentionPolicy
+}
+
+// RetentionPolicy deletes rows whose Column, created_at by default, is
+// older than MaxAge and which match the optional Query. Rows are deleted
+// BatchSize at a time so no statement holds locks for long. Deletes are
+// permanent, soft deleted models included.
+type RetentionPolicy struct {
+	MaxAge    time.Duration
+	Column    string
+	Query     string
+	Args      []interface{}
+	BatchSize int
+}
+
+// RetentionReport is what a retention run deleted, or for dry runs would
+// delete, from one table.
+type RetentionReport struct {
+	Table    string    `json:"table"`
+	Cutoff   time.Time `json:"cutoff"`
+	Matched  int64     `json:"matched"`
+	Deleted  int64     `json:"deleted"`
+	DryRun   bool      `json:"dry_run"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (rep *RetentionReport) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// RetentionService applies the retention policies of the models in the
+// ModelList every RetentionCheckInterval. GetRouter serves admin dry-run
+// reports at GET / and manual runs at POST /run.
+type RetentionService interface {
+	Run(ctx context.Context) ([]RetentionReport, error)
+	DryRun(ctx context.Context) ([]RetentionReport, error)
+
+	GetRouter() *chi.Mux
+}
+
+type RetentionServiceParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Auth      AuthService
+	DB        DBService
+	Logger    LoggerService
+	Metrics   MetricsService
+	Models    ModelList
+	Shutdown  *Shutdown `optional:"true"`
+}
+
+type RetentionServiceResult struct {
+	fx.Out
+
+	RetentionService RetentionService
+}
+
+type retentionTarget struct {
+	table  string
+	model  reflect.Type
+	policy RetentionPolicy
+}
+
+type retentionService struct {
+	db      DBService
+	logger  LoggerService
+	metrics MetricsService
+	targets []retentionTarget
+	Router  *chi.Mux
+
+	running sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRetentionService(params RetentionServiceParams) (RetentionServiceResult, error) {
+	srv := &retentionService{
+		db:      params.DB,
+		logger:  params.Logger,
+		metrics: params.Metrics,
+	}
+
+	var schemaCache sync.Map
+
+	for _, model := range params.Models {
+		retained, ok := model.(RetentionModel)
+		if !ok {
+			continue
+		}
+
+		policy := retained.RetentionPolicy()
+		if policy.MaxAge <= 0 {
+			return RetentionServiceResult{}, fmt.Errorf("retention policy of %T needs a positive max age", model)
+		}
+
+		if policy.Column == "" {
+			policy.Column = DefaultRetentionColumn
+		}
+
+		if !isValidColumnName(policy.Column) {
+			return RetentionServiceResult{}, fmt.Errorf("invalid retention column %q on %T", policy.Column, model)
+		}
+
+		if policy.BatchSize <= 0 {
+			policy.BatchSize = DefaultRetentionBatch
+		}
+
+		modelSchema, err := schema.Parse(model, &schemaCache, schema.NamingStrategy{})
+		if err != nil {
+			return RetentionServiceResult{}, fmt.Errorf("failed to parse retention model %T: %w", model, err)
+		}
+
+		srv.targets = append(srv.targets, retentionTarget{
+			table:  modelSchema.Table,
+			model:  modelSchema.ModelType,
+			policy: policy,
+		})
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Get("/", srv.handleDryRun)
+	srv.Router.Post("/run", srv.handleRun)
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			srv.stop = make(chan struct{})
+			srv.done = make(chan struct{})
+
+			go srv.run()
+
+			return nil
+		},
+	})
+
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "retention",
+		Stage: StageWorkers,
+		Stop: func(ctx context.Context) error {
+			close(srv.stop)
+
+			select {
+			case <-srv.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+
+	return RetentionServiceResult{RetentionService: srv}, nil
+}
+
+// Run deletes expired rows from every table. A failing table doesn't stop
+// the others; the first error is returned with all reports.
+func (srv *retentionService) Run(ctx context.Context) ([]RetentionReport, error) {
+	if srv.db.IsReadOnly() {
+		return nil, ErrReadOnly
+	}
+
+	return srv.apply(ctx, false)
+}
+
+// DryRun counts the rows Run would delete.
+func (srv *retentionService) DryRun(ctx context.Context) ([]RetentionReport, error) {
+	return srv.apply(ctx, true)
+}
+
+func (srv *retentionService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *retentionService) apply(ctx context.Context, dryRun bool) ([]RetentionReport, error) {
+	if !dryRun {
+		srv.running.Lock()
+		defer srv.running.Unlock()
+	}
+
+	reports := make([]RetentionReport, 0, len(srv.targets))
+
+	var firstErr error
+
+	for _, target := range srv.targets {
+		report, err := srv.applyTarget(ctx, target, dryRun)
+		if err != nil {
+			report.Error = err.Error()
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, firstErr
+}
+
+func (srv *retentionService) applyTarget(ctx context.Context, target retentionTarget, dryRun bool) (RetentionReport, error) {
+	started := time.Now()
+	cutoff := started.Add(-target.policy.MaxAge)
+	labels := MetricLabels{"table": target.table}
+
+	report := RetentionReport{Table: target.table, Cutoff: cutoff, DryRun: dryRun}
+
+	defer func() {
+		report.Duration = time.Since(started).String()
+	}()
+
+	matched, err := srv.countExpired(ctx, target, cutoff)
+	if err != nil {
+		srv.metrics.IncCounter(RetentionFailuresMetric, 1, labels)
+		return report, fmt.Errorf("failed to count expired %s: %w", target.table, err)
+	}
+
+	report.Matched = matched
+
+	if dryRun || matched == 0 {
+		return report, nil
+	}
+
+	for {
+		deleted, err := srv.deleteBatch(ctx, target, cutoff)
+		report.Deleted += deleted
+		srv.metrics.IncCounter(RetentionDeletedMetric, float64(deleted), labels)
+
+		if err != nil {
+			srv.metrics.IncCounter(RetentionFailuresMetric, 1, labels)
+			srv.logger.Error("Failed to apply retention", "table", target.table, "deleted", report.Deleted, "error", err)
+
+			return report, fmt.Errorf("failed to delete expired %s: %w", target.table, err)
+		}
+
+		if deleted < int64(target.policy.BatchSize) {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+
+	srv.metrics.ObserveHistogram(RetentionDurationMetric, time.Since(started).Seconds(), labels)
+	srv.logger.Info("Applied retention", "table", target.table, "deleted", report.Deleted, "cutoff", cutoff)
+
+	return report, nil
+}
+
+// expired selects the target's expired rows, soft deleted ones included.
+func (srv *retentionService) expired(sesh *gorm.DB, target retentionTarget, cutoff time.Time) *gorm.DB {
+	query := sesh.Unscoped().
+		Model(reflect.New(target.model).Interface()).
+		Where(fmt.Sprintf("%s < ?", target.policy.Column), cutoff)
+
+	if target.policy.Query != "" {
+		query = query.Where(target.policy.Query, target.policy.Args...)
+	}
+
+	return query
+}
+
+func (srv *retentionService) countExpired(ctx context.Context, target retentionTarget, cutoff time.Time) (int64, error) {
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	var count int64
+	err := srv.expired(sesh, target, cutoff).Count(&count).Error
+
+	return count, err
+}
+
+// deleteBatch deletes up to BatchSize expired rows. IDs are selected first
+// since not every database supports LIMIT in DELETE or IN subqueries.
+func (srv *retentionService) deleteBatch(ctx context.Context, target retentionTarget, cutoff time.Time) (int64, error) {
+	sesh, cancel := srv.db.GetSession(WithQueryTimeout(ctx, RetentionBatchTimeout))
+	defer cancel()
+
+	var ids []interface{}
+
+	err := srv.expired(sesh, target, cutoff).
+		Limit(target.policy.BatchSize).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	deleteResult := sesh.Unscoped().Where("id IN ?", ids).Delete(reflect.New(target.model).Interface())
+
+	return deleteResult.RowsAffected, deleteResult.Error
+}
+
+func (srv *retentionService) run() {
+	defer close(srv.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-srv.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(RetentionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-srv.stop:
+			return
+		case <-ticker.C:
+			if srv.db.IsReadOnly() {
+				continue
+			}
+
+			if _, err := srv.Run(ctx); err != nil {
+				srv.logger.Error("Retention run failed", "error", err)
+			}
+		}
+	}
+}
+
+func (srv *retentionService) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	reports, err := srv.DryRun(r.Context())
+	if err != nil {
+		srv.logger.Error("Failed to report retention", "error", err)
+	}
+
+	srv.renderReports(w, r, reports)
+}
+
+func (srv *retentionService) handleRun(w http.ResponseWriter, r *http.Request) {
+	reports, err := srv.Run(r.Context())
+	if err != nil && reports == nil {
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	if err != nil {
+		srv.logger.Error("Retention run failed", "error", err)
+	}
+
+	srv.renderReports(w, r, reports)
+}
+
+func (srv *retentionService) renderReports(w http.ResponseWriter, r *http.Request, reports []RetentionReport) {
+	respList := make([]render.Renderer, 0, len(reports))
+	for i := range reports {
+		respList = append(respList, &reports[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
@@ -0,0 +1,30 @@
+package mochi
+
+import (
+	"context"
+)
+
+// SearchService indexes documents and returns the IDs matching a query.
+type SearchService interface {
+	Index(ctx context.Context, index, id string, document interface{}) error
+	Delete(ctx context.Context, index, id string) error
+	Search(ctx context.Context, index, query string, limit int) ([]string, error)
+}
+
+type noopSearchService struct{}
+
+func NewNoopSearchService() SearchService {
+	return noopSearchService{}
+}
+
+func (noopSearchService) Index(ctx context.Context, index, id string, document interface{}) error {
+	return nil
+}
+
+func (noopSearchService) Delete(ctx context.Context, index, id string) error {
+	return nil
+}
+
+func (noopSearchService) Search(ctx context.Context, index, query string, limit int) ([]string, error) {
+	return []string{}, nil
+}
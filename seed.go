@@ -0,0 +1,228 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"go.uber.org/fx"
+)
+
+const (
+	SeedsGroup = `group:"seeds"`
+
+	// SeedOnStartEnvName overrides whether seeds run when the app starts;
+	// by default they do in development and test.
+	SeedOnStartEnvName = "DB_SEED_ON_START"
+)
+
+// Seeder inserts seed data. Seeders run on every start, so they must be
+// idempotent, for example by using FirstOrCreate.
+type Seeder interface {
+	Seed(ctx context.Context, db DBService) error
+}
+
+type SeederFunc func(ctx context.Context, db DBService) error
+
+func (f SeederFunc) Seed(ctx context.Context, db DBService) error {
+	return f(ctx, db)
+}
+
+// Seed registers a seeder. Seeds run by ascending Order after migrations,
+// and only in Envs, which defaults to development and test. Reference data
+// needed everywhere should list every environment explicitly.
+type Seed struct {
+	Name   string
+	Order  int
+	Envs   []AppEnv
+	Seeder Seeder
+}
+
+func (s Seed) allowedIn(env AppEnv) bool {
+	if len(s.Envs) == 0 {
+		return env.IsDevelopment()
+	}
+
+	return slices.Contains(s.Envs, env)
+}
+
+// AsSeed annotates a constructor returning a Seed so it is picked up by the
+// SeedRunner.
+func AsSeed(constructor interface{}) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(SeedsGroup)))
+}
+
+// SeedRunner runs the registered seeds, on start and on demand. Add
+// SeedOpts to the app to enable it.
+type SeedRunner interface {
+	Run(ctx context.Context) error
+	RunSeed(ctx context.Context, name string) error
+}
+
+type SeedRunnerParams struct {
+	fx.In
+
+	DB        DBService
+	Env       AppEnv
+	Lifecycle fx.Lifecycle
+	Logger    LoggerService
+	Seeds     []Seed      `group:"seeds"`
+	Config    *SeedConfig `optional:"true"`
+}
+
+// SeedConfig controls whether seeds run on start. Without it, OnStart
+// follows SeedOnStartEnvName, defaulting to true in development and test.
+type SeedConfig struct {
+	OnStart bool
+}
+
+type seedRunner struct {
+	db     DBService
+	env    AppEnv
+	logger LoggerService
+	seeds  []Seed
+	config *SeedConfig
+
+	ranOnStart bool
+}
+
+func NewSeedRunner(params SeedRunnerParams) (SeedRunner, error) {
+	seeds := append([]Seed(nil), params.Seeds...)
+	sort.SliceStable(seeds, func(i, j int) bool { return seeds[i].Order < seeds[j].Order })
+
+	seen := make(map[string]bool)
+	for _, seed := range seeds {
+		if seed.Name == "" || seed.Seeder == nil {
+			return nil, fmt.Errorf("seeds need a name and a seeder")
+		}
+
+		if seen[seed.Name] {
+			return nil, fmt.Errorf("duplicate seed %q", seed.Name)
+		}
+
+		seen[seed.Name] = true
+	}
+
+	runner := &seedRunner{
+		db:     params.DB,
+		env:    params.Env,
+		logger: params.Logger,
+		seeds:  seeds,
+		config: params.Config,
+	}
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if !runner.seedOnStart() {
+				return nil
+			}
+
+			runner.ranOnStart = true
+
+			return runner.Run(ctx)
+		},
+	})
+
+	return runner, nil
+}
+
+// Run runs every seed allowed in the current environment.
+func (r *seedRunner) Run(ctx context.Context) error {
+	for _, seed := range r.seeds {
+		if !seed.allowedIn(r.env) {
+			r.logger.Debug("Skipping seed outside its environments", "seed", seed.Name, "env", r.env)
+			continue
+		}
+
+		if err := r.run(ctx, seed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunSeed runs a single seed by name, still subject to its environments.
+func (r *seedRunner) RunSeed(ctx context.Context, name string) error {
+	for _, seed := range r.seeds {
+		if seed.Name != name {
+			continue
+		}
+
+		if !seed.allowedIn(r.env) {
+			return fmt.Errorf("seed %q is not enabled in %s", name, r.env)
+		}
+
+		return r.run(ctx, seed)
+	}
+
+	return fmt.Errorf("%w: seed %q", ErrRecordNotFound, name)
+}
+
+func (r *seedRunner) run(ctx context.Context, seed Seed) error {
+	if r.db.IsReadOnly() {
+		return ErrReadOnly
+	}
+
+	r.logger.Info("Running seed", "seed", seed.Name)
+
+	if err := seed.Seeder.Seed(ctx, r.db); err != nil {
+		return fmt.Errorf("seed %s failed: %w", seed.Name, err)
+	}
+
+	return nil
+}
+
+func (r *seedRunner) seedOnStart() bool {
+	if r.config != nil {
+		return r.config.OnStart
+	}
+
+	if value := os.Getenv(SeedOnStartEnvName); value != "" {
+		return value == "true"
+	}
+
+	return r.env.IsDevelopment()
+}
+
+// SeedOpts provides the SeedRunner and makes sure its start hook runs.
+func SeedOpts() fx.Option {
+	return fx.Options(
+		fx.Provide(NewSeedRunner),
+		fx.Invoke(func(SeedRunner) {}),
+	)
+}
+
+// RunSeeds builds the app from opts without a server, runs its seeds or
+// only the named ones, and shuts it down. opts must include SeedOpts. It
+// backs seed commands such as `go run ./cmd/seed admin_user`.
+func RunSeeds(ctx context.Context, names []string, opts ...fx.Option) error {
+	var runner SeedRunner
+
+	allOpts := append(BuildAppOpts(), opts...)
+	allOpts = append(allOpts, fx.Populate(&runner))
+
+	app := fx.New(allOpts...)
+	if err := app.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start app: %w", err)
+	}
+	defer app.Stop(context.Background())
+
+	if len(names) == 0 {
+		if r, ok := runner.(*seedRunner); ok && r.ranOnStart {
+			return nil
+		}
+
+		return runner.Run(ctx)
+	}
+
+	for _, name := range names {
+		if err := runner.RunSeed(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,37 +2,77 @@ package mochi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 )
 
 type ServiceQuery struct {
 	Filter string
 	Args   []interface{}
+
+	err error
+}
+
+// NewServiceQuery builds a ServiceQuery from a Cond. An invalid Cond is
+// reported by every call using the query.
+func NewServiceQuery(cond Cond) *ServiceQuery {
+	filter, args, err := cond.Build()
+
+	return &ServiceQuery{Filter: filter, Args: args, err: err}
+}
+
+// ReadService serves the read path of a controller. Every Service is a
+// ReadService; a dedicated implementation backed by a view, cache, or search
+// index can be set per controller with WithReadService.
+type ReadService[M Resource[K], K comparable] interface {
+	ListByUser(ctx context.Context, userID uint) ([]M, error)
+	GetOne(ctx context.Context, itemID K) (M, error)
+	GetOneByField(ctx context.Context, field string, value interface{}) (M, error)
+}
+
+// PublicReadService lists and checks items for anonymous requests on
+// controllers using WithPublicRead. The default Service implements it.
+type PublicReadService[M Resource[K], K comparable] interface {
+	ListPublic(ctx context.Context) ([]M, error)
+	// IsPublic reports whether anyone may read the item by ID.
+	IsPublic(ctx context.Context, itemID K) (bool, error)
+}
+
+// AdminReadService lists items across all users for admin controllers.
+// The default Service implements it.
+type AdminReadService[M Resource[K], K comparable] interface {
+	ListAll(ctx context.Context) ([]M, error)
 }
 
-type Service[M Resource] interface {
+type Service[M Resource[K], K comparable] interface {
 	ListByUser(ctx context.Context, userID uint) ([]M, error)
 	CreateOne(ctx context.Context, userID uint, item M) (M, error)
-	GetOne(ctx context.Context, itemID uint) (M, error)
-	UpdateOne(ctx context.Context, itemID uint, item M) (M, error)
-	DeleteOne(ctx context.Context, itemID uint) error
+	GetOne(ctx context.Context, itemID K) (M, error)
+	GetOneByField(ctx context.Context, field string, value interface{}) (M, error)
+	UpdateOne(ctx context.Context, itemID K, item M) (M, error)
+	UpdateOneFields(ctx context.Context, itemID K, fields map[string]interface{}) (M, error)
+	DeleteOne(ctx context.Context, itemID K) error
 }
 
-type service[M Resource] struct {
-	repo Repository[M]
+type service[M Resource[K], K comparable] struct {
+	repo      Repository[M, K]
+	modelName string
 
-	listQuery *ServiceQuery
-	getQuery  *ServiceQuery
+	listQuery       *ServiceQuery
+	getQuery        *ServiceQuery
+	publicListQuery *ServiceQuery
 }
 
-type ServiceOption[M Resource] func(*service[M])
+type ServiceOption[M Resource[K], K comparable] func(*service[M, K])
 
-func NewService[M Resource](
-	repo Repository[M],
-	opts ...ServiceOption[M],
-) Service[M] {
-	svc := &service[M]{
-		repo: repo,
+func NewService[M Resource[K], K comparable](
+	repo Repository[M, K],
+	opts ...ServiceOption[M, K],
+) Service[M, K] {
+	svc := &service[M, K]{
+		repo:      repo,
+		modelName: modelTypeName[M](),
 	}
 
 	for _, opt := range opts {
@@ -50,7 +90,14 @@ func NewService[M Resource](
 	return svc
 }
 
-func (s *service[M]) ListByUser(ctx context.Context, userID uint) ([]M, error) {
+func (s *service[M, K]) ListByUser(ctx context.Context, userID uint) ([]M, error) {
+	ctx, span := s.startSpan(ctx, "ListByUser")
+	defer span.End()
+
+	if s.listQuery.err != nil {
+		return nil, s.listQuery.err
+	}
+
 	items, err := s.repo.FindManyByUser(ctx, userID, s.listQuery.Filter, s.listQuery.Args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list user items: %w", err)
@@ -59,7 +106,76 @@ func (s *service[M]) ListByUser(ctx context.Context, userID uint) ([]M, error) {
 	return items, nil
 }
 
-func (s *service[M]) CreateOne(ctx context.Context, userID uint, item M) (M, error) {
+// ListPublic lists items across all users matching the public list query.
+// Without WithPublicListQuery nothing is public and anonymous requests are
+// refused.
+func (s *service[M, K]) ListPublic(ctx context.Context) ([]M, error) {
+	ctx, span := s.startSpan(ctx, "ListPublic")
+	defer span.End()
+
+	if err := s.checkPublicQuery(); err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.FindMany(ctx, s.publicListQuery.Filter, s.publicListQuery.Args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list public items: %w", err)
+	}
+
+	return items, nil
+}
+
+// IsPublic reports whether the item matches the public list query, so
+// anonymous detail reads see the same items as anonymous lists.
+func (s *service[M, K]) IsPublic(ctx context.Context, itemID K) (bool, error) {
+	ctx, span := s.startSpan(ctx, "IsPublic")
+	defer span.End()
+
+	if err := s.checkPublicQuery(); err != nil {
+		return false, err
+	}
+
+	_, err := s.repo.FindOneByID(ctx, itemID, s.publicListQuery.Filter, s.publicListQuery.Args...)
+	if errors.Is(err, ErrRecordNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to check public item: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *service[M, K]) checkPublicQuery() error {
+	if s.publicListQuery == nil {
+		return NewStatusError(http.StatusUnauthorized, fmt.Errorf("%s has no public list query", s.modelName))
+	}
+
+	return s.publicListQuery.err
+}
+
+// ListAll lists items of every user matching the list query.
+func (s *service[M, K]) ListAll(ctx context.Context) ([]M, error) {
+	ctx, span := s.startSpan(ctx, "ListAll")
+	defer span.End()
+
+	if s.listQuery.err != nil {
+		return nil, s.listQuery.err
+	}
+
+	items, err := s.repo.FindMany(ctx, s.listQuery.Filter, s.listQuery.Args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all items: %w", err)
+	}
+
+	return items, nil
+}
+
+func (s *service[M, K]) CreateOne(ctx context.Context, userID uint, item M) (M, error) {
+	ctx, span := s.startSpan(ctx, "CreateOne")
+	defer span.End()
+
 	err := s.repo.CreateOne(ctx, item)
 	if err != nil {
 		return item, fmt.Errorf("failed to create user task: %w", err)
@@ -68,7 +184,15 @@ func (s *service[M]) CreateOne(ctx context.Context, userID uint, item M) (M, err
 	return item, nil
 }
 
-func (s *service[M]) GetOne(ctx context.Context, itemID uint) (M, error) {
+func (s *service[M, K]) GetOne(ctx context.Context, itemID K) (M, error) {
+	ctx, span := s.startSpan(ctx, "GetOne")
+	defer span.End()
+
+	if s.getQuery.err != nil {
+		var item M
+		return item, s.getQuery.err
+	}
+
 	item, err := s.repo.FindOneByID(ctx, itemID, s.getQuery.Filter, s.getQuery.Args...)
 	if err != nil {
 		return item, fmt.Errorf("failed to get item: %w", err)
@@ -77,16 +201,51 @@ func (s *service[M]) GetOne(ctx context.Context, itemID uint) (M, error) {
 	return item, nil
 }
 
-func (s *service[M]) UpdateOne(ctx context.Context, itemID uint, item M) (M, error) {
-	err := s.repo.UpdateOne(ctx, itemID, item)
+func (s *service[M, K]) GetOneByField(ctx context.Context, field string, value interface{}) (M, error) {
+	ctx, span := s.startSpan(ctx, "GetOneByField")
+	defer span.End()
+
+	if s.getQuery.err != nil {
+		var item M
+		return item, s.getQuery.err
+	}
+
+	item, err := s.repo.FindOneByField(ctx, field, value, s.getQuery.Filter, s.getQuery.Args...)
+	if err != nil {
+		return item, fmt.Errorf("failed to get item by %s: %w", field, err)
+	}
+
+	return item, nil
+}
+
+func (s *service[M, K]) UpdateOne(ctx context.Context, itemID K, item M) (M, error) {
+	ctx, span := s.startSpan(ctx, "UpdateOne")
+	defer span.End()
+
+	updated, err := s.repo.UpdateOne(ctx, itemID, item)
 	if err != nil {
 		return item, fmt.Errorf("failed to update user task: %w", err)
 	}
 
+	return updated, nil
+}
+
+func (s *service[M, K]) UpdateOneFields(ctx context.Context, itemID K, fields map[string]interface{}) (M, error) {
+	ctx, span := s.startSpan(ctx, "UpdateOneFields")
+	defer span.End()
+
+	item, err := s.repo.UpdateOneFields(ctx, itemID, fields)
+	if err != nil {
+		return item, fmt.Errorf("failed to update item fields: %w", err)
+	}
+
 	return item, nil
 }
 
-func (s *service[M]) DeleteOne(ctx context.Context, itemID uint) error {
+func (s *service[M, K]) DeleteOne(ctx context.Context, itemID K) error {
+	ctx, span := s.startSpan(ctx, "DeleteOne")
+	defer span.End()
+
 	err := s.repo.DeleteOne(ctx, itemID)
 	if err != nil {
 		return fmt.Errorf("failed to delete user task: %w", err)
@@ -95,8 +254,12 @@ func (s *service[M]) DeleteOne(ctx context.Context, itemID uint) error {
 	return nil
 }
 
-func WithListQuery[M Resource](query string, args ...interface{}) ServiceOption[M] {
-	return func(s *service[M]) {
+func (s *service[M, K]) startSpan(ctx context.Context, method string) (context.Context, Span) {
+	return StartSpan(ctx, "Service."+method, Attr("mochi.model", s.modelName))
+}
+
+func WithListQuery[M Resource[K], K comparable](query string, args ...interface{}) ServiceOption[M, K] {
+	return func(s *service[M, K]) {
 		s.listQuery = &ServiceQuery{
 			Filter: query,
 			Args:   args,
@@ -104,11 +267,44 @@ func WithListQuery[M Resource](query string, args ...interface{}) ServiceOption[
 	}
 }
 
-func WithGetQuery[M Resource](query string, args ...interface{}) ServiceOption[M] {
-	return func(s *service[M]) {
+func WithGetQuery[M Resource[K], K comparable](query string, args ...interface{}) ServiceOption[M, K] {
+	return func(s *service[M, K]) {
 		s.getQuery = &ServiceQuery{
 			Filter: query,
 			Args:   args,
 		}
 	}
 }
+
+// WithPublicListQuery restricts what anonymous requests can list, such as
+// "published = ?", true.
+func WithPublicListQuery[M Resource[K], K comparable](query string, args ...interface{}) ServiceOption[M, K] {
+	return func(s *service[M, K]) {
+		s.publicListQuery = &ServiceQuery{
+			Filter: query,
+			Args:   args,
+		}
+	}
+}
+
+// WithListCond is WithListQuery for a Cond, such as
+// And(Eq("archived", false), Gt("priority", 2)).
+func WithListCond[M Resource[K], K comparable](cond Cond) ServiceOption[M, K] {
+	return func(s *service[M, K]) {
+		s.listQuery = NewServiceQuery(cond)
+	}
+}
+
+// WithGetCond is WithGetQuery for a Cond.
+func WithGetCond[M Resource[K], K comparable](cond Cond) ServiceOption[M, K] {
+	return func(s *service[M, K]) {
+		s.getQuery = NewServiceQuery(cond)
+	}
+}
+
+// WithPublicListCond is WithPublicListQuery for a Cond.
+func WithPublicListCond[M Resource[K], K comparable](cond Cond) ServiceOption[M, K] {
+	return func(s *service[M, K]) {
+		s.publicListQuery = NewServiceQuery(cond)
+	}
+}
@@ -0,0 +1,313 @@
+package mochi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+)
+
+const (
+	PrincipalTypeServiceAccount = "service_account"
+	ServiceAccountTokenTTL      = time.Hour
+
+	GrantTypeClientCredentials = "client_credentials"
+
+	// OAuth 2.0 token endpoint error codes (RFC 6749 section 5.2).
+	OAuthErrorInvalidRequest     = "invalid_request"
+	OAuthErrorInvalidClient      = "invalid_client"
+	OAuthErrorInvalidScope       = "invalid_scope"
+	OAuthErrorUnsupportedGrant   = "unsupported_grant_type"
+	serviceAccountClientIDPrefix = "sa"
+)
+
+// ServiceAccount is a non-human principal with long-lived client
+// credentials that are exchanged for short-lived scoped tokens. Add it to
+// the app's ModelList when using the ServiceAccountService.
+type ServiceAccount struct {
+	ID         uint   `gorm:"primaryKey"`
+	Name       string `gorm:"uniqueIndex"`
+	ClientID   string `gorm:"uniqueIndex"`
+	SecretHash string
+	Scopes     string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (a *ServiceAccount) GetID() uint {
+	return a.ID
+}
+
+// AllowedScopes returns the scopes the account may request.
+func (a *ServiceAccount) AllowedScopes() []string {
+	return strings.Fields(a.Scopes)
+}
+
+// ServiceAccountPrincipal is the User stored in the request context for
+// service account tokens. GetID returns 0 so service accounts never match
+// user owned records; use ServiceAccountID to identify the account.
+type ServiceAccountPrincipal struct {
+	AccountID uint
+	Scopes    []string
+}
+
+func (p *ServiceAccountPrincipal) IsAdmin() bool {
+	return false
+}
+
+func (p *ServiceAccountPrincipal) GetID() uint {
+	return 0
+}
+
+func (p *ServiceAccountPrincipal) ServiceAccountID() uint {
+	return p.AccountID
+}
+
+type ServiceAccountService interface {
+	CreateServiceAccount(ctx context.Context, name string, scopes []string) (*ServiceAccount, string, error)
+	RotateSecret(ctx context.Context, accountID uint) (string, error)
+	RevokeServiceAccount(ctx context.Context, accountID uint) error
+	ListServiceAccounts(ctx context.Context) ([]*ServiceAccount, error)
+	ExchangeCredentials(ctx context.Context, clientID, clientSecret string, scopes []string) (string, []string, error)
+	TokenHandler() http.HandlerFunc
+}
+
+type ServiceAccountServiceParams struct {
+	fx.In
+
+	Auth   AuthService
+	DB     DBService
+	Logger LoggerService
+}
+
+type ServiceAccountServiceResult struct {
+	fx.Out
+
+	ServiceAccountService ServiceAccountService
+}
+
+type serviceAccountService struct {
+	auth   AuthService
+	logger LoggerService
+	repo   Repository[*ServiceAccount, uint]
+}
+
+func NewServiceAccountService(params ServiceAccountServiceParams) (ServiceAccountServiceResult, error) {
+	srv := &serviceAccountService{
+		auth:   params.Auth,
+		logger: params.Logger,
+		repo:   NewRepository(params.DB, params.Logger, WithTableName[*ServiceAccount]("service_accounts")),
+	}
+
+	return ServiceAccountServiceResult{ServiceAccountService: srv}, nil
+}
+
+// CreateServiceAccount registers an account allowed to request scopes. The
+// client secret is only returned here; just its hash is stored.
+func (srv *serviceAccountService) CreateServiceAccount(
+	ctx context.Context,
+	name string,
+	scopes []string,
+) (*ServiceAccount, string, error) {
+	suffix, err := randomHex(8)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate client id: %w", err)
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	account := &ServiceAccount{
+		Name:       name,
+		ClientID:   fmt.Sprintf("%s_%s", serviceAccountClientIDPrefix, suffix),
+		SecretHash: hashAPIKey(secret),
+		Scopes:     strings.Join(scopes, " "),
+	}
+
+	if err := srv.repo.CreateOne(ctx, account); err != nil {
+		return nil, "", fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	srv.logger.Info("Created service account", "account", account.ID, "client_id", account.ClientID, "scopes", scopes)
+
+	return account, secret, nil
+}
+
+// RotateSecret replaces the client secret. Tokens already issued stay valid
+// until they expire.
+func (srv *serviceAccountService) RotateSecret(ctx context.Context, accountID uint) (string, error) {
+	if _, err := srv.repo.FindOneByID(ctx, accountID, "revoked_at IS NULL"); err != nil {
+		return "", fmt.Errorf("failed to find service account: %w", err)
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = srv.repo.UpdateOne(ctx, accountID, &ServiceAccount{ID: accountID, SecretHash: hashAPIKey(secret)})
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate service account secret: %w", err)
+	}
+
+	srv.logger.Info("Rotated service account secret", "account", accountID)
+
+	return secret, nil
+}
+
+func (srv *serviceAccountService) RevokeServiceAccount(ctx context.Context, accountID uint) error {
+	now := time.Now()
+
+	_, err := srv.repo.UpdateOne(ctx, accountID, &ServiceAccount{ID: accountID, RevokedAt: &now})
+	if err != nil {
+		return fmt.Errorf("failed to revoke service account: %w", err)
+	}
+
+	srv.logger.Info("Revoked service account", "account", accountID)
+
+	return nil
+}
+
+func (srv *serviceAccountService) ListServiceAccounts(ctx context.Context) ([]*ServiceAccount, error) {
+	accounts := []*ServiceAccount{}
+
+	err := srv.repo.Stream(ctx, "revoked_at IS NULL", func(account *ServiceAccount) error {
+		accounts = append(accounts, account)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// ExchangeCredentials issues a token for the account limited to the
+// requested scopes, or to all of its allowed scopes when none are
+// requested. It returns the granted scopes.
+func (srv *serviceAccountService) ExchangeCredentials(
+	ctx context.Context,
+	clientID string,
+	clientSecret string,
+	scopes []string,
+) (string, []string, error) {
+	account, err := srv.repo.FindOne(ctx, "client_id = ? AND revoked_at IS NULL", clientID)
+	if err != nil {
+		return "", nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("invalid client credentials"))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(account.SecretHash), []byte(hashAPIKey(clientSecret))) != 1 {
+		return "", nil, NewStatusError(http.StatusUnauthorized, fmt.Errorf("invalid client credentials"))
+	}
+
+	allowed := account.AllowedScopes()
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+
+	for _, scope := range scopes {
+		if !containsScope(allowed, scope) {
+			return "", nil, NewStatusError(http.StatusBadRequest, fmt.Errorf("scope %q is not allowed", scope))
+		}
+	}
+
+	token, err := srv.auth.IssueServiceAccountToken(&ServiceAccountPrincipal{AccountID: account.ID, Scopes: scopes})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to issue service account token: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := srv.repo.UpdateOne(ctx, account.ID, &ServiceAccount{ID: account.ID, LastUsedAt: &now}); err != nil {
+		srv.logger.Warn("Failed to record service account usage", "account", account.ID, "error", err)
+	}
+
+	return token, scopes, nil
+}
+
+// TokenHandler is an OAuth 2.0 token endpoint for the client credentials
+// grant. Credentials are read from HTTP Basic auth or the form body. Register
+// its path with AsFormContentPath so NewRouter accepts the form body.
+func (srv *serviceAccountService) TokenHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			renderOAuthError(w, r, http.StatusBadRequest, OAuthErrorInvalidRequest)
+			return
+		}
+
+		if r.PostForm.Get("grant_type") != GrantTypeClientCredentials {
+			renderOAuthError(w, r, http.StatusBadRequest, OAuthErrorUnsupportedGrant)
+			return
+		}
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+
+		if clientID == "" || clientSecret == "" {
+			renderOAuthError(w, r, http.StatusUnauthorized, OAuthErrorInvalidClient)
+			return
+		}
+
+		token, scopes, err := srv.ExchangeCredentials(r.Context(), clientID, clientSecret, strings.Fields(r.PostForm.Get("scope")))
+		if err != nil {
+			switch ErrorStatus(err) {
+			case http.StatusUnauthorized:
+				renderOAuthError(w, r, http.StatusUnauthorized, OAuthErrorInvalidClient)
+			case http.StatusBadRequest:
+				renderOAuthError(w, r, http.StatusBadRequest, OAuthErrorInvalidScope)
+			default:
+				srv.logger.Error("Failed to exchange client credentials", "error", err)
+				render.Render(w, r, ErrUnknown(err))
+			}
+
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		resp := NewTokenResponse(token)
+		resp.ExpiresIn = int(ServiceAccountTokenTTL.Seconds())
+		resp.Scope = strings.Join(scopes, " ")
+
+		render.Render(w, r, resp)
+	}
+}
+
+func renderOAuthError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	render.Render(w, r, &ErrResponse{
+		HTTPStatusCode: status,
+		StatusText:     http.StatusText(status) + ".",
+		ErrorText:      code,
+	})
+}
+
+func newClientSecret() (string, error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", fmt.Errorf("failed to generate client secret: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secretBytes), nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, candidate := range scopes {
+		if candidate == scope {
+			return true
+		}
+	}
+
+	return false
+}
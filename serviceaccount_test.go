@@ -0,0 +1,57 @@
+package mochi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/fx"
+)
+
+func TestTokenHandlerAcceptsForms(t *testing.T) {
+	var router *chi.Mux
+	var logger LoggerService
+
+	app := fx.New(
+		fx.NopLogger,
+		fx.Supply(EnvTest),
+		fx.Provide(NewLoggerService, NewRouter),
+		AsFormContentPath("/oauth/token"),
+		fx.Populate(&router, &logger),
+	)
+	if err := app.Err(); err != nil {
+		t.Fatalf("failed to build router: %v", err)
+	}
+
+	srv := &serviceAccountService{logger: logger}
+	router.Post("/oauth/token", srv.TokenHandler())
+
+	form := url.Values{"grant_type": {GrantTypeClientCredentials}}.Encode()
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		// no credentials are sent, so the handler rejects the client
+		{"token endpoint", "/oauth/token", http.StatusUnauthorized},
+		{"json route", HealthcheckPath, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
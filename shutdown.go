@@ -0,0 +1,194 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// ShutdownStage orders shutdown work. Stages run in order, so HTTP stops
+// accepting requests before workers drain, buffers flush, and connections
+// close.
+type ShutdownStage int
+
+const (
+	StageHTTP ShutdownStage = iota
+	StageWorkers
+	StageFlush
+	StageClose
+)
+
+func (s ShutdownStage) String() string {
+	switch s {
+	case StageHTTP:
+		return "http"
+	case StageWorkers:
+		return "workers"
+	case StageFlush:
+		return "flush"
+	case StageClose:
+		return "close"
+	default:
+		return fmt.Sprintf("stage(%d)", int(s))
+	}
+}
+
+// DefaultShutdownTimeouts fit within fx's default 15 second stop timeout.
+var DefaultShutdownTimeouts = map[ShutdownStage]time.Duration{
+	StageHTTP:    5 * time.Second,
+	StageWorkers: 4 * time.Second,
+	StageFlush:   3 * time.Second,
+	StageClose:   2 * time.Second,
+}
+
+// ShutdownHook is one piece of shutdown work. Timeout overrides the stage
+// timeout for this hook.
+type ShutdownHook struct {
+	Name    string
+	Stage   ShutdownStage
+	Timeout time.Duration
+	Stop    func(ctx context.Context) error
+}
+
+type ShutdownConfig struct {
+	StageTimeouts map[ShutdownStage]time.Duration
+}
+
+type ShutdownParams struct {
+	fx.In
+
+	Config    *ShutdownConfig `optional:"true"`
+	Lifecycle fx.Lifecycle
+	Logger    LoggerService
+}
+
+// Shutdown runs registered hooks stage by stage from a single fx OnStop
+// hook. Hooks within a stage run concurrently; a failing or slow hook is
+// logged and doesn't keep later stages from running.
+type Shutdown struct {
+	logger   LoggerService
+	timeouts map[ShutdownStage]time.Duration
+
+	mu    sync.Mutex
+	hooks []ShutdownHook
+}
+
+func NewShutdown(params ShutdownParams) *Shutdown {
+	shutdown := &Shutdown{
+		logger:   params.Logger,
+		timeouts: make(map[ShutdownStage]time.Duration),
+	}
+
+	for stage, timeout := range DefaultShutdownTimeouts {
+		shutdown.timeouts[stage] = timeout
+	}
+
+	if params.Config != nil {
+		for stage, timeout := range params.Config.StageTimeouts {
+			shutdown.timeouts[stage] = timeout
+		}
+	}
+
+	params.Lifecycle.Append(fx.Hook{OnStop: shutdown.Run})
+
+	return shutdown
+}
+
+func (s *Shutdown) Register(hook ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hooks = append(s.hooks, hook)
+}
+
+// Run executes every stage in order and returns the joined hook errors.
+func (s *Shutdown) Run(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := append([]ShutdownHook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].Stage < hooks[j].Stage })
+
+	var errs []error
+
+	for start := 0; start < len(hooks); {
+		end := start
+		for end < len(hooks) && hooks[end].Stage == hooks[start].Stage {
+			end++
+		}
+
+		errs = append(errs, s.runStage(ctx, hooks[start].Stage, hooks[start:end])...)
+		start = end
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Shutdown) runStage(ctx context.Context, stage ShutdownStage, hooks []ShutdownHook) []error {
+	s.logger.Info("Shutdown stage starting", "stage", stage, "hooks", len(hooks))
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(hooks))
+
+	for i, hook := range hooks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			errs[i] = s.runHook(ctx, hook)
+		}()
+	}
+
+	wg.Wait()
+
+	s.logger.Info("Shutdown stage finished", "stage", stage, "duration", time.Since(started))
+
+	return errs
+}
+
+func (s *Shutdown) runHook(ctx context.Context, hook ShutdownHook) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = s.timeouts[hook.Stage]
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+
+	if err := hook.Stop(ctx); err != nil {
+		s.logger.Error("Shutdown hook failed", "stage", hook.Stage, "hook", hook.Name, "duration", time.Since(started), "error", err)
+		return fmt.Errorf("%s: %w", hook.Name, err)
+	}
+
+	s.logger.Info("Shutdown hook finished", "stage", hook.Stage, "hook", hook.Name, "duration", time.Since(started))
+
+	return nil
+}
+
+// registerShutdown registers hook with shutdown when the app provides one,
+// falling back to a plain fx OnStop hook. Either way the hook only runs if
+// the app got far enough to start the hooks appended before it.
+func registerShutdown(lc fx.Lifecycle, shutdown *Shutdown, hook ShutdownHook) {
+	if shutdown == nil {
+		lc.Append(fx.Hook{OnStop: hook.Stop})
+		return
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			shutdown.Register(hook)
+			return nil
+		},
+	})
+}
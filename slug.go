@@ -0,0 +1,513 @@
+package mochi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DefaultSlugMaxLength = 80
+
+	slugHistoryKey = "mochi:slug_history"
+
+	// maxSlugSuffix leaves room for collision suffixes up to -999999.
+	maxSlugSuffix = 7
+)
+
+// SlugMode controls what happens to a slug after its row is created.
+type SlugMode int
+
+const (
+	// SlugImmutable sets the slug on create and ignores later writes to it,
+	// so URLs never change.
+	SlugImmutable SlugMode = iota
+	// SlugVersioned regenerates the slug when its source changes and
+	// records the old one in SlugHistory, where WithSlugHistory finds it.
+	SlugVersioned
+)
+
+// Slug generates Column from Source, such as slug from title. Collisions
+// get a numeric suffix, "title-2", unique among rows with the same Scope
+// values. Column should still have a unique index, which decides between
+// concurrent creates.
+type Slug struct {
+	Column    string
+	Source    string
+	Mode      SlugMode
+	MaxLength int
+	Scope     []string
+}
+
+// SluggedModel is implemented by models with generated slugs. A slug set
+// on create is kept, normalized and made unique, instead of generated.
+type SluggedModel interface {
+	Slugs() []Slug
+}
+
+// SlugHistory records a slug a row has had, so links to it keep working.
+// Add it to the ModelList of apps with versioned slugs.
+type SlugHistory struct {
+	ID        uint      `gorm:"primaryKey"`
+	Table     string    `gorm:"column:table_name;size:64;not null;uniqueIndex:idx_slug_history"`
+	Slug      string    `gorm:"size:255;not null;uniqueIndex:idx_slug_history"`
+	RecordID  string    `gorm:"size:64;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// slugTransliterations covers Latin letters that don't decompose into a
+// base letter and accents.
+var slugTransliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// Slugify lowercases s, strips accents, and joins the remaining runs of
+// letters and digits with hyphens: "Crème Brûlée!" becomes "creme-brulee".
+func Slugify(s string) string {
+	var b strings.Builder
+
+	hyphen := false
+
+	for _, r := range norm.NFKD.String(strings.ToLower(s)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		case slugTransliterations[r] != "":
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+
+			b.WriteString(slugTransliterations[r])
+			hyphen = false
+
+			continue
+		default:
+			hyphen = true
+			continue
+		}
+
+		if hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+
+		b.WriteRune(r)
+		hyphen = false
+	}
+
+	return b.String()
+}
+
+func truncateSlug(slug string, length int) string {
+	if len(slug) > length {
+		slug = slug[:length]
+	}
+
+	return strings.TrimRight(slug, "-")
+}
+
+func (s Slug) maxLength() int {
+	if s.MaxLength > maxSlugSuffix {
+		return s.MaxLength
+	}
+
+	return DefaultSlugMaxLength
+}
+
+// base slugifies value, falling back to a random slug for values without
+// letters or digits.
+func (s Slug) base(value string) string {
+	base := truncateSlug(Slugify(value), s.maxLength())
+	if base != "" {
+		return base
+	}
+
+	random := make([]byte, 4)
+	rand.Read(random)
+
+	return hex.EncodeToString(random)
+}
+
+type slugs struct {
+	byModel map[reflect.Type][]Slug
+}
+
+// registerSlugs installs the callbacks generating the slugs of models.
+func registerSlugs(db *gorm.DB, models ModelList) error {
+	sl := &slugs{byModel: make(map[reflect.Type][]Slug)}
+
+	versioned := false
+	hasHistory := false
+
+	for _, model := range models {
+		if _, ok := model.(*SlugHistory); ok {
+			hasHistory = true
+		}
+
+		slugged, ok := model.(SluggedModel)
+		if !ok {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse slugged model %T: %w", model, err)
+		}
+
+		for _, s := range slugged.Slugs() {
+			if err := checkIdentifiers(append([]string{s.Column, s.Source}, s.Scope...)...); err != nil {
+				return fmt.Errorf("invalid slug on %T: %w", model, err)
+			}
+
+			for _, column := range append([]string{s.Column, s.Source}, s.Scope...) {
+				if stmt.Schema.LookUpField(column) == nil {
+					return fmt.Errorf("slug column %s must be a field of %T", column, model)
+				}
+			}
+
+			versioned = versioned || s.Mode == SlugVersioned
+			sl.byModel[stmt.Schema.ModelType] = append(sl.byModel[stmt.Schema.ModelType], s)
+		}
+	}
+
+	if len(sl.byModel) == 0 {
+		return nil
+	}
+
+	if versioned && !hasHistory {
+		return fmt.Errorf("versioned slugs require SlugHistory in the ModelList")
+	}
+
+	err := db.Callback().Create().Before("gorm:create").Register("mochi:slug_create", sl.beforeCreate)
+	if err != nil {
+		return err
+	}
+
+	err = db.Callback().Update().Before("gorm:update").Register("mochi:slug_update", sl.beforeUpdate)
+	if err != nil {
+		return err
+	}
+
+	return db.Callback().Update().Before("gorm:after_update").Register("mochi:slug_history", sl.afterUpdate)
+}
+
+// beforeCreate fills in the slugs of new rows, keeping them unique within
+// the batch as well as the table.
+func (sl *slugs) beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+
+	for _, s := range sl.byModel[stmt.Schema.ModelType] {
+		column := stmt.Schema.LookUpField(s.Column)
+		source := stmt.Schema.LookUpField(s.Source)
+		pending := make(map[string]bool)
+
+		eachRow(stmt.ReflectValue, func(row reflect.Value) {
+			if db.Error != nil {
+				return
+			}
+
+			value, isZero := column.ValueOf(stmt.Context, row)
+			if isZero {
+				value, isZero = source.ValueOf(stmt.Context, row)
+				if isZero {
+					return
+				}
+			}
+
+			scope := slugScope(stmt, s, row)
+
+			slug, err := sl.unique(db, s, s.base(slugString(value)), scope, nil, pending)
+			if err != nil {
+				db.AddError(err)
+				return
+			}
+
+			pending[scope.key+slug] = true
+
+			if err := column.Set(stmt.Context, row, slug); err != nil {
+				db.AddError(fmt.Errorf("failed to set slug %s: %w", s.Column, err))
+			}
+		})
+	}
+}
+
+// beforeUpdate drops writes to immutable slugs and regenerates versioned
+// slugs of a single row whose source is updated.
+func (sl *slugs) beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+	changes := []SlugHistory{}
+
+	for _, s := range sl.byModel[stmt.Schema.ModelType] {
+		if s.Mode == SlugImmutable {
+			if updatesColumn(stmt, s.Column) {
+				stmt.Omits = append(stmt.Omits, s.Column)
+			}
+
+			continue
+		}
+
+		if updatesColumn(stmt, s.Column) || !updatesColumn(stmt, s.Source) {
+			continue
+		}
+
+		change, err := sl.regenerate(db, s)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	if len(changes) > 0 {
+		db.InstanceSet(slugHistoryKey, changes)
+	}
+}
+
+// regenerate sets a new slug from the updated source value. Updates
+// matching several rows keep their slugs, since they would all get the
+// same one.
+func (sl *slugs) regenerate(db *gorm.DB, s Slug) (*SlugHistory, error) {
+	stmt := db.Statement
+
+	rows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Model(reflect.New(stmt.Schema.ModelType).Interface()).
+		Clauses(statementConds(stmt)...).
+		Limit(2).
+		Find(rows.Interface()).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load row for slug %s: %w", s.Column, err)
+	}
+
+	if rows.Elem().Len() != 1 {
+		return nil, nil
+	}
+
+	row := rows.Elem().Index(0)
+
+	value, ok := updatedValue(stmt, s.Source)
+	if !ok {
+		return nil, nil
+	}
+
+	base := s.base(slugString(value))
+	current, _ := stmt.Schema.LookUpField(s.Column).ValueOf(stmt.Context, row)
+	currentSlug := slugString(current)
+
+	if currentSlug == base || isSuffixedSlug(currentSlug, base) {
+		return nil, nil
+	}
+
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, row)
+
+	slug, err := sl.unique(db, s, base, slugScope(stmt, s, row), id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.SetColumn(s.Column, slug)
+	if len(stmt.Selects) > 0 {
+		stmt.Selects = append(stmt.Selects, s.Column)
+	}
+
+	if currentSlug == "" {
+		return nil, nil
+	}
+
+	return &SlugHistory{
+		Table:    stmt.Schema.Table,
+		Slug:     currentSlug,
+		RecordID: slugString(id),
+	}, nil
+}
+
+// afterUpdate records the slugs replaced by beforeUpdate.
+func (sl *slugs) afterUpdate(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	value, ok := db.InstanceGet(slugHistoryKey)
+	if !ok {
+		return
+	}
+
+	for _, entry := range value.([]SlugHistory) {
+		// a slug reused after being replaced points at its latest owner
+		err := db.Session(&gorm.Session{NewDB: true}).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "table_name"}, {Name: "slug"}},
+				DoUpdates: clause.AssignmentColumns([]string{"record_id", "created_at"}),
+			}).
+			Create(&entry).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("failed to record slug history: %w", err))
+			return
+		}
+	}
+}
+
+type slugScopeValues struct {
+	conds []clause.Expression
+	key   string
+}
+
+func slugScope(stmt *gorm.Statement, s Slug, row reflect.Value) slugScopeValues {
+	scope := slugScopeValues{}
+
+	for _, column := range s.Scope {
+		value, _ := stmt.Schema.LookUpField(column).ValueOf(stmt.Context, row)
+
+		scope.conds = append(scope.conds, clause.Eq{Column: clause.Column{Name: column}, Value: value})
+		scope.key += fmt.Sprintf("%v\x00", value)
+	}
+
+	return scope
+}
+
+// unique returns base, or base with the lowest free numeric suffix, among
+// the rows in scope other than excludeID and the pending slugs of a batch.
+// Soft deleted rows count, since they still hold their unique index entry.
+func (sl *slugs) unique(
+	db *gorm.DB,
+	s Slug,
+	base string,
+	scope slugScopeValues,
+	excludeID interface{},
+	pending map[string]bool,
+) (string, error) {
+	prefix := truncateSlug(base, s.maxLength()-maxSlugSuffix)
+
+	query := db.Session(&gorm.Session{NewDB: true}).
+		Table(db.Statement.Table).
+		Where(fmt.Sprintf("%s LIKE ?", s.Column), prefix+"%").
+		Clauses(scope.conds...)
+
+	if excludeID != nil {
+		query = query.Where("id <> ?", excludeID)
+	}
+
+	var existing []string
+	if err := query.Pluck(s.Column, &existing).Error; err != nil {
+		return "", fmt.Errorf("failed to check slug %s: %w", s.Column, err)
+	}
+
+	taken := make(map[string]bool, len(existing))
+	for _, slug := range existing {
+		taken[slug] = true
+	}
+
+	isTaken := func(slug string) bool {
+		return taken[slug] || pending[scope.key+slug]
+	}
+
+	if !isTaken(base) {
+		return base, nil
+	}
+
+	for n := 2; ; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		if len(suffix) > maxSlugSuffix {
+			return "", fmt.Errorf("%w: no free slug for %q", ErrConflict, base)
+		}
+
+		slug := truncateSlug(base, s.maxLength()-len(suffix)) + suffix
+		if !isTaken(slug) {
+			return slug, nil
+		}
+	}
+}
+
+// slugString formats a field value, dereferencing pointers.
+func slugString(value interface{}) string {
+	indirect := reflect.Indirect(reflect.ValueOf(value))
+	if !indirect.IsValid() {
+		return ""
+	}
+
+	return fmt.Sprint(indirect.Interface())
+}
+
+// isSuffixedSlug reports whether slug is base with a collision suffix.
+func isSuffixedSlug(slug, base string) bool {
+	rest, ok := strings.CutPrefix(slug, base+"-")
+	if !ok {
+		return false
+	}
+
+	_, err := strconv.Atoi(rest)
+
+	return err == nil
+}
+
+// updatedValue returns the value an update statement writes to column.
+func updatedValue(stmt *gorm.Statement, column string) (interface{}, bool) {
+	field := stmt.Schema.LookUpField(column)
+
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		if value, ok := dest[field.DBName]; ok {
+			return value, true
+		}
+
+		value, ok := dest[field.Name]
+
+		return value, ok
+	}
+
+	destValue := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+	if destValue.Kind() != reflect.Struct || destValue.Type() != stmt.Schema.ModelType {
+		return nil, false
+	}
+
+	value, _ := field.ValueOf(stmt.Context, destValue)
+
+	return value, true
+}
+
+var slugSchemaCache sync.Map
+
+// modelTable returns the table name of M.
+func modelTable[M any]() (string, error) {
+	modelSchema, err := schema.Parse(newModelPtr[M](), &slugSchemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %T: %w", *new(M), err)
+	}
+
+	return modelSchema.Table, nil
+}
+
+// FindSlugHistory returns the row that had slug in table, or
+// ErrRecordNotFound.
+func FindSlugHistory(ctx context.Context, db DBService, table, slug string) (*SlugHistory, error) {
+	var entry SlugHistory
+
+	if err := db.FindOne(ctx, &entry, nil, nil, "table_name = ? AND slug = ?", table, slug); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
@@ -0,0 +1,143 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	SortQueryParam = "sort"
+)
+
+// NullsOrder places NULL values before or after other values. NullsDefault
+// leaves the database default, which in PostgreSQL sorts NULLs as larger
+// than any value.
+type NullsOrder int
+
+const (
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+// SortKey orders results by one column. CaseInsensitive compares lowered
+// values; Collation sorts with a named collation instead.
+type SortKey struct {
+	Field           string
+	Desc            bool
+	Nulls           NullsOrder
+	CaseInsensitive bool
+	Collation       string
+}
+
+// ParseSort parses a comma separated sort expression. A leading "-" sorts
+// descending and ":nullsfirst", ":nullslast", and ":ci" modify a key, as in
+// "-due_date:nullslast,title:ci".
+func ParseSort(value string) ([]SortKey, error) {
+	keys := []SortKey{}
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		modifiers := strings.Split(part, ":")
+		key := SortKey{Field: modifiers[0]}
+
+		if strings.HasPrefix(key.Field, "-") {
+			key.Desc = true
+			key.Field = key.Field[1:]
+		}
+
+		if !isValidColumnName(key.Field) {
+			return nil, fmt.Errorf("invalid sort field %q", key.Field)
+		}
+
+		for _, modifier := range modifiers[1:] {
+			switch strings.ToLower(modifier) {
+			case "nullsfirst":
+				key.Nulls = NullsFirst
+			case "nullslast":
+				key.Nulls = NullsLast
+			case "ci":
+				key.CaseInsensitive = true
+			default:
+				return nil, fmt.Errorf("unknown sort modifier %q", modifier)
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+type sortContextKey[M any] struct{}
+
+// WithSort sets the order for lists of M loaded with the returned context.
+// Keys are scoped to the model type so other repositories used in the same
+// request are not affected.
+func WithSort[M any](ctx context.Context, keys ...SortKey) context.Context {
+	return context.WithValue(ctx, sortContextKey[M]{}, keys)
+}
+
+func SortFromCtx[M any](ctx context.Context) []SortKey {
+	keys, _ := ctx.Value(sortContextKey[M]{}).([]SortKey)
+	return keys
+}
+
+type orderContextKey int
+
+const (
+	orderClauseKey orderContextKey = iota
+)
+
+// withOrderClause passes a validated ORDER BY clause to DBService.FindMany.
+func withOrderClause(ctx context.Context, clause string) context.Context {
+	return context.WithValue(ctx, orderClauseKey, clause)
+}
+
+func orderClauseFromCtx(ctx context.Context) string {
+	clause, _ := ctx.Value(orderClauseKey).(string)
+	return clause
+}
+
+// orderClause builds an ORDER BY clause from keys, qualifying each field
+// with column.
+func orderClause(keys []SortKey, column func(string) string, dialect Dialect) (string, error) {
+	terms := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if !isValidColumnName(key.Field) {
+			return "", fmt.Errorf("invalid sort field %q", key.Field)
+		}
+
+		col := column(key.Field)
+
+		term := col
+		if key.CaseInsensitive {
+			term = fmt.Sprintf("LOWER(%s)", term)
+		}
+
+		if key.Collation != "" {
+			collated, err := dialect.collate(term, key.Collation)
+			if err != nil {
+				return "", err
+			}
+
+			term = collated
+		}
+
+		if key.Desc {
+			term += " DESC"
+		} else {
+			term += " ASC"
+		}
+
+		terms = append(terms, dialect.nullsOrder(col, term, key.Nulls))
+	}
+
+	return strings.Join(terms, ", "), nil
+}
@@ -0,0 +1,165 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+
+	"go.uber.org/fx"
+)
+
+const TasksGroup = `group:"tasks"`
+
+// Task is a named maintenance job, such as a backfill or data fix, run
+// inside the app's fx container with its database and logger wired.
+type Task struct {
+	Name        string
+	Description string
+	Run         func(ctx context.Context, args []string) error
+}
+
+// AsTask annotates a constructor returning a Task so RunTask and RunCLI can
+// find it.
+func AsTask(constructor interface{}) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(TasksGroup)))
+}
+
+type taskParams struct {
+	fx.In
+
+	Logger LoggerService
+	Tasks  []Task `group:"tasks"`
+}
+
+// RunTask builds and starts the app from opts without a server, runs the
+// named task with args, and shuts the app down.
+func RunTask(ctx context.Context, name string, args []string, opts ...fx.Option) error {
+	var params taskParams
+
+	app, err := newTaskApp(&params, opts)
+	if err != nil {
+		return err
+	}
+
+	task, err := findTask(params.Tasks, name)
+	if err != nil {
+		return err
+	}
+
+	if err := app.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start app: %w", err)
+	}
+	defer app.Stop(context.Background())
+
+	params.Logger.Info("Running task", "task", name, "args", args)
+
+	if err := task.Run(ctx, args); err != nil {
+		return fmt.Errorf("task %s failed: %w", name, err)
+	}
+
+	params.Logger.Info("Finished task", "task", name)
+
+	return nil
+}
+
+// ListTasks builds the app from opts without starting it and returns its
+// tasks by name.
+func ListTasks(opts ...fx.Option) ([]Task, error) {
+	var params taskParams
+
+	if _, err := newTaskApp(&params, opts); err != nil {
+		return nil, err
+	}
+
+	tasks := append([]Task(nil), params.Tasks...)
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+
+	return tasks, nil
+}
+
+// RunCLI runs a command-line invocation of the app, such as
+// `myapp run backfill-slugs --dry-run`, from a main package:
+//
+//	run <task> [args...]   run a registered task
+//	tasks                  list registered tasks
+//	seed [seeds...]        run seeds, see RunSeeds
+//
+// Interrupts cancel the task's context.
+func RunCLI(args []string, opts ...fx.Option) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: run <task> [args...] | tasks | seed [seeds...]")
+	}
+
+	switch args[0] {
+	case "run":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: run <task> [args...]")
+		}
+
+		return RunTask(ctx, args[1], args[2:], opts...)
+	case "tasks":
+		tasks, err := ListTasks(opts...)
+		if err != nil {
+			return err
+		}
+
+		return printTasks(os.Stdout, tasks)
+	case "seed":
+		return RunSeeds(ctx, args[1:], opts...)
+	}
+
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func newTaskApp(params *taskParams, opts []fx.Option) (*fx.App, error) {
+	allOpts := append(BuildAppOpts(), opts...)
+	allOpts = append(allOpts, fx.Populate(params))
+
+	app := fx.New(allOpts...)
+	if err := app.Err(); err != nil {
+		return nil, fmt.Errorf("failed to build app: %w", err)
+	}
+
+	seen := make(map[string]bool, len(params.Tasks))
+	for _, task := range params.Tasks {
+		if task.Name == "" || task.Run == nil {
+			return nil, fmt.Errorf("tasks need a name and a run func")
+		}
+
+		if seen[task.Name] {
+			return nil, fmt.Errorf("duplicate task %q", task.Name)
+		}
+
+		seen[task.Name] = true
+	}
+
+	return app, nil
+}
+
+func findTask(tasks []Task, name string) (Task, error) {
+	for _, task := range tasks {
+		if task.Name == name {
+			return task, nil
+		}
+	}
+
+	return Task{}, fmt.Errorf("%w: task %q", ErrRecordNotFound, name)
+}
+
+func printTasks(w io.Writer, tasks []Task) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	for _, task := range tasks {
+		fmt.Fprintf(tw, "%s\t%s\n", task.Name, task.Description)
+	}
+
+	return tw.Flush()
+}
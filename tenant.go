@@ -0,0 +1,164 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	TenantHeaderName = "X-Tenant-ID"
+)
+
+var ErrTenantRequired = errors.New("tenant required")
+
+type tenantContextKey int
+
+const (
+	tenantIDKey tenantContextKey = iota
+	crossTenantKey
+)
+
+// TenantModel is implemented by models scoped to a tenant. Repositories add
+// a tenant_id condition to every query for these models.
+type TenantModel interface {
+	GetTenantID() uint
+	SetTenantID(tenantID uint)
+}
+
+// TenantUser is implemented by users that belong to a tenant. Their tenant
+// is embedded in the tokens issued for them.
+type TenantUser interface {
+	GetTenantID() uint
+}
+
+// TenantMember is implemented by users that may act in several tenants.
+// It takes precedence over TenantUser.
+type TenantMember interface {
+	IsTenantMember(tenantID uint) bool
+}
+
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+func TenantFromCtx(ctx context.Context) (uint, bool) {
+	tenantID, ok := ctx.Value(tenantIDKey).(uint)
+	return tenantID, ok
+}
+
+// AllowCrossTenant disables tenant scoping for queries made with the
+// returned context. Nothing, including admin access, does this implicitly.
+func AllowCrossTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, crossTenantKey, true)
+}
+
+func isCrossTenantAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(crossTenantKey).(bool)
+	return allowed
+}
+
+// TenantResolver extracts the tenant for a request. It returns false when
+// the request doesn't identify a tenant.
+type TenantResolver func(r *http.Request) (uint, bool, error)
+
+// TenantFromClaims resolves the tenant from the authenticated token. It
+// must run after AuthRequired.
+func TenantFromClaims(auth AuthService) TenantResolver {
+	return func(r *http.Request) (uint, bool, error) {
+		claims, err := auth.GetClaimsFromCtx(r.Context())
+		if err != nil || claims.Tid == 0 {
+			return 0, false, nil
+		}
+
+		return claims.Tid, true, nil
+	}
+}
+
+// TenantFromHeader resolves the tenant from a request header.
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) (uint, bool, error) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return 0, false, nil
+		}
+
+		tenantID, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid tenant header: %w", err)
+		}
+
+		return uint(tenantID), true, nil
+	}
+}
+
+// TenantMiddleware stores the first tenant resolved from the request in the
+// context and rejects requests without one. It must run after AuthRequired:
+// the authenticated user has to be a member of the tenant through
+// TenantMember or TenantUser, so users exposing neither, including admins,
+// are rejected.
+func TenantMiddleware(auth AuthService, resolvers ...TenantResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := auth.GetUserFromCtx(r.Context())
+			if err != nil {
+				DefaultErrorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+				return
+			}
+
+			for _, resolve := range resolvers {
+				tenantID, ok, err := resolve(r)
+				if err != nil {
+					DefaultErrorHandler(w, r, InvalidRequest(err))
+					return
+				}
+
+				if !ok {
+					continue
+				}
+
+				if err := checkTenantMembership(user, tenantID); err != nil {
+					DefaultErrorHandler(w, r, err)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenantID)))
+				return
+			}
+
+			DefaultErrorHandler(w, r, NewStatusError(http.StatusForbidden, ErrTenantRequired))
+		})
+	}
+}
+
+func checkTenantMembership(user User, tenantID uint) error {
+	switch member := user.(type) {
+	case TenantMember:
+		if member.IsTenantMember(tenantID) {
+			return nil
+		}
+	case TenantUser:
+		if member.GetTenantID() == tenantID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: user is not a member of tenant %d", ErrForbidden, tenantID)
+}
+
+// tenantScope returns the tenant condition for ctx. It returns an empty
+// query when scoping is explicitly disabled.
+func tenantScope(ctx context.Context, column string) (string, []interface{}, error) {
+	if isCrossTenantAllowed(ctx) {
+		return "", nil, nil
+	}
+
+	tenantID, ok := TenantFromCtx(ctx)
+	if !ok {
+		return "", nil, ErrTenantRequired
+	}
+
+	return fmt.Sprintf("%s = ?", column), []interface{}{tenantID}, nil
+}
@@ -0,0 +1,386 @@
+package mochi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"gorm.io/gorm"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+
+	dbSpanKey = "mochi:trace_span"
+)
+
+// SpanAttribute is a key-value pair attached to a span, named after the
+// OpenTelemetry semantic conventions where one exists.
+type SpanAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+func Attr(key string, value interface{}) SpanAttribute {
+	return SpanAttribute{Key: key, Value: value}
+}
+
+// Span is a unit of traced work. It mirrors the OpenTelemetry trace.Span
+// methods mochi uses, so an OTel span can be wrapped in a few lines.
+type Span interface {
+	SetName(name string)
+	SetAttributes(attrs ...SpanAttribute)
+	// RecordError records err and marks the span as failed.
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans and propagates trace context across HTTP. Start
+// makes the span in ctx, if any, the parent of the new span. Apps using
+// OpenTelemetry register an adapter around their trace.Tracer and
+// propagation.TextMapPropagator with ProvideTracer.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span)
+	// Extract returns ctx carrying the remote parent from header.
+	Extract(ctx context.Context, header http.Header) context.Context
+	// Inject writes the trace context of ctx into header for outgoing
+	// requests.
+	Inject(ctx context.Context, header http.Header)
+}
+
+type tracerContextKey int
+
+const tracerKey tracerContextKey = 0
+
+// ContextWithTracer makes StartSpan use tracer for ctx. The Tracing
+// middleware does this for requests; background work such as tasks can do
+// it to be traced too.
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, tracer)
+}
+
+// TracerFromCtx returns the tracer of ctx, or a no-op tracer.
+func TracerFromCtx(ctx context.Context) Tracer {
+	tracer, ok := ctx.Value(tracerKey).(Tracer)
+	if !ok {
+		return noopTracer{}
+	}
+
+	return tracer
+}
+
+// StartSpan starts a span with the tracer of ctx. The caller must End it.
+func StartSpan(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	return TracerFromCtx(ctx).Start(ctx, name, attrs...)
+}
+
+// Tracing starts a server span for each request, continuing the trace of
+// an incoming traceparent header. The span is named after the chi route
+// pattern once the request has been routed, and 5xx responses mark it as
+// failed.
+func Tracing(tracer Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := ContextWithTracer(tracer.Extract(r.Context(), r.Header), tracer)
+
+			ctx, span := tracer.Start(ctx, r.Method,
+				Attr("http.request.method", r.Method),
+				Attr("url.path", r.URL.Path),
+			)
+			defer span.End()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetAttributes(Attr("http.route", rctx.RoutePattern()))
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			span.SetAttributes(Attr("http.response.status_code", status))
+
+			if status >= http.StatusInternalServerError {
+				span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+			}
+		})
+	}
+}
+
+// registerTracing adds a span around every query run through db, as a
+// child of the span in the statement context.
+func registerTracing(db *gorm.DB) error {
+	callbacks := db.Callback()
+
+	if err := registerDBSpan("create", callbacks.Create().Before("*"), callbacks.Create().After("*")); err != nil {
+		return err
+	}
+
+	if err := registerDBSpan("query", callbacks.Query().Before("*"), callbacks.Query().After("*")); err != nil {
+		return err
+	}
+
+	if err := registerDBSpan("update", callbacks.Update().Before("*"), callbacks.Update().After("*")); err != nil {
+		return err
+	}
+
+	if err := registerDBSpan("delete", callbacks.Delete().Before("*"), callbacks.Delete().After("*")); err != nil {
+		return err
+	}
+
+	if err := registerDBSpan("row", callbacks.Row().Before("*"), callbacks.Row().After("*")); err != nil {
+		return err
+	}
+
+	return registerDBSpan("raw", callbacks.Raw().Before("*"), callbacks.Raw().After("*"))
+}
+
+type gormCallback interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+func registerDBSpan(operation string, before, after gormCallback) error {
+	if err := before.Register("mochi:trace_before_"+operation, startDBSpan(operation)); err != nil {
+		return err
+	}
+
+	return after.Register("mochi:trace_after_"+operation, endDBSpan)
+}
+
+func startDBSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := StartSpan(db.Statement.Context, "db."+operation,
+			Attr("db.system", string(dialectOf(db))),
+			Attr("db.operation", operation),
+		)
+
+		db.Statement.Context = ctx
+		db.InstanceSet(dbSpanKey, span)
+	}
+}
+
+func endDBSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(dbSpanKey)
+	if !ok {
+		return
+	}
+
+	span := value.(Span)
+
+	if db.Statement.Table != "" {
+		span.SetAttributes(Attr("db.sql.table", db.Statement.Table))
+	}
+
+	span.SetAttributes(
+		Attr("db.statement", db.Statement.SQL.String()),
+		Attr("db.rows_affected", db.RowsAffected),
+	)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+	}
+
+	span.End()
+}
+
+// modelTypeName names span attributes after the model type.
+func modelTypeName[M any]() string {
+	modelType := reflect.TypeOf((*M)(nil)).Elem()
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+
+	return modelType.Name()
+}
+
+type noopTracer struct{}
+
+func NewNoopTracer() Tracer {
+	return noopTracer{}
+}
+
+func (noopTracer) Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	return ctx
+}
+
+func (noopTracer) Inject(ctx context.Context, header http.Header) {}
+
+type noopSpan struct{}
+
+func (noopSpan) SetName(name string) {}
+
+func (noopSpan) SetAttributes(attrs ...SpanAttribute) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
+
+// SpanContext identifies a span within a W3C trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != len(sc.TraceID) {
+		return sc, fmt.Errorf("invalid traceparent trace ID %q", parts[1])
+	}
+
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != len(sc.SpanID) {
+		return sc, fmt.Errorf("invalid traceparent span ID %q", parts[2])
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return sc, fmt.Errorf("invalid traceparent flags %q", parts[3])
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&1 == 1
+
+	if sc.TraceID == [16]byte{} || sc.SpanID == [8]byte{} {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	return sc, nil
+}
+
+type spanContextKey int
+
+const (
+	remoteSpanKey spanContextKey = iota
+	logSpanKey
+)
+
+// LogTracer is a Tracer that writes finished spans to the logger at debug
+// level, with W3C trace and span IDs so they can be correlated with other
+// services. It suits development and apps without a tracing backend.
+type LogTracer struct {
+	logger LoggerService
+}
+
+func NewLogTracer(logger LoggerService) *LogTracer {
+	return &LogTracer{logger: logger}
+}
+
+func (t *LogTracer) Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	span := &logSpan{
+		tracer:  t,
+		name:    name,
+		attrs:   attrs,
+		started: time.Now(),
+	}
+
+	if parent, ok := ctx.Value(logSpanKey).(*logSpan); ok {
+		span.context.TraceID = parent.context.TraceID
+		span.parentID = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteSpanKey).(SpanContext); ok {
+		span.context.TraceID = remote.TraceID
+		span.parentID = remote.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+	}
+
+	rand.Read(span.context.SpanID[:])
+	span.context.Sampled = true
+
+	return context.WithValue(ctx, logSpanKey, span), span
+}
+
+func (t *LogTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, remoteSpanKey, sc)
+}
+
+func (t *LogTracer) Inject(ctx context.Context, header http.Header) {
+	if span, ok := ctx.Value(logSpanKey).(*logSpan); ok {
+		header.Set(TraceparentHeader, span.context.Traceparent())
+	}
+}
+
+type logSpan struct {
+	tracer   *LogTracer
+	name     string
+	attrs    []SpanAttribute
+	context  SpanContext
+	parentID [8]byte
+	started  time.Time
+	err      error
+}
+
+func (s *logSpan) SetName(name string) {
+	s.name = name
+}
+
+func (s *logSpan) SetAttributes(attrs ...SpanAttribute) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *logSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *logSpan) End() {
+	args := []interface{}{
+		"trace_id", hex.EncodeToString(s.context.TraceID[:]),
+		"span_id", hex.EncodeToString(s.context.SpanID[:]),
+		"duration", time.Since(s.started),
+	}
+
+	if s.parentID != [8]byte{} {
+		args = append(args, "parent_id", hex.EncodeToString(s.parentID[:]))
+	}
+
+	for _, attr := range s.attrs {
+		args = append(args, attr.Key, attr.Value)
+	}
+
+	if s.err != nil {
+		args = append(args, "error", s.err)
+	}
+
+	s.tracer.logger.Debug("Span "+s.name, args...)
+}
@@ -0,0 +1,365 @@
+package mochi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RouteTransfer ControllerRoute = "transfer"
+	TransferPath                  = "/transfer"
+)
+
+// OwnershipTransfer is the audit entry written for every resource moved
+// between users. Add it to the app's ModelList when using transfers.
+type OwnershipTransfer struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Resource   string    `gorm:"index:idx_ownership_transfers_resource" json:"resource"`
+	ResourceID string    `gorm:"index:idx_ownership_transfers_resource" json:"resource_id"`
+	FromUserID uint      `gorm:"index" json:"from_user_id"`
+	ToUserID   uint      `gorm:"index" json:"to_user_id"`
+	ActorID    uint      `json:"actor_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Transfer moves items to ToUserID. ItemIDs selects the items, FromUserID
+// restricts them to one owner, and with no ItemIDs every item of FromUserID
+// is moved, as when offboarding a user. ActorID is recorded for auditing.
+type Transfer[K comparable] struct {
+	ItemIDs    []K
+	FromUserID uint
+	ToUserID   uint
+	ActorID    uint
+}
+
+// OwnershipChange is the database side of a Transfer. When Expected is set,
+// the transfer fails unless exactly that many rows match.
+type OwnershipChange struct {
+	ToUserID uint
+	ActorID  uint
+	Expected int
+}
+
+// TransferService moves items between users for controllers using
+// WithOwnershipTransfer. The default Service implements it.
+type TransferService[M Resource[K], K comparable] interface {
+	TransferOwnership(ctx context.Context, transfer Transfer[K]) (int64, error)
+}
+
+// TransferOwnership sets user_id to change.ToUserID on model rows matching
+// query and records an OwnershipTransfer for each, in one transaction.
+func (srv *dbService) TransferOwnership(
+	ctx context.Context,
+	model interface{},
+	change OwnershipChange,
+	query interface{},
+	args ...interface{},
+) (int64, error) {
+	if err := srv.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	if query == nil || query == "" {
+		return 0, fmt.Errorf("transfer ownership requires a query")
+	}
+
+	sesh, cancel := srv.GetSession(ctx)
+	defer cancel()
+
+	stmt := &gorm.Statement{DB: sesh}
+	if err := stmt.Parse(model); err != nil {
+		return 0, fmt.Errorf("failed to parse transfer model: %w", err)
+	}
+
+	var transferred int64
+
+	err := sesh.Transaction(func(tx *gorm.DB) error {
+		instance := reflect.New(stmt.Schema.ModelType).Interface()
+
+		selectQuery := tx.Model(instance).Select("id", "user_id").Where(query, args...)
+		if srv.Dialect() != DialectSQLite {
+			selectQuery = selectQuery.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+
+		var rows []map[string]interface{}
+		if err := selectQuery.Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to select items: %w", err)
+		}
+
+		if change.Expected > 0 && len(rows) != change.Expected {
+			return fmt.Errorf("%w: %d of %d items to transfer", ErrRecordNotFound, len(rows), change.Expected)
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		updateResult := tx.Model(instance).Where(query, args...).Update("user_id", change.ToUserID)
+		if updateResult.Error != nil {
+			return fmt.Errorf("failed to update owners: %w", updateResult.Error)
+		}
+
+		entries := make([]OwnershipTransfer, 0, len(rows))
+		for _, row := range rows {
+			entries = append(entries, OwnershipTransfer{
+				Resource:   stmt.Schema.Table,
+				ResourceID: auditID(row["id"]),
+				FromUserID: auditUserID(row["user_id"]),
+				ToUserID:   change.ToUserID,
+				ActorID:    change.ActorID,
+			})
+		}
+
+		if err := tx.CreateInBatches(&entries, CreateBatchSize).Error; err != nil {
+			return fmt.Errorf("failed to record transfers: %w", err)
+		}
+
+		transferred = int64(len(rows))
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("transfer ownership failed: %w", err)
+	}
+
+	return transferred, nil
+}
+
+// auditUserID converts a scanned user_id, whose integer type depends on the
+// driver and column.
+func auditUserID(id interface{}) uint {
+	v := reflect.ValueOf(id)
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(v.Uint())
+	}
+
+	return 0
+}
+
+func auditID(id interface{}) string {
+	if raw, ok := id.([]byte); ok {
+		return string(raw)
+	}
+
+	return fmt.Sprint(id)
+}
+
+// TransferOwnership moves items to transfer.ToUserID within the context
+// tenant. When ItemIDs are given, every one of them must match or nothing is
+// transferred.
+func (r *repository[M, K]) TransferOwnership(ctx context.Context, transfer Transfer[K]) (int64, error) {
+	if r.viewBacked {
+		return 0, ErrViewNotWritable
+	}
+
+	var model M
+	if _, ok := any(model).(OwnedModel); !ok {
+		return 0, fmt.Errorf("%T does not implement OwnedModel", model)
+	}
+
+	if transfer.ToUserID == 0 {
+		return 0, fmt.Errorf("transfer requires a target user")
+	}
+
+	conds := []Cond{}
+	expected := 0
+
+	if len(transfer.ItemIDs) > 0 {
+		unique := make(map[K]bool, len(transfer.ItemIDs))
+		for _, id := range transfer.ItemIDs {
+			unique[id] = true
+		}
+
+		conds = append(conds, In(r.column("id"), transfer.ItemIDs))
+		expected = len(unique)
+	}
+
+	if transfer.FromUserID != 0 {
+		conds = append(conds, Eq(r.column("user_id"), transfer.FromUserID))
+	}
+
+	if len(conds) == 0 {
+		return 0, fmt.Errorf("transfer requires items or a source user")
+	}
+
+	query, args, err := And(conds...).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err = r.scopeQuery(ctx, query, args)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := r.db.TransferOwnership(ctx, new(M), OwnershipChange{
+		ToUserID: transfer.ToUserID,
+		ActorID:  transfer.ActorID,
+		Expected: expected,
+	}, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to transfer items: %w", err)
+	}
+
+	r.logger.WithContext(ctx).Info("Transferred items", "table", r.tableName, "count", count, "to_user", transfer.ToUserID, "actor", transfer.ActorID)
+
+	return count, nil
+}
+
+func (s *service[M, K]) TransferOwnership(ctx context.Context, transfer Transfer[K]) (int64, error) {
+	count, err := s.repo.TransferOwnership(ctx, transfer)
+	if err != nil {
+		return 0, fmt.Errorf("failed to transfer ownership: %w", err)
+	}
+
+	return count, nil
+}
+
+// TransferRequest is the body of POST /transfer. IDs may be JSON strings or
+// numbers.
+type TransferRequest struct {
+	IDs        []json.RawMessage `json:"ids"`
+	FromUserID uint              `json:"from_user_id"`
+	ToUserID   uint              `json:"to_user_id"`
+}
+
+func (req *TransferRequest) Bind(r *http.Request) error {
+	if req.ToUserID == 0 {
+		return fmt.Errorf("to_user_id is required")
+	}
+
+	return nil
+}
+
+type TransferResponse struct {
+	Transferred int64 `json:"transferred"`
+}
+
+func (resp *TransferResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// handleTransfer moves the requested items to another user. Admins may
+// move any items, or all of from_user_id's; other users only their own
+// items, listed by ID.
+func (c *controller[M, K]) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	transferSvc, ok := c.svc.(TransferService[M, K])
+	if !ok {
+		c.renderError(w, r, "failed to transfer items", fmt.Errorf("%T does not support transfers", c.svc))
+		return
+	}
+
+	user, err := c.auth.GetUserFromCtx(ctx)
+	if err != nil {
+		c.errorHandler(w, r, NewStatusError(http.StatusUnauthorized, err))
+		return
+	}
+
+	actor, err := c.auth.GetActorFromCtx(ctx)
+	if err != nil {
+		actor = user
+	}
+
+	req := &TransferRequest{}
+	if err := render.Bind(r, req); err != nil {
+		c.errorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	transfer := Transfer[K]{
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		ActorID:    actor.GetID(),
+	}
+
+	for _, raw := range req.IDs {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+
+		id, err := c.idParser(value)
+		if err != nil {
+			c.errorHandler(w, r, InvalidRequest(err))
+			return
+		}
+
+		transfer.ItemIDs = append(transfer.ItemIDs, id)
+	}
+
+	if !user.IsAdmin() {
+		if transfer.FromUserID != 0 && transfer.FromUserID != user.GetID() {
+			c.errorHandler(w, r, NewStatusError(http.StatusForbidden, fmt.Errorf("cannot transfer another user's items")))
+			return
+		}
+
+		if len(transfer.ItemIDs) == 0 {
+			c.errorHandler(w, r, InvalidRequest(fmt.Errorf("ids are required")))
+			return
+		}
+
+		transfer.FromUserID = user.GetID()
+	}
+
+	if err := c.checkTransferTarget(ctx, transfer.ToUserID); err != nil {
+		c.renderError(w, r, "failed to transfer items", err)
+		return
+	}
+
+	count, err := transferSvc.TransferOwnership(ctx, transfer)
+	if err != nil {
+		c.renderError(w, r, "failed to transfer items", err)
+		return
+	}
+
+	render.Render(w, r, &TransferResponse{Transferred: count})
+}
+
+// checkTransferTarget requires the receiving user to exist and, for
+// tenant-scoped requests, to be a member of the tenant. Both failures are
+// reported alike so other tenants' users can't be discovered.
+func (c *controller[M, K]) checkTransferTarget(ctx context.Context, userID uint) error {
+	unknown := fmt.Errorf("%w: unknown to_user_id %d", ErrValidation, userID)
+
+	target, err := c.transferUsers.GetUserByID(ctx, userID)
+	if errors.Is(err, ErrRecordNotFound) {
+		return unknown
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get transfer target: %w", err)
+	}
+
+	if tenantID, ok := TenantFromCtx(ctx); ok && !isCrossTenantAllowed(ctx) {
+		if checkTenantMembership(target, tenantID) != nil {
+			return unknown
+		}
+	}
+
+	return nil
+}
+
+// WithOwnershipTransfer mounts POST /transfer, moving items between users
+// with an OwnershipTransfer audit entry per item. users looks up the
+// receiving user, who must exist and belong to the request's tenant.
+func WithOwnershipTransfer[M Resource[K], K comparable](users UserService) ControllerOption[M, K] {
+	return func(c *controller[M, K]) {
+		c.transferUsers = users
+	}
+}
@@ -0,0 +1,409 @@
+package mochi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	UsageBucketSize    = time.Hour
+	UsageFlushInterval = 30 * time.Second
+	UsageDefaultWindow = 30 * 24 * time.Hour
+	UsageSinceParam    = "since"
+)
+
+// UsageRollup is the model backing usage analytics, one row per user, route,
+// and hour. Add it to the app's ModelList when using NewUsageService.
+type UsageRollup struct {
+	UserID uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Route  string    `gorm:"primaryKey" json:"route"`
+	Bucket time.Time `gorm:"primaryKey;index" json:"bucket"`
+
+	Requests       int64 `json:"requests"`
+	Errors         int64 `json:"errors"`
+	TotalLatencyMs int64 `json:"total_latency_ms"`
+	MaxLatencyMs   int64 `json:"max_latency_ms"`
+}
+
+func (u *UsageRollup) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// UsageSummary totals usage for a route over a window.
+type UsageSummary struct {
+	Route        string  `json:"route"`
+	Users        int64   `json:"users"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+}
+
+func (u *UsageSummary) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// UsageService records per-user request counts and latencies into hourly
+// rollups. Its middleware must run after AuthRequired, for example through
+// WithMiddleware, since anonymous requests aren't recorded.
+//
+// MeUsageHandler serves the current user's usage, typically at /me/usage,
+// and GetRouter serves admin aggregates.
+type UsageService interface {
+	Middleware() func(http.Handler) http.Handler
+	UsageForUser(ctx context.Context, userID uint, since time.Time) ([]UsageRollup, error)
+	Summary(ctx context.Context, since time.Time) ([]UsageSummary, error)
+	Flush(ctx context.Context) error
+
+	MeUsageHandler() http.Handler
+	GetRouter() *chi.Mux
+}
+
+type UsageServiceParams struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Auth      AuthService
+	DB        DBService
+	Logger    LoggerService
+	Shutdown  *Shutdown `optional:"true"`
+}
+
+type UsageServiceResult struct {
+	fx.Out
+
+	UsageService UsageService
+}
+
+type usageKey struct {
+	userID uint
+	route  string
+	bucket time.Time
+}
+
+type usageService struct {
+	auth   AuthService
+	db     DBService
+	logger LoggerService
+	Router *chi.Mux
+
+	mu      sync.Mutex
+	pending map[usageKey]*UsageRollup
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewUsageService(params UsageServiceParams) (UsageServiceResult, error) {
+	srv := &usageService{
+		auth:    params.Auth,
+		db:      params.DB,
+		logger:  params.Logger,
+		pending: make(map[usageKey]*UsageRollup),
+	}
+
+	srv.Router = chi.NewRouter()
+	srv.Router.Use(params.Auth.AuthRequired())
+	srv.Router.Use(params.Auth.AdminRequired())
+
+	srv.Router.Get("/", srv.handleSummary)
+	srv.Router.Get("/users/{userID}", srv.handleUserUsage)
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			srv.stop = make(chan struct{})
+			srv.done = make(chan struct{})
+
+			go srv.run()
+
+			return nil
+		},
+	})
+
+	registerShutdown(params.Lifecycle, params.Shutdown, ShutdownHook{
+		Name:  "usage flush",
+		Stage: StageFlush,
+		Stop: func(ctx context.Context) error {
+			close(srv.stop)
+			<-srv.done
+
+			return srv.Flush(ctx)
+		},
+	})
+
+	return UsageServiceResult{UsageService: srv}, nil
+}
+
+func (srv *usageService) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := srv.auth.GetUserFromCtx(r.Context())
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			srv.record(usageKey{
+				userID: user.GetID(),
+				route:  fmt.Sprintf("%s %s", r.Method, route),
+				bucket: start.UTC().Truncate(UsageBucketSize),
+			}, time.Since(start), ww.Status() >= http.StatusInternalServerError)
+		})
+	}
+}
+
+func (srv *usageService) record(key usageKey, latency time.Duration, failed bool) {
+	ms := latency.Milliseconds()
+
+	rollup := &UsageRollup{
+		UserID:         key.userID,
+		Route:          key.route,
+		Bucket:         key.bucket,
+		Requests:       1,
+		TotalLatencyMs: ms,
+		MaxLatencyMs:   ms,
+	}
+
+	if failed {
+		rollup.Errors = 1
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.addPending(rollup)
+}
+
+// addPending adds rollup to the pending rollup for the same key. Callers
+// must hold mu.
+func (srv *usageService) addPending(rollup *UsageRollup) {
+	key := usageKey{userID: rollup.UserID, route: rollup.Route, bucket: rollup.Bucket}
+
+	existing, ok := srv.pending[key]
+	if !ok {
+		srv.pending[key] = rollup
+		return
+	}
+
+	existing.Requests += rollup.Requests
+	existing.Errors += rollup.Errors
+	existing.TotalLatencyMs += rollup.TotalLatencyMs
+
+	if rollup.MaxLatencyMs > existing.MaxLatencyMs {
+		existing.MaxLatencyMs = rollup.MaxLatencyMs
+	}
+}
+
+// Flush writes pending rollups, adding them to any rows already stored.
+// When the write fails, the rollups are kept for the next flush.
+func (srv *usageService) Flush(ctx context.Context) error {
+	srv.mu.Lock()
+	pending := srv.pending
+	srv.pending = make(map[usageKey]*UsageRollup)
+	srv.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rollups := make([]*UsageRollup, 0, len(pending))
+	for _, rollup := range pending {
+		rollups = append(rollups, rollup)
+	}
+
+	if err := srv.writeRollups(ctx, rollups); err != nil {
+		srv.mu.Lock()
+		for _, rollup := range rollups {
+			srv.addPending(rollup)
+		}
+		srv.mu.Unlock()
+
+		return fmt.Errorf("failed to flush usage rollups: %w", err)
+	}
+
+	return nil
+}
+
+func (srv *usageService) writeRollups(ctx context.Context, rollups []*UsageRollup) error {
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	dialect := srv.db.Dialect()
+
+	sum := func(column string) clause.Expr {
+		return gorm.Expr(fmt.Sprintf("usage_rollups.%s + %s", column, dialect.excluded(column)))
+	}
+
+	return sesh.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "route"}, {Name: "bucket"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":         sum("requests"),
+			"errors":           sum("errors"),
+			"total_latency_ms": sum("total_latency_ms"),
+			"max_latency_ms": gorm.Expr(dialect.greatest(
+				"usage_rollups.max_latency_ms", dialect.excluded("max_latency_ms"),
+			)),
+		}),
+	}).Create(&rollups).Error
+}
+
+func (srv *usageService) UsageForUser(ctx context.Context, userID uint, since time.Time) ([]UsageRollup, error) {
+	var rollups []UsageRollup
+
+	err := srv.db.FindMany(ctx, &rollups, nil, nil, "user_id = ? AND bucket >= ?", userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user usage: %w", err)
+	}
+
+	return rollups, nil
+}
+
+func (srv *usageService) Summary(ctx context.Context, since time.Time) ([]UsageSummary, error) {
+	var summaries []UsageSummary
+
+	sesh, cancel := srv.db.GetSession(ctx)
+	defer cancel()
+
+	err := sesh.Model(&UsageRollup{}).
+		Select(
+			"route, COUNT(DISTINCT user_id) AS users, SUM(requests) AS requests, SUM(errors) AS errors, "+
+				"SUM(total_latency_ms) * 1.0 / NULLIF(SUM(requests), 0) AS avg_latency_ms, MAX(max_latency_ms) AS max_latency_ms",
+		).
+		Where("bucket >= ?", since).
+		Group("route").
+		Order("requests DESC").
+		Scan(&summaries).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize usage: %w", err)
+	}
+
+	return summaries, nil
+}
+
+func (srv *usageService) MeUsageHandler() http.Handler {
+	return srv.auth.AuthRequired()(http.HandlerFunc(srv.handleMe))
+}
+
+func (srv *usageService) GetRouter() *chi.Mux {
+	return srv.Router
+}
+
+func (srv *usageService) run() {
+	defer close(srv.done)
+
+	ticker := time.NewTicker(UsageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-srv.stop:
+			return
+		case <-ticker.C:
+			if err := srv.Flush(context.Background()); err != nil {
+				srv.logger.Error("Failed to flush usage", "error", err)
+			}
+		}
+	}
+}
+
+func (srv *usageService) handleMe(w http.ResponseWriter, r *http.Request) {
+	user, err := srv.auth.GetUserFromCtx(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrUnauthorized(err))
+		return
+	}
+
+	srv.renderUserUsage(w, r, user.GetID())
+}
+
+func (srv *usageService) handleUserUsage(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(chi.URLParam(r, "userID"), 10, 0)
+	if err != nil {
+		DefaultErrorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	srv.renderUserUsage(w, r, uint(userID))
+}
+
+func (srv *usageService) renderUserUsage(w http.ResponseWriter, r *http.Request, userID uint) {
+	since, err := usageSince(r)
+	if err != nil {
+		DefaultErrorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	rollups, err := srv.UsageForUser(r.Context(), userID, since)
+	if err != nil {
+		srv.logger.Error("Failed to get user usage", "user", userID, "error", err)
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	respList := make([]render.Renderer, 0, len(rollups))
+	for i := range rollups {
+		respList = append(respList, &rollups[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
+
+func (srv *usageService) handleSummary(w http.ResponseWriter, r *http.Request) {
+	since, err := usageSince(r)
+	if err != nil {
+		DefaultErrorHandler(w, r, InvalidRequest(err))
+		return
+	}
+
+	summaries, err := srv.Summary(r.Context(), since)
+	if err != nil {
+		srv.logger.Error("Failed to summarize usage", "error", err)
+		DefaultErrorHandler(w, r, err)
+		return
+	}
+
+	respList := make([]render.Renderer, 0, len(summaries))
+	for i := range summaries {
+		respList = append(respList, &summaries[i])
+	}
+
+	render.RenderList(w, r, respList)
+}
+
+// usageSince reads the RFC 3339 since parameter, defaulting to
+// UsageDefaultWindow ago.
+func usageSince(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get(UsageSinceParam)
+	if value == "" {
+		return time.Now().Add(-UsageDefaultWindow), nil
+	}
+
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since parameter: %w", err)
+	}
+
+	return since, nil
+}
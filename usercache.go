@@ -0,0 +1,71 @@
+package mochi
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultUserCacheTTL  = 30 * time.Second
+	DefaultUserCacheSize = 10000
+)
+
+type cachedUser struct {
+	user      User
+	expiresAt time.Time
+}
+
+// userCache keeps users loaded by AuthRequired for a short TTL. It is local
+// to the process, so the TTL bounds how long other instances can serve a
+// user that has been changed or removed.
+type userCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu    sync.RWMutex
+	users map[uint]cachedUser
+}
+
+func newUserCache(ttl time.Duration, maxSize int) *userCache {
+	return &userCache{ttl: ttl, maxSize: maxSize, users: make(map[uint]cachedUser)}
+}
+
+func (c *userCache) get(userID uint) (User, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.users[userID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.user, true
+}
+
+func (c *userCache) set(userID uint, user User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if len(c.users) >= c.maxSize {
+		for id, entry := range c.users {
+			if now.After(entry.expiresAt) {
+				delete(c.users, id)
+			}
+		}
+	}
+
+	if len(c.users) >= c.maxSize {
+		c.users = make(map[uint]cachedUser)
+	}
+
+	c.users[userID] = cachedUser{user: user, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *userCache) invalidate(userID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.users, userID)
+}
@@ -0,0 +1,98 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var ErrViewNotWritable = errors.New("view-backed models are read-only")
+
+// ViewModel is implemented by read models backed by a SQL view. Migrate
+// creates the view from ViewDefinition after all tables, instead of running
+// AutoMigrate for the model. Repositories refuse writes to view models and
+// controllers only mount their read routes.
+type ViewModel interface {
+	ViewDefinition() string
+}
+
+func isViewModel[M any]() bool {
+	var model M
+	_, ok := any(model).(ViewModel)
+
+	return ok
+}
+
+// viewStatements returns the statements creating the view. SQLite has no
+// CREATE OR REPLACE VIEW, so the view is dropped and recreated instead.
+func viewStatements(db *gorm.DB, model interface{}) ([]string, error) {
+	view := model.(ViewModel)
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, fmt.Errorf("failed to parse view model %T: %w", model, err)
+	}
+
+	if isMaterializedView(db, model) {
+		return []string{fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s AS %s", stmt.Schema.Table, view.ViewDefinition())}, nil
+	}
+
+	if dialectOf(db) == DialectSQLite {
+		return []string{
+			fmt.Sprintf("DROP VIEW IF EXISTS %s", stmt.Schema.Table),
+			fmt.Sprintf("CREATE VIEW %s AS %s", stmt.Schema.Table, view.ViewDefinition()),
+		}, nil
+	}
+
+	return []string{fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s", stmt.Schema.Table, view.ViewDefinition())}, nil
+}
+
+// isMaterializedView reports whether model is created as a materialized
+// view, which requires both the model and the database to support it.
+func isMaterializedView(db *gorm.DB, model interface{}) bool {
+	_, ok := model.(MaterializedViewModel)
+	return ok && dialectOf(db).SupportsMaterializedViews()
+}
+
+// splitViewModels separates view models from table models so views are
+// created once the tables they select from exist.
+func splitViewModels(models []interface{}) (tables []interface{}, views []interface{}) {
+	for _, model := range models {
+		if _, ok := model.(ViewModel); ok {
+			views = append(views, model)
+		} else {
+			tables = append(tables, model)
+		}
+	}
+
+	return tables, views
+}
+
+func (srv *dbService) migrateViews(ctx context.Context, views []interface{}) error {
+	sesh := srv.db.WithContext(ctx)
+
+	for _, view := range views {
+		statements, err := viewStatements(sesh, view)
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range statements {
+			srv.logger.Info("Creating view", "sql", statement)
+
+			if err := sesh.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to create view for %T: %w", view, err)
+			}
+		}
+
+		if isMaterializedView(sesh, view) {
+			if err := srv.migrateIndexes(ctx, view); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
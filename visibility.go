@@ -0,0 +1,289 @@
+package mochi
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// VisibilityTag restricts a DTO field to certain viewers, for example
+// `visible:"admin"`, `visible:"owner,scope:billing:read"`. Any listed rule
+// grants access:
+//
+//   - admin: admin users
+//   - owner: the user owning the item, see OwnedModel
+//   - user: any authenticated user
+//   - scope:<scope>: tokens granted the scope, or tokens without scopes
+//
+// Admins see every field. Hidden fields are zeroed, so they should be tagged
+// omitempty to be left out of the response entirely. Fields tagged with
+// MaskTag are masked for every viewer allowed to see them.
+const VisibilityTag = "visible"
+
+// Viewer is who a response is rendered for.
+type Viewer struct {
+	User   User
+	Owner  bool
+	Scopes []string
+
+	// Scoped is false for tokens without scope claims, which are
+	// unrestricted like in RequireScope.
+	Scoped bool
+}
+
+// NewViewer describes the request's user, and whether it owns item, for
+// ApplyVisibility. item may be nil for responses not tied to a resource.
+func NewViewer(ctx context.Context, auth AuthService, item any) Viewer {
+	user, err := auth.GetUserFromCtx(ctx)
+	if err != nil {
+		return Viewer{}
+	}
+
+	viewer := Viewer{User: user}
+
+	if owned, ok := item.(OwnedModel); ok {
+		viewer.Owner = owned.GetUserID() == user.GetID()
+	}
+
+	if claims, err := auth.GetClaimsFromCtx(ctx); err == nil && (claims.Scope != "" || claims.Pty != "") {
+		viewer.Scoped = true
+		viewer.Scopes = claims.Scopes()
+	}
+
+	return viewer
+}
+
+func (v Viewer) allowed(rules []string) bool {
+	if v.User == nil {
+		return false
+	}
+
+	if v.User.IsAdmin() {
+		return true
+	}
+
+	for _, rule := range rules {
+		switch {
+		case rule == "user":
+			return true
+		case rule == "owner":
+			if v.Owner {
+				return true
+			}
+		case strings.HasPrefix(rule, "scope:"):
+			if !v.Scoped || containsScope(v.Scopes, strings.TrimPrefix(rule, "scope:")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// visibilityField is a field with visibility rules or a masker, or a nested
+// value whose own fields may have them.
+type visibilityField struct {
+	index  int
+	rules  []string
+	mask   Masker
+	nested bool
+}
+
+// visibilityCache maps struct types to their visibilityFields.
+var visibilityCache sync.Map
+
+// ApplyVisibility zeroes the fields of dto, and of nested structs, slices,
+// and maps, that viewer may not see and masks the rest. Values that can't be
+// changed in place, such as structs held by value or in maps, are stripped
+// in a copy, so callers must use the returned dto.
+func ApplyVisibility[T any](dto T, viewer Viewer) T {
+	visibility := &visibilityWalker{viewer: viewer, seen: make(map[visibilityRef]bool)}
+	visibility.apply(reflect.ValueOf(&dto).Elem())
+
+	return dto
+}
+
+// visibilityWalker strips a value, visiting each pointer, map, and slice
+// once so shared and self-referencing values terminate.
+type visibilityWalker struct {
+	viewer Viewer
+	seen   map[visibilityRef]bool
+}
+
+type visibilityRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func (w *visibilityWalker) visit(v reflect.Value) bool {
+	ref := visibilityRef{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+
+	if w.seen[ref] {
+		return false
+	}
+
+	w.seen[ref] = true
+
+	return true
+}
+
+func (w *visibilityWalker) apply(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && w.visit(v) {
+			w.apply(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+
+		elem := v.Elem()
+		if elem.Kind() == reflect.Ptr || !mayHaveVisibility(elem.Type()) {
+			w.apply(elem)
+			return
+		}
+
+		// values held by an interface can't be set, so strip a copy
+		if v.CanSet() {
+			stripped := reflect.New(elem.Type()).Elem()
+			stripped.Set(elem)
+			w.apply(stripped)
+			v.Set(stripped)
+		}
+	case reflect.Slice:
+		if v.IsNil() || !w.visit(v) {
+			return
+		}
+
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			w.apply(v.Index(i))
+		}
+	case reflect.Map:
+		if v.IsNil() || !mayHaveVisibility(v.Type().Elem()) || !w.visit(v) {
+			return
+		}
+
+		// map values aren't addressable, so strip copies into a new map,
+		// leaving the map the model may share intact
+		stripped := v
+		if v.CanSet() {
+			stripped = reflect.MakeMapWithSize(v.Type(), v.Len())
+		}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			w.apply(value)
+			stripped.SetMapIndex(iter.Key(), value)
+		}
+
+		if v.CanSet() {
+			v.Set(stripped)
+		}
+	case reflect.Struct:
+		if !v.CanSet() {
+			return
+		}
+
+		for _, field := range visibilityFields(v.Type()) {
+			fv := v.Field(field.index)
+
+			if field.rules != nil && !w.viewer.allowed(field.rules) {
+				fv.SetZero()
+				continue
+			}
+
+			if field.mask != nil {
+				maskValue(fv, field.mask)
+			}
+
+			if field.nested {
+				w.apply(fv)
+			}
+		}
+	}
+}
+
+func visibilityFields(t reflect.Type) []visibilityField {
+	if cached, ok := visibilityCache.Load(t); ok {
+		return cached.([]visibilityField)
+	}
+
+	fields := []visibilityField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		field := visibilityField{index: i, nested: mayHaveVisibility(sf.Type)}
+
+		if tag, ok := sf.Tag.Lookup(VisibilityTag); ok {
+			for _, rule := range strings.Split(tag, ",") {
+				if rule = strings.TrimSpace(rule); rule != "" {
+					field.rules = append(field.rules, rule)
+				}
+			}
+		}
+
+		if name, ok := sf.Tag.Lookup(MaskTag); ok {
+			masker, err := lookupMasker(name)
+			if err != nil {
+				// fail closed rather than exposing the value
+				masker = MaskFull
+			}
+
+			field.mask = masker
+		}
+
+		if field.rules != nil || field.mask != nil || field.nested {
+			fields = append(fields, field)
+		}
+	}
+
+	visibilityCache.Store(t, fields)
+
+	return fields
+}
+
+// maskValue masks string and *string fields.
+func maskValue(v reflect.Value, masker Masker) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+
+		// mask a copy so the model the pointer may share stays intact
+		masked := reflect.New(v.Type().Elem())
+		masked.Elem().Set(v.Elem())
+		v.Set(masked)
+		v = masked.Elem()
+	}
+
+	if v.Kind() == reflect.String {
+		v.SetString(masker(v.String()))
+	}
+}
+
+// mayHaveVisibility reports whether values of t can contain structs, and so
+// tagged fields.
+func mayHaveVisibility(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return mayHaveVisibility(t.Elem())
+	case reflect.Interface, reflect.Struct:
+		return true
+	}
+
+	return false
+}